@@ -0,0 +1,172 @@
+package aead
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// XChaCha20Poly1305 uses 24 byte nonces that are safe to choose at random
+	XChaCha20Poly1305 Algorithm = "XChaCha20-Poly1305"
+	// ChaCha20Poly1305 is the RFC 8439 AEAD with 12 byte nonces
+	ChaCha20Poly1305 Algorithm = "ChaCha20-Poly1305"
+	// AES256GCM is AES-GCM with a 32 byte key and 12 byte nonces
+	AES256GCM Algorithm = "AES-256-GCM"
+	// AES256GCMSIV is the RFC 8452 AEAD with a 32 byte key. Repeating a nonce
+	// only reveals whether two messages with the same associated data are equal
+	AES256GCMSIV Algorithm = "AES-256-GCM-SIV"
+
+	// KeySize is the size of the keys of every algorithm
+	KeySize = 32
+	// TagSize is the size of the authentication tag added by every algorithm
+	TagSize = 16
+)
+
+// randReader is the source of randomness used for keys and nonces
+var randReader io.Reader = rand.Reader
+
+// Algorithm is the identifier of an AEAD algorithm
+type Algorithm string
+
+// AEAD is an authenticated encryption cipher with associated data. Seal and
+// Open follow cipher.AEAD and panic with a nonce of the wrong size, Encrypt
+// and Decrypt manage a random nonce and return errors instead
+type AEAD interface {
+	cipher.AEAD
+
+	// Algorithm returns the algorithm identifier of the cipher
+	Algorithm() Algorithm
+	// Encrypt seals the plaintext with a random nonce that is prefixed to the
+	// returned ciphertext
+	Encrypt(plaintext, associatedData []byte) ([]byte, error)
+	// Decrypt opens a ciphertext created by Encrypt with the same associated
+	// data
+	Decrypt(ciphertext, associatedData []byte) ([]byte, error)
+}
+
+type aeadCipher struct {
+	cipher.AEAD
+	alg Algorithm
+}
+
+// Algorithms returns the supported algorithms
+func Algorithms() []Algorithm {
+	return []Algorithm{XChaCha20Poly1305, ChaCha20Poly1305, AES256GCM, AES256GCMSIV}
+}
+
+// Valid returns true if the algorithm is supported
+func (a Algorithm) Valid() bool {
+	for _, alg := range Algorithms() {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns the AEAD of the algorithm with a 32 byte key
+func New(alg Algorithm, key []byte) (AEAD, error) {
+	const op = "aead.New"
+
+	if !alg.Valid() {
+		return nil, ez.New(op, ez.EINVALID, "Unsupported AEAD algorithm "+string(alg), nil)
+	} else if len(key) != KeySize {
+		return nil, ez.New(op, ez.EINVALID, "An AEAD key must be 32 bytes long", nil)
+	}
+
+	var c cipher.AEAD
+	var err error
+
+	switch alg {
+	case XChaCha20Poly1305:
+		c, err = chacha20poly1305.NewX(key)
+	case ChaCha20Poly1305:
+		c, err = chacha20poly1305.New(key)
+	case AES256GCM:
+		var block cipher.Block
+		block, err = aes.NewCipher(key)
+		if err == nil {
+			c, err = cipher.NewGCM(block)
+		}
+	case AES256GCMSIV:
+		c, err = newGCMSIV(key)
+	}
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while creating the cipher", err)
+	}
+
+	return &aeadCipher{AEAD: c, alg: alg}, nil
+}
+
+// NewFromKey returns the AEAD of a key created by NewKey, the algorithm is
+// taken from the key type
+func NewFromKey(key *keys.Key) (AEAD, error) {
+	const op = "aead.NewFromKey"
+
+	if key == nil {
+		return nil, ez.New(op, ez.EINVALID, "Key can not be nil", nil)
+	}
+
+	c, err := New(Algorithm(key.Type), key.Value)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return c, nil
+}
+
+// NewKey returns a random key for the algorithm, its type is the algorithm
+// identifier
+func NewKey(alg Algorithm) (*keys.Key, error) {
+	const op = "aead.NewKey"
+
+	if !alg.Valid() {
+		return nil, ez.New(op, ez.EINVALID, "Unsupported AEAD algorithm "+string(alg), nil)
+	}
+
+	key := make([]byte, KeySize)
+	_, err := io.ReadFull(randReader, key)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the key", err)
+	}
+
+	return keys.New(key, keys.Type(alg)), nil
+}
+
+func (c *aeadCipher) Algorithm() Algorithm {
+	return c.alg
+}
+
+func (c *aeadCipher) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
+	const op = "aead.AEAD.Encrypt"
+
+	nonce := make([]byte, c.NonceSize(), c.NonceSize()+len(plaintext)+c.Overhead())
+	_, err := io.ReadFull(randReader, nonce)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the nonce", err)
+	}
+
+	return c.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func (c *aeadCipher) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
+	const op = "aead.AEAD.Decrypt"
+
+	if len(ciphertext) < c.NonceSize()+c.Overhead() {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is too short", nil)
+	}
+
+	nonce := ciphertext[:c.NonceSize()]
+	plaintext, err := c.Open(nil, nonce, ciphertext[c.NonceSize():], associatedData)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not decrypt, invalid ciphertext, associated data or key", nil)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,138 @@
+package aead
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+)
+
+func TestNew(t *testing.T) {
+	// Setup
+	key := make([]byte, KeySize)
+
+	// Case 1: Should create every algorithm
+	for _, alg := range Algorithms() {
+		c, err := New(alg, key)
+		assert.Nil(t, err)
+		assert.Equal(t, alg, c.Algorithm())
+		assert.Equal(t, TagSize, c.Overhead())
+	}
+
+	// Case 2: Should fail with an unknown algorithm
+	_, err := New("AES-128-CBC", key)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with a key of the wrong length
+	_, err = New(AES256GCM, key[:16])
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	// Setup
+	plaintext := []byte("account balance")
+	ad := []byte("record:42")
+
+	for _, alg := range Algorithms() {
+		key, err := NewKey(alg)
+		assert.Nil(t, err)
+		assert.Equal(t, keys.Type(alg), key.Type)
+
+		c, err := NewFromKey(key)
+		assert.Nil(t, err)
+
+		// Case 1: Should decrypt with the same associated data
+		ciphertext, err := c.Encrypt(plaintext, ad)
+		assert.Nil(t, err)
+		assert.Equal(t, c.NonceSize()+len(plaintext)+TagSize, len(ciphertext))
+
+		decrypted, err := c.Decrypt(ciphertext, ad)
+		assert.Nil(t, err, alg)
+		assert.Equal(t, plaintext, decrypted)
+
+		// Case 2: Should fail with other associated data
+		_, err = c.Decrypt(ciphertext, []byte("record:43"))
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err), alg)
+
+		// Case 3: Should fail with a modified ciphertext
+		ciphertext[len(ciphertext)-1] ^= 1
+		_, err = c.Decrypt(ciphertext, ad)
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err), alg)
+
+		// Case 4: Should fail with a truncated ciphertext
+		_, err = c.Decrypt(ciphertext[:c.NonceSize()+TagSize-1], ad)
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err), alg)
+
+		// Case 5: Should fail with another key
+		other, _ := NewKey(alg)
+		c2, _ := NewFromKey(other)
+		ciphertext[len(ciphertext)-1] ^= 1
+		_, err = c2.Decrypt(ciphertext, ad)
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err), alg)
+	}
+
+	// Case 6: Should fail with a key of another type
+	_, err := NewFromKey(keys.New(make([]byte, KeySize), keys.C25519))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestGCMSIV(t *testing.T) {
+	// Setup
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		assert.Nil(t, err)
+		return b
+	}
+
+	// Case 1: Should match the POLYVAL example of RFC 8452 Appendix A
+	p := newPolyval(decode("25629347589242761d31f826ba4b757b"))
+	p.update(decode("4f4f95668c83dfb6401762bb2d01a262d1a24ddd2721d006bbe45f20d3c9f362"))
+	sum := p.sum()
+	assert.Equal(t, "f7a3b47b846119fae5b7866cf5e5b77e", hex.EncodeToString(sum[:]))
+
+	// Case 2: Should match the AES-256-GCM-SIV vectors of RFC 8452 Appendix C.2
+	c, err := New(AES256GCMSIV, decode("0100000000000000000000000000000000000000000000000000000000000000"))
+	assert.Nil(t, err)
+	nonce := decode("030000000000000000000000")
+
+	vectors := []struct {
+		plaintext string
+		ad        string
+		result    string
+	}{
+		{"", "", "07f5f4169bbf55a8400cd47ea6fd400f"},
+		{"0100000000000000", "", "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28"},
+		{"010000000000000000000000", "", "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e"},
+		{"01000000000000000000000000000000", "", "85a01b63025ba19b7fd3ddfc033b3e76c9eac6fa700942702e90862383c6c366"},
+		{"0100000000000000000000000000000002000000000000000000000000000000", "", "4a6a9db4c8c6549201b9edb53006cba821ec9cf850948a7c86c68ac7539d027fe819e63abcd020b006a976397632eb5d"},
+		{"010000000000000000000000000000000200000000000000000000000000000003000000000000000000000000000000", "", "c00d121893a9fa603f48ccc1ca3c57ce7499245ea0046db16c53c7c66fe717e39cf6c748837b61f6ee3adcee17534ed5790bc96880a99ba804bd12c0e6a22cc4"},
+		{"0200000000000000", "01", "1de22967237a813291213f267e3b452f02d01ae33e4ec854"},
+		{"020000000000000000000000", "01", "163d6f9cc1b346cd453a2e4cc1a4a19ae800941ccdc57cc8413c277f"},
+		{"02000000000000000000000000000000", "01", "c91545823cc24f17dbb0e9e807d5ec17b292d28ff61189e8e49f3875ef91aff7"},
+		{"0200000000000000000000000000000003000000000000000000000000000000", "01", "07dad364bfc2b9da89116d7bef6daaaf6f255510aa654f920ac81b94e8bad365aea1bad12702e1965604374aab96dbbc"},
+		{"020000000000000000000000000000000300000000000000000000000000000004000000000000000000000000000000", "01", "c67a1f0f567a5198aa1fcc8e3f21314336f7f51ca8b1af61feac35a86416fa47fbca3b5f749cdf564527f2314f42fe2503332742b228c647173616cfd44c54eb"},
+		{"02000000", "010000000000000000000000", "22b3f4cd1835e517741dfddccfa07fa4661b74cf"},
+		{"0300000000000000000000000000000004000000", "010000000000000000000000000000000200", "43dd0163cdb48f9fe3212bf61b201976067f342bb879ad976d8242acc188ab59cabfe307"},
+	}
+	for _, v := range vectors {
+		result := c.Seal(nil, nonce, decode(v.plaintext), decode(v.ad))
+		assert.Equal(t, v.result, hex.EncodeToString(result))
+
+		plaintext, err := c.Open(nil, nonce, result, decode(v.ad))
+		assert.Nil(t, err)
+		assert.Equal(t, v.plaintext, hex.EncodeToString(plaintext))
+
+		if v.ad != "" {
+			_, err = c.Open(nil, nonce, result, nil)
+			assert.NotNil(t, err)
+		}
+	}
+
+	// Case 3: Should produce the same ciphertext when a nonce is repeated
+	a := c.Seal(nil, nonce, []byte("message"), []byte("ad"))
+	b := c.Seal(nil, nonce, []byte("message"), []byte("ad"))
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c.Seal(nil, nonce, []byte("massage"), []byte("ad")))
+}
@@ -0,0 +1,299 @@
+package aead
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// An envelope is encoded as the version byte, the algorithm ID, a flags byte,
+// the length of the key ID and the key ID, the SHA-256 hash of the associated
+// data when the flag is set, which is opt-in, the nonce and the ciphertext. Everything before
+// the ciphertext is authenticated together with the associated data
+const (
+	envelopeVersion   byte = 1
+	envelopeMaxKeyID       = 255
+	envelopeFixedSize      = 4
+	envelopeFlagAAD   byte = 1
+)
+
+// algorithmIDs are the identifiers of the algorithms in an envelope. They must
+// never change once assigned
+var algorithmIDs = map[Algorithm]byte{
+	XChaCha20Poly1305: 1,
+	ChaCha20Poly1305:  2,
+	AES256GCM:         3,
+	AES256GCMSIV:      4,
+}
+
+var (
+	// ErrEnvelopeMalformed is returned when an envelope can not be decoded
+	ErrEnvelopeMalformed = ez.New("aead.ParseEnvelope", ez.EINVALID, "Envelope is malformed", nil)
+	// ErrEnvelopeUnknownKey is returned when the envelope key ID is not in the KeySet
+	ErrEnvelopeUnknownKey = ez.New("aead.KeySet.OpenEnvelope", ez.ENOTFOUND, "Envelope was encrypted with an unknown key", nil)
+	// ErrEnvelopeAADMismatch is returned when the associated data does not
+	// match the hash recorded in the envelope
+	ErrEnvelopeAADMismatch = ez.New("aead.Envelope.Open", ez.EINVALID, "Envelope was encrypted with other associated data", nil)
+)
+
+// Envelope represents a self describing ciphertext
+type Envelope struct {
+	Version    byte
+	Algorithm  Algorithm
+	KeyID      string
+	AADHash    []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// KeySet represents a set of keys that can open envelopes, indexed by key ID
+type KeySet map[string]*keys.Key
+
+// SealEnvelope encrypts the plaintext with a random nonce into an envelope
+// that records the algorithm of the cipher and the key ID. The associated data
+// is authenticated but not recorded
+func SealEnvelope(c AEAD, keyID string, plaintext, associatedData []byte) (*Envelope, error) {
+	const op = "aead.SealEnvelope"
+
+	e, err := sealEnvelope(op, c, keyID, plaintext, associatedData, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// SealEnvelopeWithAADHash is SealEnvelope that also records the SHA-256 hash of
+// the associated data, so a mismatch is reported before decrypting. The hash is
+// readable by anyone, so it must not be used with guessable associated data
+// such as record IDs
+func SealEnvelopeWithAADHash(c AEAD, keyID string, plaintext, associatedData []byte) (*Envelope, error) {
+	const op = "aead.SealEnvelopeWithAADHash"
+
+	e, err := sealEnvelope(op, c, keyID, plaintext, associatedData, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func sealEnvelope(op string, c AEAD, keyID string, plaintext, associatedData []byte, hashAAD bool) (*Envelope, error) {
+	if c == nil {
+		return nil, ez.New(op, ez.EINVALID, "AEAD can not be nil", nil)
+	} else if keyID == "" || len(keyID) > envelopeMaxKeyID {
+		return nil, ez.New(op, ez.EINVALID, "KeyID must be between 1 and 255 bytes long", nil)
+	} else if _, ok := algorithmIDs[c.Algorithm()]; !ok {
+		return nil, ez.New(op, ez.EINVALID, "Unsupported AEAD algorithm "+string(c.Algorithm()), nil)
+	}
+
+	e := &Envelope{
+		Version:   envelopeVersion,
+		Algorithm: c.Algorithm(),
+		KeyID:     keyID,
+		Nonce:     make([]byte, c.NonceSize()),
+	}
+
+	if hashAAD {
+		h := sha256.Sum256(associatedData)
+		e.AADHash = h[:]
+	}
+
+	_, err := io.ReadFull(randReader, e.Nonce)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the nonce", err)
+	}
+
+	e.Ciphertext = c.Seal(nil, e.Nonce, plaintext, e.authenticatedData(associatedData))
+
+	return e, nil
+}
+
+// ParseEnvelope decodes an envelope from its binary encoding
+func ParseEnvelope(b []byte) (*Envelope, error) {
+	if len(b) < envelopeFixedSize || b[0] != envelopeVersion {
+		return nil, ErrEnvelopeMalformed
+	}
+
+	e := &Envelope{Version: b[0]}
+	for alg, id := range algorithmIDs {
+		if id == b[1] {
+			e.Algorithm = alg
+		}
+	}
+
+	flags := b[2]
+	kidLen := int(b[3])
+	b = b[envelopeFixedSize:]
+
+	if e.Algorithm == "" || flags&^envelopeFlagAAD != 0 || kidLen == 0 || len(b) < kidLen {
+		return nil, ErrEnvelopeMalformed
+	}
+	e.KeyID = string(b[:kidLen])
+	b = b[kidLen:]
+
+	if flags&envelopeFlagAAD != 0 {
+		if len(b) < sha256.Size {
+			return nil, ErrEnvelopeMalformed
+		}
+		e.AADHash = append([]byte{}, b[:sha256.Size]...)
+		b = b[sha256.Size:]
+	}
+
+	nonceSize := nonceSize(e.Algorithm)
+	if len(b) < nonceSize+TagSize {
+		return nil, ErrEnvelopeMalformed
+	}
+	e.Nonce = append([]byte{}, b[:nonceSize]...)
+	e.Ciphertext = append([]byte{}, b[nonceSize:]...)
+
+	return e, nil
+}
+
+// ParseEnvelopeString decodes an envelope from its text encoding
+func ParseEnvelopeString(s string) (*Envelope, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrEnvelopeMalformed
+	}
+
+	return ParseEnvelope(b)
+}
+
+// Bytes returns the binary encoding of the envelope
+func (e *Envelope) Bytes() []byte {
+	return append(e.header(), e.Ciphertext...)
+}
+
+// String returns the text encoding of the envelope, the binary encoding in
+// unpadded base64url
+func (e *Envelope) String() string {
+	return base64.RawURLEncoding.EncodeToString(e.Bytes())
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+func (e *Envelope) MarshalBinary() ([]byte, error) {
+	return e.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (e *Envelope) UnmarshalBinary(b []byte) error {
+	parsed, err := ParseEnvelope(b)
+	if err != nil {
+		return err
+	}
+
+	*e = *parsed
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (e *Envelope) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (e *Envelope) UnmarshalText(b []byte) error {
+	parsed, err := ParseEnvelopeString(string(b))
+	if err != nil {
+		return err
+	}
+
+	*e = *parsed
+	return nil
+}
+
+// Open decrypts the envelope with a key, using the algorithm recorded in the
+// envelope
+func (e *Envelope) Open(key []byte, associatedData []byte) ([]byte, error) {
+	const op = "aead.Envelope.Open"
+
+	if e.AADHash != nil {
+		h := sha256.Sum256(associatedData)
+		if subtle.ConstantTimeCompare(h[:], e.AADHash) != 1 {
+			return nil, ErrEnvelopeAADMismatch
+		}
+	}
+
+	c, err := New(e.Algorithm, key)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if len(e.Nonce) != c.NonceSize() {
+		return nil, ErrEnvelopeMalformed
+	}
+
+	plaintext, err := c.Open(nil, e.Nonce, e.Ciphertext, e.authenticatedData(associatedData))
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not open Envelope, invalid envelope or credentials", nil)
+	}
+
+	return plaintext, nil
+}
+
+// Open decodes a binary envelope and decrypts it with the key selected by its
+// key ID
+func (ks KeySet) Open(b []byte, associatedData []byte) ([]byte, error) {
+	e, err := ParseEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.OpenEnvelope(e, associatedData)
+}
+
+// OpenString decodes a text envelope and decrypts it with the key selected by
+// its key ID
+func (ks KeySet) OpenString(s string, associatedData []byte) ([]byte, error) {
+	e, err := ParseEnvelopeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.OpenEnvelope(e, associatedData)
+}
+
+// OpenEnvelope decrypts an envelope with the key selected by its key ID. A key
+// whose type is an AEAD algorithm only opens envelopes of that algorithm
+func (ks KeySet) OpenEnvelope(e *Envelope, associatedData []byte) ([]byte, error) {
+	const op = "aead.KeySet.OpenEnvelope"
+
+	key, ok := ks[e.KeyID]
+	if !ok || key == nil {
+		return nil, ErrEnvelopeUnknownKey
+	} else if alg := Algorithm(key.Type); alg.Valid() && alg != e.Algorithm {
+		return nil, ez.New(op, ez.EINVALID, "Envelope algorithm does not match the key", nil)
+	}
+
+	return e.Open(key.Value, associatedData)
+}
+
+func (e *Envelope) header() []byte {
+	var flags byte
+	if e.AADHash != nil {
+		flags |= envelopeFlagAAD
+	}
+
+	h := make([]byte, 0, envelopeFixedSize+len(e.KeyID)+len(e.AADHash)+len(e.Nonce))
+	h = append(h, e.Version, algorithmIDs[e.Algorithm], flags, byte(len(e.KeyID)))
+	h = append(h, e.KeyID...)
+	h = append(h, e.AADHash...)
+	h = append(h, e.Nonce...)
+
+	return h
+}
+
+func (e *Envelope) authenticatedData(associatedData []byte) []byte {
+	return append(e.header(), associatedData...)
+}
+
+func nonceSize(alg Algorithm) int {
+	if alg == XChaCha20Poly1305 {
+		return chacha20poly1305.NonceSizeX
+	}
+	return chacha20poly1305.NonceSize
+}
@@ -0,0 +1,98 @@
+package aead
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+)
+
+func TestEnvelope(t *testing.T) {
+	// Setup
+	oldKey, _ := NewKey(AES256GCM)
+	newKey, _ := NewKey(XChaCha20Poly1305)
+	ks := KeySet{"2023-q4": oldKey, "2024-q1": newKey}
+	ad := []byte("record:42")
+
+	oldCipher, _ := NewFromKey(oldKey)
+	newCipher, _ := NewFromKey(newKey)
+
+	// Case 1: Should open envelopes of every key and algorithm by their header
+	old, err := SealEnvelopeWithAADHash(oldCipher, "2023-q4", []byte("old secret"), ad)
+	assert.Nil(t, err)
+	assert.Equal(t, AES256GCM, old.Algorithm)
+	assert.Len(t, old.AADHash, 32)
+
+	current, err := SealEnvelope(newCipher, "2024-q1", []byte("new secret"), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, current.AADHash)
+
+	plaintext, err := ks.Open(old.Bytes(), ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("old secret"), plaintext)
+
+	plaintext, err = ks.OpenString(current.String(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new secret"), plaintext)
+
+	// Case 2: Should round trip the binary, text and JSON encodings
+	parsed, err := ParseEnvelope(old.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, old, parsed)
+
+	parsed, err = ParseEnvelopeString(old.String())
+	assert.Nil(t, err)
+	assert.Equal(t, old, parsed)
+
+	b, err := json.Marshal(struct{ Data *Envelope }{old})
+	assert.Nil(t, err)
+	var decoded struct{ Data *Envelope }
+	assert.Nil(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, old, decoded.Data)
+
+	// Case 3: Should report mismatching associated data before decrypting
+	_, err = ks.Open(old.Bytes(), []byte("record:43"))
+	assert.Equal(t, ErrEnvelopeAADMismatch, err)
+
+	// Case 4: Should not record the associated data hash unless asked
+	hidden, err := SealEnvelope(newCipher, "2024-q1", []byte("new secret"), ad)
+	assert.Nil(t, err)
+	assert.Nil(t, hidden.AADHash)
+	assert.Equal(t, byte(0), hidden.Bytes()[2])
+
+	plaintext, err = ks.Open(hidden.Bytes(), ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new secret"), plaintext)
+
+	_, err = ks.Open(hidden.Bytes(), []byte("record:43"))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	assert.NotEqual(t, ErrEnvelopeAADMismatch, err)
+
+	// Case 5: Should fail with an unknown key ID
+	_, err = KeySet{"2024-q1": newKey}.Open(old.Bytes(), ad)
+	assert.Equal(t, ErrEnvelopeUnknownKey, err)
+
+	// Case 6: Should fail when the header is modified
+	modified := old.Bytes()
+	modified[1] = algorithmIDs[AES256GCMSIV]
+	_, err = KeySet{"2023-q4": keys.New(oldKey.Value, keys.C25519)}.Open(modified, ad)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 7: Should fail when the key type does not match the algorithm
+	_, err = ks.Open(modified, ad)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 8: Should fail with malformed envelopes
+	for _, b := range [][]byte{nil, {2, 1, 0, 1, 'a'}, {1, 9, 0, 1, 'a'}, old.Bytes()[:20]} {
+		_, err = ParseEnvelope(b)
+		assert.Equal(t, ErrEnvelopeMalformed, err)
+	}
+	_, err = ParseEnvelopeString("not base64!")
+	assert.Equal(t, ErrEnvelopeMalformed, err)
+
+	// Case 9: Should fail with an invalid key ID
+	_, err = SealEnvelope(newCipher, "", []byte("secret"), nil)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
@@ -0,0 +1,227 @@
+package aead
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// AES-GCM-SIV as specified in RFC 8452. The nonce and the key derive a per
+// message authentication key and encryption key, the tag is computed with
+// POLYVAL over the associated data and the plaintext and is used as the
+// initial counter, so the ciphertext depends on the whole message
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVBlockSize = 16
+	gcmSIVMaxInput  = 1 << 36
+)
+
+var errGCMSIVOpen = errors.New("aead: message authentication failed")
+
+type gcmSIV struct {
+	block cipher.Block
+}
+
+type polyval struct {
+	hLo, hHi uint64
+	lo, hi   uint64
+}
+
+func newGCMSIV(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcmSIV{block: block}, nil
+}
+
+func (g *gcmSIV) NonceSize() int {
+	return gcmSIVNonceSize
+}
+
+func (g *gcmSIV) Overhead() int {
+	return TagSize
+}
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("aead: incorrect nonce length given to AES-GCM-SIV")
+	} else if uint64(len(plaintext)) > gcmSIVMaxInput || uint64(len(additionalData)) > gcmSIVMaxInput {
+		panic("aead: message too large for AES-GCM-SIV")
+	}
+
+	authKey, encBlock := g.deriveKeys(nonce)
+	tag := g.tag(authKey, encBlock, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+TagSize)
+	ctr(encBlock, tag, out[:len(plaintext)], plaintext)
+	copy(out[len(plaintext):], tag[:])
+
+	return ret
+}
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("aead: incorrect nonce length given to AES-GCM-SIV")
+	} else if len(ciphertext) < TagSize || uint64(len(ciphertext)) > gcmSIVMaxInput+TagSize {
+		return nil, errGCMSIVOpen
+	} else if uint64(len(additionalData)) > gcmSIVMaxInput {
+		return nil, errGCMSIVOpen
+	}
+
+	var tag [gcmSIVBlockSize]byte
+	copy(tag[:], ciphertext[len(ciphertext)-TagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-TagSize]
+
+	authKey, encBlock := g.deriveKeys(nonce)
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	ctr(encBlock, tag, out, ciphertext)
+
+	expected := g.tag(authKey, encBlock, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errGCMSIVOpen
+	}
+
+	return ret, nil
+}
+
+// deriveKeys returns the message authentication key and the message
+// encryption cipher, built from the first half of AES(key, counter || nonce)
+func (g *gcmSIV) deriveKeys(nonce []byte) ([gcmSIVBlockSize]byte, cipher.Block) {
+	var authKey [gcmSIVBlockSize]byte
+	var encKey [KeySize]byte
+	var in, out [gcmSIVBlockSize]byte
+	copy(in[4:], nonce)
+
+	for i := uint32(0); i < 6; i++ {
+		binary.LittleEndian.PutUint32(in[:4], i)
+		g.block.Encrypt(out[:], in[:])
+		if i < 2 {
+			copy(authKey[i*8:], out[:8])
+		} else {
+			copy(encKey[(i-2)*8:], out[:8])
+		}
+	}
+
+	// The key has the correct length so NewCipher can not fail
+	encBlock, _ := aes.NewCipher(encKey[:])
+
+	return authKey, encBlock
+}
+
+func (g *gcmSIV) tag(authKey [gcmSIVBlockSize]byte, encBlock cipher.Block, nonce, plaintext, additionalData []byte) [gcmSIVBlockSize]byte {
+	var lengths [gcmSIVBlockSize]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+
+	p := newPolyval(authKey[:])
+	p.update(additionalData)
+	p.update(plaintext)
+	p.update(lengths[:])
+
+	s := p.sum()
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[gcmSIVBlockSize-1] &= 0x7f
+
+	var tag [gcmSIVBlockSize]byte
+	encBlock.Encrypt(tag[:], s[:])
+
+	return tag
+}
+
+// ctr encrypts with AES-CTR using the tag with its top bit set as the initial
+// counter block. Only the first 32 bits are incremented, as little endian
+func ctr(block cipher.Block, tag [gcmSIVBlockSize]byte, dst, src []byte) {
+	counter := tag
+	counter[gcmSIVBlockSize-1] |= 0x80
+	n := binary.LittleEndian.Uint32(counter[:4])
+
+	var stream [gcmSIVBlockSize]byte
+	for len(src) > 0 {
+		block.Encrypt(stream[:], counter[:])
+		n++
+		binary.LittleEndian.PutUint32(counter[:4], n)
+
+		l := len(src)
+		if l > gcmSIVBlockSize {
+			l = gcmSIVBlockSize
+		}
+		subtle.XORBytes(dst[:l], src[:l], stream[:l])
+		dst, src = dst[l:], src[l:]
+	}
+}
+
+func newPolyval(h []byte) *polyval {
+	return &polyval{
+		hLo: binary.LittleEndian.Uint64(h[:8]),
+		hHi: binary.LittleEndian.Uint64(h[8:]),
+	}
+}
+
+// update absorbs data in 16 byte blocks, padding the last one with zeros
+func (p *polyval) update(data []byte) {
+	for len(data) > 0 {
+		var block [gcmSIVBlockSize]byte
+		n := copy(block[:], data)
+		data = data[n:]
+
+		p.lo ^= binary.LittleEndian.Uint64(block[:8])
+		p.hi ^= binary.LittleEndian.Uint64(block[8:])
+		p.lo, p.hi = polyvalDot(p.lo, p.hi, p.hLo, p.hHi)
+	}
+}
+
+func (p *polyval) sum() [gcmSIVBlockSize]byte {
+	var s [gcmSIVBlockSize]byte
+	binary.LittleEndian.PutUint64(s[:8], p.lo)
+	binary.LittleEndian.PutUint64(s[8:], p.hi)
+	return s
+}
+
+// polyvalDot returns a * b * x^-128 in the POLYVAL field, where bit i of the
+// little endian element is the coefficient of x^i and the field polynomial is
+// x^128 + x^127 + x^126 + x^121 + 1. Each bit of b is added with Horner's rule
+// from the lowest one, dividing by x after each step. Division by x adds the
+// polynomial when the lowest bit is set and shifts right, so the loop runs in
+// constant time with masks instead of branches
+func polyvalDot(aLo, aHi, bLo, bHi uint64) (uint64, uint64) {
+	var lo, hi uint64
+
+	for i := uint(0); i < 128; i++ {
+		var bit uint64
+		if i < 64 {
+			bit = (bLo >> i) & 1
+		} else {
+			bit = (bHi >> (i - 64)) & 1
+		}
+		mask := -bit
+		lo ^= aLo & mask
+		hi ^= aHi & mask
+
+		reduce := -(lo & 1)
+		lo = lo>>1 | hi<<63
+		hi = hi>>1 ^ reduce&0xe100000000000000
+	}
+
+	return lo, hi
+}
+
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,202 @@
+package age
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// A file is the header, a 16 byte nonce and the payload. The file key is
+// wrapped for every recipient in the header, which is authenticated with an
+// HMAC keyed from the file key. The payload key is derived from the file key
+// and the nonce
+const (
+	fileKeySize  = 16
+	nonceSize    = 16
+	headerLabel  = "header"
+	payloadLabel = "payload"
+)
+
+// randReader is the source of randomness used for file keys, nonces and
+// ephemeral keys
+var randReader io.Reader = rand.Reader
+
+// ErrIncorrectIdentity is returned when no identity can unwrap the file key
+var ErrIncorrectIdentity = ez.New("age.Decrypt", ez.ENOTAUTHORIZED, "No identity matched any of the recipients", nil)
+
+// Recipient wraps the file key of a file for one or more stanzas
+type Recipient interface {
+	Wrap(fileKey []byte) ([]*Stanza, error)
+}
+
+// Identity unwraps the file key from the stanzas of a file. It returns
+// ErrIncorrectIdentity if none of the stanzas are addressed to it
+type Identity interface {
+	Unwrap(stanzas []*Stanza) ([]byte, error)
+}
+
+// Encrypt writes the header of a file encrypted to the recipients to dst and
+// returns a writer for the plaintext. Close must be called to write the final
+// chunk, it does not close dst
+func Encrypt(dst io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	const op = "age.Encrypt"
+
+	if len(recipients) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "At least one recipient is required", nil)
+	}
+	for _, r := range recipients {
+		if _, ok := r.(*ScryptRecipient); ok && len(recipients) != 1 {
+			return nil, ez.New(op, ez.EINVALID, "A ScryptRecipient can not be combined with other recipients", nil)
+		}
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	_, err := io.ReadFull(randReader, fileKey)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the file key", err)
+	}
+	defer keys.Wipe(fileKey)
+
+	h := &header{}
+	for _, r := range recipients {
+		stanzas, err := r.Wrap(fileKey)
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		h.stanzas = append(h.stanzas, stanzas...)
+	}
+
+	var buf bytes.Buffer
+	err = h.marshalWithoutMAC(&buf)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	buf.WriteString(" " + b64.EncodeToString(headerMAC(fileKey, buf.Bytes())) + "\n")
+
+	nonce := make([]byte, nonceSize)
+	_, err = io.ReadFull(randReader, nonce)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the nonce", err)
+	}
+	buf.Write(nonce)
+
+	_, err = dst.Write(buf.Bytes())
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while writing the header", err)
+	}
+
+	payloadKey := hkdfKey(fileKey, nonce, payloadLabel, chacha20poly1305.KeySize)
+	defer keys.Wipe(payloadKey)
+
+	w, err := newStreamWriter(payloadKey, dst)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return w, nil
+}
+
+// Decrypt reads the header of a file from src, unwraps the file key with the
+// first matching identity and returns a reader for the plaintext. Errors in
+// the payload are returned by the reader
+func Decrypt(src io.Reader, identities ...Identity) (io.Reader, error) {
+	const op = "age.Decrypt"
+
+	if len(identities) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "At least one identity is required", nil)
+	}
+
+	h, payload, err := parseHeader(src)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	for _, s := range h.stanzas {
+		if s.Type == scryptType && len(h.stanzas) != 1 {
+			return nil, ez.New(op, ez.EINVALID, "An scrypt stanza must be the only one in the file", nil)
+		}
+	}
+
+	var fileKey []byte
+	for _, i := range identities {
+		fileKey, err = i.Unwrap(h.stanzas)
+		if err == ErrIncorrectIdentity {
+			continue
+		} else if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		break
+	}
+	if fileKey == nil {
+		return nil, ErrIncorrectIdentity
+	}
+	defer keys.Wipe(fileKey)
+
+	if len(fileKey) != fileKeySize {
+		return nil, ez.New(op, ez.EINVALID, "File key has an invalid length", nil)
+	} else if !hmac.Equal(headerMAC(fileKey, h.raw), h.mac) {
+		return nil, ez.New(op, ez.EINVALID, "Header has an invalid MAC", nil)
+	}
+
+	nonce := make([]byte, nonceSize)
+	_, err = io.ReadFull(payload, nonce)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Payload nonce is truncated", err)
+	}
+
+	payloadKey := hkdfKey(fileKey, nonce, payloadLabel, chacha20poly1305.KeySize)
+	defer keys.Wipe(payloadKey)
+
+	r, err := newStreamReader(payloadKey, payload)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return r, nil
+}
+
+func headerMAC(fileKey, header []byte) []byte {
+	key := hkdfKey(fileKey, nil, headerLabel, sha256.Size)
+	defer keys.Wipe(key)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+// aeadEncrypt seals a file key with ChaCha20-Poly1305 and a zero nonce, each
+// wrap key is only used once
+func aeadEncrypt(key, plaintext []byte) ([]byte, error) {
+	const op = "age.aeadEncrypt"
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while creating the cipher", err)
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	return aead.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func aeadDecrypt(key, ciphertext []byte) ([]byte, error) {
+	const op = "age.aeadDecrypt"
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while creating the cipher", err)
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not unwrap the file key", nil)
+	}
+
+	return plaintext, nil
+}
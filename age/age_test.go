@@ -0,0 +1,314 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/nacl"
+)
+
+// testVector is a file of the age testkit, c2sp.org/CCTV/age
+type testVector struct {
+	expect      string
+	payloadHash string
+	identities  []Identity
+	armored     bool
+	file        []byte
+}
+
+func parseTestVector(t *testing.T, path string) *testVector {
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	v := &testVector{}
+	compressed := false
+	r := bufio.NewReader(bytes.NewReader(b))
+	for {
+		line, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+
+		kv := strings.SplitN(line, ": ", 2)
+		switch kv[0] {
+		case "expect":
+			v.expect = kv[1]
+		case "payload":
+			v.payloadHash = kv[1]
+		case "identity":
+			i, err := ParseX25519Identity(kv[1])
+			assert.Nil(t, err)
+			v.identities = append(v.identities, i)
+		case "passphrase":
+			i, err := NewScryptIdentity(kv[1])
+			assert.Nil(t, err)
+			v.identities = append(v.identities, i)
+		case "armored":
+			v.armored = kv[1] == "yes"
+		case "compressed":
+			compressed = kv[1] == "zlib"
+		}
+	}
+
+	v.file, err = io.ReadAll(r)
+	assert.Nil(t, err)
+	if compressed {
+		zr, err := zlib.NewReader(bytes.NewReader(v.file))
+		assert.Nil(t, err)
+		v.file, err = io.ReadAll(zr)
+		assert.Nil(t, err)
+	}
+
+	return v
+}
+
+func TestTestkit(t *testing.T) {
+	paths, err := filepath.Glob("testdata/testkit/*")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		v := parseTestVector(t, path)
+
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			var in io.Reader = bytes.NewReader(v.file)
+			if v.armored {
+				in = NewArmorReader(in)
+			}
+
+			r, err := Decrypt(in, v.identities...)
+			switch v.expect {
+			case "success":
+				assert.Nil(t, err)
+				out, err := io.ReadAll(r)
+				assert.Nil(t, err)
+				sum := sha256.Sum256(out)
+				assert.Equal(t, v.payloadHash, hex.EncodeToString(sum[:]))
+			case "payload failure":
+				assert.Nil(t, err)
+				_, err = io.ReadAll(r)
+				assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+			case "header failure", "HMAC failure":
+				assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+			case "no match":
+				assert.Equal(t, ErrIncorrectIdentity, err)
+			case "armor failure":
+				if err == nil {
+					_, err = io.ReadAll(r)
+				}
+				assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+			default:
+				t.Fatalf("unknown expectation %q", v.expect)
+			}
+		})
+	}
+}
+
+func encryptToBuffer(t *testing.T, plaintext []byte, recipients ...Recipient) []byte {
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, recipients...)
+	assert.Nil(t, err)
+	_, err = w.Write(plaintext)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func decryptBuffer(file []byte, identities ...Identity) ([]byte, error) {
+	r, err := Decrypt(bytes.NewReader(file), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	// Setup
+	alice, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+	bob, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+	eve, err := GenerateX25519Identity()
+	assert.Nil(t, err)
+
+	// Case 1: Should decrypt payloads around the chunk boundaries
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 2 * chunkSize} {
+		plaintext := bytes.Repeat([]byte{'a'}, size)
+		file := encryptToBuffer(t, plaintext, alice.Recipient())
+
+		out, err := decryptBuffer(file, alice)
+		assert.Nil(t, err)
+		assert.Equal(t, plaintext, out)
+	}
+
+	// Case 2: Should decrypt with any of multiple recipients
+	file := encryptToBuffer(t, []byte("hello"), alice.Recipient(), bob.Recipient())
+	for _, i := range []Identity{alice, bob} {
+		out, err := decryptBuffer(file, i)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("hello"), out)
+	}
+
+	// Case 3: Should try every identity
+	out, err := decryptBuffer(file, eve, bob)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), out)
+
+	// Case 4: Should fail with an identity that is not a recipient
+	_, err = decryptBuffer(file, eve)
+	assert.Equal(t, ErrIncorrectIdentity, err)
+
+	// Case 5: Should fail when the header is modified
+	modified := bytes.Replace(file, []byte("X25519"), []byte("X25518"), 1)
+	_, err = decryptBuffer(modified, alice)
+	assert.NotNil(t, err)
+
+	// Case 6: Should fail when the payload is truncated
+	_, err = decryptBuffer(file[:len(file)-1], alice)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 7: Should require recipients and identities
+	_, err = Encrypt(&bytes.Buffer{})
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = Decrypt(bytes.NewReader(file))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestScrypt(t *testing.T) {
+	// Setup
+	recipient, err := NewScryptRecipient("correct horse battery staple")
+	assert.Nil(t, err)
+	assert.Nil(t, recipient.SetWorkFactor(10))
+
+	identity, err := NewScryptIdentity("correct horse battery staple")
+	assert.Nil(t, err)
+
+	// Case 1: Should decrypt with the passphrase
+	file := encryptToBuffer(t, []byte("hello"), recipient)
+	out, err := decryptBuffer(file, identity)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), out)
+
+	// Case 2: Should fail with another passphrase
+	wrong, _ := NewScryptIdentity("wrong passphrase")
+	_, err = decryptBuffer(file, wrong)
+	assert.Equal(t, ErrIncorrectIdentity, err)
+
+	// Case 3: Should reject work factors above the maximum
+	assert.Nil(t, identity.SetMaxWorkFactor(9))
+	_, err = decryptBuffer(file, identity)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should not combine a passphrase with other recipients
+	other, _ := GenerateX25519Identity()
+	_, err = Encrypt(&bytes.Buffer{}, recipient, other.Recipient())
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should reject invalid parameters
+	_, err = NewScryptRecipient("")
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(recipient.SetWorkFactor(31)))
+}
+
+func TestArmor(t *testing.T) {
+	// Setup
+	identity, _ := GenerateX25519Identity()
+
+	// Case 1: Should decrypt an armored file
+	for _, size := range []int{0, 10, bytesPerLine, 1000} {
+		plaintext := bytes.Repeat([]byte{'a'}, size)
+
+		var buf bytes.Buffer
+		aw := NewArmorWriter(&buf)
+		w, err := Encrypt(aw, identity.Recipient())
+		assert.Nil(t, err)
+		_, err = w.Write(plaintext)
+		assert.Nil(t, err)
+		assert.Nil(t, w.Close())
+		assert.Nil(t, aw.Close())
+
+		assert.True(t, strings.HasPrefix(buf.String(), armorHeader+"\n"))
+		assert.True(t, strings.HasSuffix(buf.String(), armorFooter+"\n"))
+
+		r, err := Decrypt(NewArmorReader(&buf), identity)
+		assert.Nil(t, err)
+		out, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, plaintext, out)
+	}
+
+	// Case 2: Should round trip arbitrary data
+	var buf bytes.Buffer
+	aw := NewArmorWriter(&buf)
+	aw.Write([]byte("hello "))
+	aw.Write([]byte("world"))
+	assert.Nil(t, aw.Close())
+
+	out, err := io.ReadAll(NewArmorReader(&buf))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello world"), out)
+
+	// Case 3: Should fail without the end line
+	_, err = io.ReadAll(NewArmorReader(strings.NewReader(armorHeader + "\naGVsbG8=\n")))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestX25519Encoding(t *testing.T) {
+	// Setup
+	kp, err := nacl.NewKeyPair()
+	assert.Nil(t, err)
+	pub, err := kp.Public()
+	assert.Nil(t, err)
+
+	// Case 1: Should convert a NaCl key pair into an identity and back
+	identity, err := NewX25519Identity(kp)
+	assert.Nil(t, err)
+	assert.Equal(t, pub.Value, identity.Recipient().PublicKey().Value)
+
+	priv, _ := kp.Private()
+	converted, _ := identity.KeyPair().Private()
+	assert.Equal(t, priv.Value, converted.Value)
+
+	// Case 2: Should round trip the Bech32 encodings
+	s := identity.String()
+	assert.True(t, strings.HasPrefix(s, "AGE-SECRET-KEY-1"))
+	parsed, err := ParseX25519Identity(s)
+	assert.Nil(t, err)
+	assert.Equal(t, s, parsed.String())
+
+	r := identity.Recipient().String()
+	assert.True(t, strings.HasPrefix(r, "age1"))
+	recipient, err := ParseX25519Recipient(r)
+	assert.Nil(t, err)
+	assert.Equal(t, r, recipient.String())
+
+	// Case 3: Should encrypt to the NaCl public key
+	fromKey, err := NewX25519Recipient(pub)
+	assert.Nil(t, err)
+	assert.Equal(t, r, fromKey.String())
+
+	file := encryptToBuffer(t, []byte("hello"), fromKey)
+	out, err := decryptBuffer(file, parsed)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), out)
+
+	// Case 4: Should reject invalid encodings
+	_, err = ParseX25519Recipient(strings.ToUpper(r))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = ParseX25519Recipient(r[:len(r)-1] + "q")
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = ParseX25519Identity(r)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
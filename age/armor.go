@@ -0,0 +1,210 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+
+	"github.com/vanclief/ez"
+)
+
+// An armored file is the binary file in padded base64 wrapped at 64 columns
+// between a begin and an end line. Readers accept CRLF line endings and
+// whitespace around the armor, but nothing else
+const (
+	armorHeader        = "-----BEGIN AGE ENCRYPTED FILE-----"
+	armorFooter        = "-----END AGE ENCRYPTED FILE-----"
+	armorMaxWhitespace = 1024
+)
+
+var armorEncoding = base64.StdEncoding.Strict()
+
+type armorWriter struct {
+	w       io.Writer
+	buf     []byte
+	started bool
+	err     error
+}
+
+type armorReader struct {
+	r       *bufio.Reader
+	started bool
+	done    bool
+	plain   []byte
+	err     error
+}
+
+// NewArmorWriter returns a writer that armors everything written to it into
+// dst. Close must be called to write the end line, it does not close dst
+func NewArmorWriter(dst io.Writer) io.WriteCloser {
+	return &armorWriter{w: dst}
+}
+
+// NewArmorReader returns a reader that removes the armor of a file read from
+// src
+func NewArmorReader(src io.Reader) io.Reader {
+	return &armorReader{r: bufio.NewReader(src)}
+}
+
+func (a *armorWriter) Write(p []byte) (int, error) {
+	const op = "age.ArmorWriter.Write"
+
+	if a.err != nil {
+		return 0, a.err
+	}
+
+	a.buf = append(a.buf, p...)
+	var out bytes.Buffer
+	if !a.started {
+		out.WriteString(armorHeader + "\n")
+	}
+	for len(a.buf) > bytesPerLine {
+		out.WriteString(armorEncoding.EncodeToString(a.buf[:bytesPerLine]) + "\n")
+		a.buf = a.buf[bytesPerLine:]
+	}
+
+	_, err := a.w.Write(out.Bytes())
+	if err != nil {
+		a.err = ez.New(op, ez.EINTERNAL, "Error while writing the armor", err)
+		return 0, a.err
+	}
+	a.started = true
+
+	return len(p), nil
+}
+
+func (a *armorWriter) Close() error {
+	const op = "age.ArmorWriter.Close"
+
+	if a.err != nil {
+		return a.err
+	}
+
+	var out bytes.Buffer
+	if !a.started {
+		out.WriteString(armorHeader + "\n")
+	}
+	if len(a.buf) > 0 {
+		out.WriteString(armorEncoding.EncodeToString(a.buf) + "\n")
+	}
+	out.WriteString(armorFooter + "\n")
+
+	_, err := a.w.Write(out.Bytes())
+	if err != nil {
+		a.err = ez.New(op, ez.EINTERNAL, "Error while writing the armor", err)
+		return a.err
+	}
+
+	a.err = ez.New(op, ez.EINVALID, "ArmorWriter is closed", nil)
+	return nil
+}
+
+func (a *armorReader) Read(p []byte) (int, error) {
+	for len(a.plain) == 0 {
+		if a.err != nil {
+			return 0, a.err
+		} else if a.done {
+			return 0, io.EOF
+		}
+		a.err = a.next()
+	}
+
+	n := copy(p, a.plain)
+	a.plain = a.plain[n:]
+
+	return n, nil
+}
+
+// next decodes one line of the armor. It returns io.EOF after the end line
+func (a *armorReader) next() error {
+	const op = "age.ArmorReader.Read"
+
+	if !a.started {
+		skipped := 0
+		for {
+			line, err := a.readLine()
+			if err != nil {
+				return err
+			} else if len(bytes.TrimSpace(line)) > 0 {
+				if string(line) != armorHeader {
+					return ez.New(op, ez.EINVALID, "Armor has an invalid begin line", nil)
+				}
+				break
+			}
+
+			skipped += len(line) + 1
+			if skipped > armorMaxWhitespace {
+				return ez.New(op, ez.EINVALID, "Armor has too much leading whitespace", nil)
+			}
+		}
+		a.started = true
+	}
+
+	line, err := a.readLine()
+	if err != nil {
+		return err
+	} else if string(line) == armorFooter {
+		return a.trailing()
+	} else if len(line) == 0 || len(line) > columnsPerLine || bytes.ContainsAny(line, "\r\n") {
+		return ez.New(op, ez.EINVALID, "Armor has a malformed line", nil)
+	}
+
+	plain, err := armorEncoding.DecodeString(string(line))
+	if err != nil {
+		return ez.New(op, ez.EINVALID, "Armor has invalid base64", err)
+	}
+	a.plain = plain
+
+	if len(plain) < bytesPerLine {
+		line, err := a.readLine()
+		if err != nil {
+			return err
+		} else if string(line) != armorFooter {
+			return ez.New(op, ez.EINVALID, "Armor is missing the end line after a short line", nil)
+		}
+
+		err = a.trailing()
+		if err != io.EOF {
+			a.plain = nil
+			return err
+		}
+		// The last line is returned before io.EOF
+		a.done = true
+	}
+
+	return nil
+}
+
+// readLine returns the next line without its LF or CRLF ending
+func (a *armorReader) readLine() ([]byte, error) {
+	const op = "age.ArmorReader.Read"
+
+	line, err := a.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, ez.New(op, ez.EINVALID, "Armor has a line that is too long", nil)
+	} else if err == io.EOF && len(line) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Armor is truncated", nil)
+	} else if err != nil && err != io.EOF {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while reading the armor", err)
+	}
+
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+
+	return line, nil
+}
+
+// trailing checks that only whitespace follows the end line
+func (a *armorReader) trailing() error {
+	const op = "age.ArmorReader.Read"
+
+	rest, err := io.ReadAll(io.LimitReader(a.r, armorMaxWhitespace+1))
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while reading the armor", err)
+	} else if len(rest) > armorMaxWhitespace || len(bytes.TrimSpace(rest)) > 0 {
+		return ez.New(op, ez.EINVALID, "Armor has trailing data", nil)
+	}
+
+	return io.EOF
+}
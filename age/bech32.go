@@ -0,0 +1,133 @@
+package age
+
+import (
+	"strings"
+
+	"github.com/vanclief/ez"
+)
+
+// Keys are encoded with Bech32 as defined in BIP 173, without its 90
+// character limit. Recipients use lowercase and identities uppercase
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	b := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]>>5)
+	}
+	b = append(b, 0)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]&31)
+	}
+	return b
+}
+
+// convertBits regroups a slice of fromBits groups into toBits groups
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, bool) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, false
+		}
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, false
+	}
+
+	return out, true
+}
+
+// bech32Encode encodes data with a lowercase human readable part
+func bech32Encode(hrp string, data []byte) string {
+	values, _ := convertBits(data, 8, 5, true)
+
+	check := append(bech32HRPExpand(hrp), values...)
+	check = append(check, 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(check) ^ 1
+
+	var s strings.Builder
+	s.WriteString(hrp)
+	s.WriteByte('1')
+	for _, v := range values {
+		s.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		s.WriteByte(bech32Charset[(mod>>uint(5*(5-i)))&31])
+	}
+
+	return s.String()
+}
+
+// bech32Decode returns the lowercase human readable part and the data of a
+// Bech32 string, which must not mix cases
+func bech32Decode(s string) (string, []byte, error) {
+	const op = "age.bech32Decode"
+
+	lower := strings.ToLower(s)
+	if s != lower && s != strings.ToUpper(s) {
+		return "", nil, ez.New(op, ez.EINVALID, "Bech32 string mixes upper and lower case", nil)
+	}
+
+	pos := strings.LastIndexByte(lower, '1')
+	if pos < 1 || pos+7 > len(lower) {
+		return "", nil, ez.New(op, ez.EINVALID, "Bech32 string has an invalid separator position", nil)
+	}
+
+	hrp := lower[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, ez.New(op, ez.EINVALID, "Bech32 string has an invalid prefix", nil)
+		}
+	}
+
+	values := make([]byte, 0, len(lower)-pos-1)
+	for i := pos + 1; i < len(lower); i++ {
+		v := strings.IndexByte(bech32Charset, lower[i])
+		if v < 0 {
+			return "", nil, ez.New(op, ez.EINVALID, "Bech32 string has an invalid character", nil)
+		}
+		values = append(values, byte(v))
+	}
+
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, ez.New(op, ez.EINVALID, "Bech32 string has an invalid checksum", nil)
+	}
+
+	data, ok := convertBits(values[:len(values)-6], 5, 8, false)
+	if !ok {
+		return "", nil, ez.New(op, ez.EINVALID, "Bech32 string has invalid padding", nil)
+	}
+
+	return hrp, data, nil
+}
@@ -0,0 +1,259 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/vanclief/ez"
+)
+
+// The header is the intro line, one or more recipient stanzas and the MAC
+// line. A stanza is a "->" line with its type and arguments followed by its
+// body in unpadded base64, wrapped at 64 columns and ended by a shorter line
+const (
+	intro          = "age-encryption.org/v1\n"
+	stanzaPrefix   = "->"
+	footerPrefix   = "---"
+	columnsPerLine = 64
+	bytesPerLine   = columnsPerLine / 4 * 3
+
+	maxHeaderSize = 2 << 20
+	maxStanzas    = 1024
+	maxStanzaArgs = 128
+)
+
+var b64 = base64.RawStdEncoding.Strict()
+
+// Stanza represents a recipient stanza of the header, it holds the file key
+// wrapped for one recipient
+type Stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+type header struct {
+	stanzas []*Stanza
+	mac     []byte
+	// raw is the encoded header up to and including the "---" the MAC covers
+	raw []byte
+}
+
+// headerReader reads lines of the header and keeps a copy of them
+type headerReader struct {
+	r   *bufio.Reader
+	raw bytes.Buffer
+}
+
+func (h *header) marshalWithoutMAC(w io.Writer) error {
+	const op = "age.header.marshal"
+
+	_, err := io.WriteString(w, intro)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while writing the header", err)
+	}
+
+	for _, s := range h.stanzas {
+		err = s.marshal(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, footerPrefix)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while writing the header", err)
+	}
+
+	return nil
+}
+
+func (s *Stanza) marshal(w io.Writer) error {
+	const op = "age.Stanza.marshal"
+
+	if !isValidArg(s.Type) {
+		return ez.New(op, ez.EINVALID, "Stanza type "+s.Type+" is not valid", nil)
+	}
+
+	line := stanzaPrefix + " " + s.Type
+	for _, a := range s.Args {
+		if !isValidArg(a) {
+			return ez.New(op, ez.EINVALID, "Stanza argument "+a+" is not valid", nil)
+		}
+		line += " " + a
+	}
+
+	var b strings.Builder
+	b.WriteString(line + "\n")
+	writeWrapped(&b, b64.EncodeToString(s.Body), true)
+
+	_, err := io.WriteString(w, b.String())
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while writing the header", err)
+	}
+
+	return nil
+}
+
+// writeWrapped writes s in lines of 64 columns. If finalShortLine is true an
+// empty line is added when the last line is full, as stanza bodies require
+func writeWrapped(b *strings.Builder, s string, finalShortLine bool) {
+	for len(s) >= columnsPerLine {
+		b.WriteString(s[:columnsPerLine] + "\n")
+		s = s[columnsPerLine:]
+	}
+	if len(s) > 0 || finalShortLine {
+		b.WriteString(s + "\n")
+	}
+}
+
+// parseHeader reads the header and returns a reader with the rest of the file
+func parseHeader(src io.Reader) (*header, io.Reader, error) {
+	const op = "age.parseHeader"
+
+	br := bufio.NewReader(src)
+	hr := &headerReader{r: br}
+
+	line, err := hr.readLine()
+	if err != nil {
+		return nil, nil, ez.Wrap(op, err)
+	} else if line != intro {
+		return nil, nil, ez.New(op, ez.EINVALID, "File is not an age v1 file", nil)
+	}
+
+	h := &header{}
+	for {
+		peek, err := br.Peek(len(footerPrefix))
+		if err != nil {
+			return nil, nil, ez.New(op, ez.EINVALID, "Header is truncated", err)
+		}
+
+		if string(peek) == footerPrefix {
+			break
+		} else if len(h.stanzas) == maxStanzas {
+			return nil, nil, ez.New(op, ez.EINVALID, "Header has too many stanzas", nil)
+		}
+
+		s, err := hr.readStanza()
+		if err != nil {
+			return nil, nil, ez.Wrap(op, err)
+		}
+		h.stanzas = append(h.stanzas, s)
+	}
+
+	line, err = hr.readLine()
+	if err != nil {
+		return nil, nil, ez.Wrap(op, err)
+	}
+	h.raw = hr.raw.Bytes()[:hr.raw.Len()-len(line)+len(footerPrefix)]
+
+	args := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+	if len(args) != 2 || args[0] != footerPrefix {
+		return nil, nil, ez.New(op, ez.EINVALID, "Header has a malformed MAC line", nil)
+	}
+	h.mac, err = decodeBase64(args[1])
+	if err != nil || len(h.mac) != 32 {
+		return nil, nil, ez.New(op, ez.EINVALID, "Header has a malformed MAC", nil)
+	} else if len(h.stanzas) == 0 {
+		return nil, nil, ez.New(op, ez.EINVALID, "Header has no recipient stanzas", nil)
+	}
+
+	// Hand over what bufio already read together with the rest of src
+	buffered, _ := br.Peek(br.Buffered())
+	payload := io.MultiReader(bytes.NewReader(buffered), src)
+
+	return h, payload, nil
+}
+
+func (hr *headerReader) readLine() (string, error) {
+	const op = "age.headerReader.readLine"
+
+	var line []byte
+	for {
+		frag, err := hr.r.ReadSlice('\n')
+		if hr.raw.Len()+len(frag) > maxHeaderSize {
+			return "", ez.New(op, ez.EINVALID, "Header is larger than 2 MiB", nil)
+		}
+		hr.raw.Write(frag)
+		line = append(line, frag...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			return "", ez.New(op, ez.EINVALID, "Header is truncated", err)
+		}
+
+		return string(line), nil
+	}
+}
+
+func (hr *headerReader) readStanza() (*Stanza, error) {
+	const op = "age.headerReader.readStanza"
+
+	line, err := hr.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	args := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+	if len(args) < 2 || len(args) > maxStanzaArgs+2 || args[0] != stanzaPrefix {
+		return nil, ez.New(op, ez.EINVALID, "Stanza has a malformed first line", nil)
+	}
+	for _, a := range args[1:] {
+		if !isValidArg(a) {
+			return nil, ez.New(op, ez.EINVALID, "Stanza has an invalid argument", nil)
+		}
+	}
+
+	s := &Stanza{Type: args[1], Args: args[2:], Body: []byte{}}
+	for {
+		line, err := hr.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := decodeBase64(strings.TrimSuffix(line, "\n"))
+		if err != nil {
+			return nil, ez.New(op, ez.EINVALID, "Stanza has a malformed body line", nil)
+		} else if len(b) > bytesPerLine {
+			return nil, ez.New(op, ez.EINVALID, "Stanza has a body line longer than 64 columns", nil)
+		}
+
+		s.Body = append(s.Body, b...)
+		if len(b) < bytesPerLine {
+			return s, nil
+		}
+	}
+}
+
+// decodeBase64 decodes canonical unpadded base64. The standard decoder skips
+// newlines, so they are rejected first
+func decodeBase64(s string) ([]byte, error) {
+	const op = "age.decodeBase64"
+
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, ez.New(op, ez.EINVALID, "Base64 has a newline", nil)
+	}
+
+	b, err := b64.DecodeString(s)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Base64 is malformed", err)
+	}
+
+	return b, nil
+}
+
+func isValidArg(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package age
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptType  = "scrypt"
+	scryptLabel = "age-encryption.org/v1/scrypt"
+	scryptSalt  = 16
+
+	// DefaultWorkFactor is the scrypt work factor used by ScryptRecipient,
+	// the base 2 logarithm of N
+	DefaultWorkFactor = 18
+	// DefaultMaxWorkFactor is the largest work factor ScryptIdentity accepts
+	DefaultMaxWorkFactor = 22
+)
+
+var workFactorRegexp = regexp.MustCompile(`^[1-9][0-9]*$`)
+
+// ScryptRecipient encrypts files with a passphrase. It can not be combined
+// with other recipients
+type ScryptRecipient struct {
+	passphrase []byte
+	workFactor int
+}
+
+// ScryptIdentity decrypts files encrypted with a passphrase
+type ScryptIdentity struct {
+	passphrase    []byte
+	maxWorkFactor int
+}
+
+// NewScryptRecipient returns a recipient for a passphrase with the default
+// work factor
+func NewScryptRecipient(passphrase string) (*ScryptRecipient, error) {
+	const op = "age.NewScryptRecipient"
+
+	if passphrase == "" {
+		return nil, ez.New(op, ez.EINVALID, "Passphrase can not be empty", nil)
+	}
+
+	return &ScryptRecipient{passphrase: []byte(passphrase), workFactor: DefaultWorkFactor}, nil
+}
+
+// SetWorkFactor sets the base 2 logarithm of the scrypt N parameter, between
+// 1 and 30. Each increment doubles the time and memory needed to decrypt
+func (r *ScryptRecipient) SetWorkFactor(logN int) error {
+	const op = "age.ScryptRecipient.SetWorkFactor"
+
+	if logN < 1 || logN > 30 {
+		return ez.New(op, ez.EINVALID, "Work factor must be between 1 and 30", nil)
+	}
+	r.workFactor = logN
+
+	return nil
+}
+
+// Wrap encrypts the file key with a key derived from the passphrase
+func (r *ScryptRecipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	const op = "age.ScryptRecipient.Wrap"
+
+	salt := make([]byte, scryptSalt)
+	_, err := io.ReadFull(randReader, salt)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the salt", err)
+	}
+
+	wrapKey, err := scryptKey(op, r.passphrase, salt, r.workFactor)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Wipe(wrapKey)
+
+	body, err := aeadEncrypt(wrapKey, fileKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	s := &Stanza{
+		Type: scryptType,
+		Args: []string{b64.EncodeToString(salt), strconv.Itoa(r.workFactor)},
+		Body: body,
+	}
+
+	return []*Stanza{s}, nil
+}
+
+// NewScryptIdentity returns an identity for a passphrase that accepts work
+// factors up to DefaultMaxWorkFactor
+func NewScryptIdentity(passphrase string) (*ScryptIdentity, error) {
+	const op = "age.NewScryptIdentity"
+
+	if passphrase == "" {
+		return nil, ez.New(op, ez.EINVALID, "Passphrase can not be empty", nil)
+	}
+
+	return &ScryptIdentity{passphrase: []byte(passphrase), maxWorkFactor: DefaultMaxWorkFactor}, nil
+}
+
+// SetMaxWorkFactor sets the largest work factor accepted from a file, so an
+// untrusted file can not make decryption use unbounded time and memory
+func (i *ScryptIdentity) SetMaxWorkFactor(logN int) error {
+	const op = "age.ScryptIdentity.SetMaxWorkFactor"
+
+	if logN < 1 || logN > 30 {
+		return ez.New(op, ez.EINVALID, "Work factor must be between 1 and 30", nil)
+	}
+	i.maxWorkFactor = logN
+
+	return nil
+}
+
+// Unwrap decrypts the file key of a file encrypted with a passphrase. An
+// scrypt stanza must be the only one in the file
+func (i *ScryptIdentity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	const op = "age.ScryptIdentity.Unwrap"
+
+	for _, s := range stanzas {
+		if s.Type != scryptType {
+			continue
+		} else if len(stanzas) != 1 {
+			return nil, ez.New(op, ez.EINVALID, "An scrypt stanza must be the only one in the file", nil)
+		} else if len(s.Args) != 2 {
+			return nil, ez.New(op, ez.EINVALID, "scrypt stanza must have two arguments", nil)
+		}
+
+		salt, err := decodeBase64(s.Args[0])
+		if err != nil || len(salt) != scryptSalt {
+			return nil, ez.New(op, ez.EINVALID, "scrypt stanza has an invalid salt", nil)
+		} else if !workFactorRegexp.MatchString(s.Args[1]) {
+			return nil, ez.New(op, ez.EINVALID, "scrypt stanza has an invalid work factor", nil)
+		}
+
+		logN, err := strconv.Atoi(s.Args[1])
+		if err != nil || logN > 30 {
+			return nil, ez.New(op, ez.EINVALID, "scrypt stanza has an invalid work factor", nil)
+		} else if logN > i.maxWorkFactor {
+			return nil, ez.New(op, ez.EINVALID, "scrypt stanza work factor is larger than the maximum", nil)
+		} else if len(s.Body) != fileKeySize+tagSize {
+			return nil, ez.New(op, ez.EINVALID, "scrypt stanza has an invalid body", nil)
+		}
+
+		wrapKey, err := scryptKey(op, i.passphrase, salt, logN)
+		if err != nil {
+			return nil, err
+		}
+		defer keys.Wipe(wrapKey)
+
+		fileKey, err := aeadDecrypt(wrapKey, s.Body)
+		if err != nil {
+			return nil, ErrIncorrectIdentity
+		}
+
+		return fileKey, nil
+	}
+
+	return nil, ErrIncorrectIdentity
+}
+
+func scryptKey(op string, passphrase, salt []byte, logN int) ([]byte, error) {
+	s := make([]byte, 0, len(scryptLabel)+len(salt))
+	s = append(s, scryptLabel...)
+	s = append(s, salt...)
+
+	key, err := scrypt.Key(passphrase, s, 1<<uint(logN), 8, 1, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while deriving the scrypt key", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,63 @@
+package age
+
+import (
+	"io"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/internal/stream"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The payload is encrypted with the STREAM construction and ChaCha20-Poly1305
+// in chunks of 64 KiB. The nonce of each chunk is an 11 byte big endian
+// counter followed by a byte that is 1 only for the final chunk. Only an empty
+// payload has an empty final chunk
+const (
+	chunkSize = stream.ChunkSize
+	tagSize   = stream.TagSize
+)
+
+func newStreamWriter(key []byte, w io.Writer) (*stream.Writer, error) {
+	const op = "age.newStreamWriter"
+
+	config, err := streamConfig(key)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return stream.NewWriter(w, config), nil
+}
+
+func newStreamReader(key []byte, r io.Reader) (*stream.Reader, error) {
+	const op = "age.newStreamReader"
+
+	config, err := streamConfig(key)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return stream.NewReader(r, config), nil
+}
+
+func streamConfig(key []byte) (stream.Config, error) {
+	const op = "age.streamConfig"
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return stream.Config{}, ez.New(op, ez.EINTERNAL, "Error while creating the cipher", err)
+	}
+
+	return stream.Config{AEAD: aead, Nonce: streamNonce}, nil
+}
+
+func streamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	for i := 10; i >= 3; i-- {
+		nonce[i] = byte(counter)
+		counter >>= 8
+	}
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,244 @@
+package age
+
+import (
+	"crypto/sha256"
+	"io"
+	"strings"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"github.com/vanclief/go-crypto/nacl"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	x25519Type            = "X25519"
+	x25519Label           = "age-encryption.org/v1/X25519"
+	x25519RecipientPrefix = "age"
+	x25519IdentityPrefix  = "age-secret-key-"
+)
+
+// X25519Recipient encrypts files to a Curve25519 public key, encoded as
+// age1...
+type X25519Recipient struct {
+	publicKey []byte
+}
+
+// X25519Identity decrypts files encrypted to its public key, encoded as
+// AGE-SECRET-KEY-1...
+type X25519Identity struct {
+	privateKey []byte
+	publicKey  []byte
+}
+
+// NewX25519Recipient returns the recipient of a NaCl public key
+func NewX25519Recipient(pub *nacl.PublicKey) (*X25519Recipient, error) {
+	const op = "age.NewX25519Recipient"
+
+	if pub == nil || pub.Key == nil || len(pub.Value) != curve25519.PointSize {
+		return nil, ez.New(op, ez.EINVALID, "PublicKey must be 32 bytes long", nil)
+	}
+
+	return &X25519Recipient{publicKey: append([]byte{}, pub.Value...)}, nil
+}
+
+// NewX25519Identity returns the identity of a NaCl key pair
+func NewX25519Identity(kp *nacl.KeyPair) (*X25519Identity, error) {
+	const op = "age.NewX25519Identity"
+
+	if kp == nil || kp.KeyPair == nil {
+		return nil, ez.New(op, ez.EINVALID, "KeyPair can not be nil", nil)
+	}
+
+	priv, err := kp.Private()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return newX25519Identity(priv.Value)
+}
+
+// GenerateX25519Identity returns a new random identity
+func GenerateX25519Identity() (*X25519Identity, error) {
+	const op = "age.GenerateX25519Identity"
+
+	priv := make([]byte, curve25519.ScalarSize)
+	_, err := io.ReadFull(randReader, priv)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the key", err)
+	}
+	defer keys.Wipe(priv)
+
+	return newX25519Identity(priv)
+}
+
+// ParseX25519Recipient decodes an age1... recipient
+func ParseX25519Recipient(s string) (*X25519Recipient, error) {
+	const op = "age.ParseX25519Recipient"
+
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if hrp != x25519RecipientPrefix || s != bech32Encode(hrp, data) {
+		return nil, ez.New(op, ez.EINVALID, "Recipient is not an age1 lowercase string", nil)
+	} else if len(data) != curve25519.PointSize {
+		return nil, ez.New(op, ez.EINVALID, "Recipient must encode 32 bytes", nil)
+	}
+
+	return &X25519Recipient{publicKey: data}, nil
+}
+
+// ParseX25519Identity decodes an AGE-SECRET-KEY-1... identity
+func ParseX25519Identity(s string) (*X25519Identity, error) {
+	const op = "age.ParseX25519Identity"
+
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if hrp != x25519IdentityPrefix {
+		return nil, ez.New(op, ez.EINVALID, "Identity is not an AGE-SECRET-KEY-1 string", nil)
+	} else if len(data) != curve25519.ScalarSize {
+		return nil, ez.New(op, ez.EINVALID, "Identity must encode 32 bytes", nil)
+	}
+	defer keys.Wipe(data)
+
+	return newX25519Identity(data)
+}
+
+func newX25519Identity(priv []byte) (*X25519Identity, error) {
+	const op = "age.newX25519Identity"
+
+	if len(priv) != curve25519.ScalarSize {
+		return nil, ez.New(op, ez.EINVALID, "PrivateKey must be 32 bytes long", nil)
+	}
+
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "PrivateKey is not valid", err)
+	}
+
+	return &X25519Identity{privateKey: append([]byte{}, priv...), publicKey: pub}, nil
+}
+
+// String returns the age1... encoding of the recipient
+func (r *X25519Recipient) String() string {
+	return bech32Encode(x25519RecipientPrefix, r.publicKey)
+}
+
+// PublicKey returns the recipient as a NaCl public key
+func (r *X25519Recipient) PublicKey() *nacl.PublicKey {
+	return &nacl.PublicKey{Key: keys.New(append([]byte{}, r.publicKey...), keys.C25519)}
+}
+
+// Wrap encrypts the file key to an ephemeral share agreed with the recipient
+func (r *X25519Recipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	const op = "age.X25519Recipient.Wrap"
+
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	_, err := io.ReadFull(randReader, ephemeral)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the ephemeral key", err)
+	}
+	defer keys.Wipe(ephemeral)
+
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while computing the ephemeral share", err)
+	}
+
+	shared, err := curve25519.X25519(ephemeral, r.publicKey)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Recipient is a low order point", err)
+	}
+	defer keys.Wipe(shared)
+
+	wrapKey := x25519WrapKey(shared, share, r.publicKey)
+	defer keys.Wipe(wrapKey)
+
+	body, err := aeadEncrypt(wrapKey, fileKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return []*Stanza{{Type: x25519Type, Args: []string{b64.EncodeToString(share)}, Body: body}}, nil
+}
+
+// String returns the AGE-SECRET-KEY-1... encoding of the identity
+func (i *X25519Identity) String() string {
+	return strings.ToUpper(bech32Encode(x25519IdentityPrefix, i.privateKey))
+}
+
+// Recipient returns the recipient of the identity
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{publicKey: append([]byte{}, i.publicKey...)}
+}
+
+// KeyPair returns the identity as a NaCl key pair
+func (i *X25519Identity) KeyPair() *nacl.KeyPair {
+	pub := append([]byte{}, i.publicKey...)
+	priv := append([]byte{}, i.privateKey...)
+
+	return &nacl.KeyPair{KeyPair: keys.NewKeyPair(pub, priv, keys.C25519)}
+}
+
+// Unwrap decrypts the file key from the first X25519 stanza addressed to the
+// identity. Malformed X25519 stanzas are an error even if they are not
+func (i *X25519Identity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	const op = "age.X25519Identity.Unwrap"
+
+	for _, s := range stanzas {
+		if s.Type != x25519Type {
+			continue
+		} else if len(s.Args) != 1 {
+			return nil, ez.New(op, ez.EINVALID, "X25519 stanza must have one argument", nil)
+		}
+
+		share, err := decodeBase64(s.Args[0])
+		if err != nil || len(share) != curve25519.PointSize {
+			return nil, ez.New(op, ez.EINVALID, "X25519 stanza has an invalid share", nil)
+		} else if len(s.Body) != fileKeySize+tagSize {
+			return nil, ez.New(op, ez.EINVALID, "X25519 stanza has an invalid body", nil)
+		}
+
+		shared, err := curve25519.X25519(i.privateKey, share)
+		if err != nil {
+			return nil, ez.New(op, ez.EINVALID, "X25519 stanza has a low order share", nil)
+		}
+
+		wrapKey := x25519WrapKey(shared, share, i.publicKey)
+		keys.Wipe(shared)
+
+		fileKey, err := aeadDecrypt(wrapKey, s.Body)
+		keys.Wipe(wrapKey)
+		if err == nil {
+			return fileKey, nil
+		}
+	}
+
+	return nil, ErrIncorrectIdentity
+}
+
+// Destroy zeroes the private key of the identity
+func (i *X25519Identity) Destroy() {
+	keys.Wipe(i.privateKey)
+}
+
+func x25519WrapKey(shared, share, publicKey []byte) []byte {
+	salt := make([]byte, 0, len(share)+len(publicKey))
+	salt = append(salt, share...)
+	salt = append(salt, publicKey...)
+
+	return hkdfKey(shared, salt, x25519Label, chacha20poly1305.KeySize)
+}
+
+func hkdfKey(secret, salt []byte, info string, size int) []byte {
+	key := make([]byte, size)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key)
+	if err != nil {
+		// HKDF only fails when more than 255 blocks are requested
+		panic("age: " + err.Error())
+	}
+	return key
+}
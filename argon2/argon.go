@@ -13,6 +13,16 @@ const (
 	keyLength   uint32 = 32
 )
 
+// Params represents the cost parameters of Argon2id. Memory is in KiB
+type Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// DefaultParams are the parameters used by KDF
+var DefaultParams = Params{Time: iterations, Memory: memory, Threads: parallelism}
+
 // KDF will use Argon2 to derivate a key from a string secret and a string salt
 func KDF(secret string, salt string) (*keys.Key, error) {
 	const op = "Argon2.KDF"
@@ -26,3 +36,39 @@ func KDF(secret string, salt string) (*keys.Key, error) {
 
 	return keys.New(key, keys.Argon2), nil
 }
+
+// IDKey will use Argon2id with the given parameters to derivate a 32 byte key
+// from a secret and a salt
+func IDKey(secret, salt []byte, p Params) (*keys.Key, error) {
+	const op = "Argon2.IDKey"
+	if len(secret) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Secret can not be empty", nil)
+	} else if len(salt) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Salt can not be empty", nil)
+	} else if err := p.Validate(); err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	key := argon2.IDKey(secret, salt, p.Time, p.Memory, p.Threads, keyLength)
+
+	return keys.New(key, keys.Argon2), nil
+}
+
+// Validate checks the parameters are accepted by Argon2id
+func (p Params) Validate() error {
+	const op = "Argon2.Params.Validate"
+	if p.Time < 1 {
+		return ez.New(op, ez.EINVALID, "Time must be at least 1", nil)
+	} else if p.Threads < 1 {
+		return ez.New(op, ez.EINVALID, "Threads must be at least 1", nil)
+	} else if p.Memory < 8*uint32(p.Threads) {
+		return ez.New(op, ez.EINVALID, "Memory must be at least 8 KiB per thread", nil)
+	}
+
+	return nil
+}
+
+// Exceeds returns true if any parameter is larger than the one in max
+func (p Params) Exceeds(max Params) bool {
+	return p.Time > max.Time || p.Memory > max.Memory || p.Threads > max.Threads
+}
@@ -29,3 +29,34 @@ func TestArgon2KD(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
 }
+
+func TestIDKey(t *testing.T) {
+	// Setup
+	salt := []byte("tester@gmail.com")
+
+	// Case 1: Should match KDF with the default parameters
+	key, err := IDKey([]byte("password123"), salt, DefaultParams)
+	assert.Nil(t, err)
+	assert.Equal(t, "ry86D23WlX277BAkXN6Em8Q9WV0hoiPr2LIIAAYmdlw", utils.BytesToBase64(key.Value))
+
+	// Case 2: Should derive another key with other parameters
+	key, err = IDKey([]byte("password123"), salt, Params{Time: 1, Memory: 1024, Threads: 1})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "ry86D23WlX277BAkXN6Em8Q9WV0hoiPr2LIIAAYmdlw", utils.BytesToBase64(key.Value))
+
+	// Case 3: Should fail with invalid parameters
+	for _, p := range []Params{{Time: 0, Memory: 1024, Threads: 1}, {Time: 1, Memory: 1024, Threads: 0}, {Time: 1, Memory: 7, Threads: 1}} {
+		_, err = IDKey([]byte("password123"), salt, p)
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	}
+
+	// Case 4: Should fail without a secret or a salt
+	_, err = IDKey(nil, salt, DefaultParams)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = IDKey([]byte("password123"), nil, DefaultParams)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should compare parameters against limits
+	assert.False(t, DefaultParams.Exceeds(DefaultParams))
+	assert.True(t, Params{Time: 1, Memory: 2 * memory, Threads: 1}.Exceeds(DefaultParams))
+}
@@ -0,0 +1,340 @@
+package ed25519
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/vanclief/ez"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// UsageSign allows the certified key to sign messages
+	UsageSign Usage = 1 << iota
+	// UsageCertSign allows the certified key to issue certificates
+	UsageCertSign
+	// UsageClientAuth allows the certified key to authenticate as a client
+	UsageClientAuth
+	// UsageServerAuth allows the certified key to authenticate as a server
+	UsageServerAuth
+)
+
+const (
+	certificateVersion  byte = 1
+	certificateContext       = "go-crypto ed25519 certificate v1"
+	certificateMaxName       = 1<<16 - 1
+	certificateMaxChain      = 16
+)
+
+// Usage is a set of flags of what a certified key can be used for
+type Usage uint32
+
+// Certificate represents a lightweight certificate where an issuer signs a
+// subject public key. MaxPathLength is the number of intermediate
+// certificates allowed below a certificate authority, a negative value means
+// unlimited. SubjectKey and IssuerKey must be 32 byte ed25519 public keys
+type Certificate struct {
+	Subject       string    `json:"subject"`
+	SubjectKey    []byte    `json:"subject_key"`
+	Issuer        string    `json:"issuer"`
+	IssuerKey     []byte    `json:"issuer_key"`
+	NotBefore     time.Time `json:"not_before"`
+	NotAfter      time.Time `json:"not_after"`
+	Usage         Usage     `json:"usage"`
+	MaxPathLength int32     `json:"max_path_length"`
+	Signature     []byte    `json:"signature"`
+}
+
+// SelfSignCertificate creates a root certificate for the KeyPair from a
+// template. The subject key and issuer fields of the template are ignored
+func (kp *KeyPair) SelfSignCertificate(template *Certificate) (*Certificate, error) {
+	const op = "ed25519.SelfSignCertificate"
+
+	pub, err := kp.Public()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	cert := *template
+	cert.SubjectKey = pub.Value
+	cert.Issuer = cert.Subject
+	cert.IssuerKey = pub.Value
+
+	err = kp.signCertificate(&cert)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return &cert, nil
+}
+
+// IssueCertificate creates a certificate from a template signed by the
+// KeyPair, which must be the subject of the issuer certificate
+func (kp *KeyPair) IssueCertificate(template *Certificate, issuer *Certificate) (*Certificate, error) {
+	const op = "ed25519.IssueCertificate"
+
+	if issuer == nil {
+		return nil, ez.New(op, ez.EINVALID, "Issuer certificate can not be nil", nil)
+	} else if !bytes.Equal(issuer.SubjectKey, kp.PublicKey) {
+		return nil, ez.New(op, ez.EINVALID, "Issuer certificate does not belong to the KeyPair", nil)
+	} else if issuer.Usage&UsageCertSign == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Issuer certificate is not allowed to issue certificates", nil)
+	}
+
+	if _, err := NewPublicKey(template.SubjectKey); err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	cert := *template
+	cert.Issuer = issuer.Subject
+	cert.IssuerKey = issuer.SubjectKey
+
+	err := kp.signCertificate(&cert)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return &cert, nil
+}
+
+func (kp *KeyPair) signCertificate(cert *Certificate) error {
+	const op = "ed25519.signCertificate"
+
+	if !cert.NotAfter.After(cert.NotBefore) {
+		return ez.New(op, ez.EINVALID, "NotAfter must be after NotBefore", nil)
+	} else if len(cert.Subject) > certificateMaxName || len(cert.Issuer) > certificateMaxName {
+		return ez.New(op, ez.EINVALID, "Subject and Issuer must be shorter than 64KiB", nil)
+	}
+
+	cert.NotBefore = cert.NotBefore.Truncate(time.Second)
+	cert.NotAfter = cert.NotAfter.Truncate(time.Second)
+
+	data, err := cert.signedData()
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	sig, err := kp.Sign(data)
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+	cert.Signature = sig
+
+	return nil
+}
+
+// Verify builds a chain from the certificate up to one of the trusted roots
+// using the intermediates, checking signatures, validity windows, usages and
+// path lengths at the given time. It returns the chain starting with the
+// certificate and ending with the root
+func (c *Certificate) Verify(intermediates, roots []*Certificate, at time.Time, usage Usage) ([]*Certificate, error) {
+	const op = "ed25519.Certificate.Verify"
+
+	if c.Usage&usage != usage {
+		return nil, ez.New(op, ez.ENOTAUTHORIZED, "Certificate does not allow the requested usage", nil)
+	}
+
+	chain := []*Certificate{c}
+	current := c
+	for len(chain) <= certificateMaxChain {
+		if !current.validAt(at) {
+			return nil, ez.New(op, ez.ENOTAUTHENTICATED, "Certificate "+current.Subject+" is expired or not valid yet", nil)
+		}
+
+		if root := findIssuer(current, roots); root != nil {
+			if !root.validAt(at) {
+				return nil, ez.New(op, ez.ENOTAUTHENTICATED, "Root certificate "+root.Subject+" is expired or not valid yet", nil)
+			}
+
+			chain = append(chain, root)
+			err := checkPathLengths(chain)
+			if err != nil {
+				return nil, ez.Wrap(op, err)
+			}
+
+			return chain, nil
+		}
+
+		issuer := findIssuer(current, intermediates)
+		if issuer == nil {
+			return nil, ez.New(op, ez.ENOTAUTHENTICATED, "Certificate "+current.Subject+" is not signed by a trusted issuer", nil)
+		}
+
+		chain = append(chain, issuer)
+		current = issuer
+	}
+
+	return nil, ez.New(op, ez.ENOTAUTHENTICATED, "Certificate chain is too long", nil)
+}
+
+// VerifySignature validates that the certificate was signed by the public key
+func (c *Certificate) VerifySignature(issuerKey *PublicKey) bool {
+	if issuerKey == nil || len(c.Signature) != ed25519.SignatureSize {
+		return false
+	}
+
+	data, err := c.signedData()
+	if err != nil {
+		return false
+	}
+
+	v, err := issuerKey.VerifySignature(c.Signature, data)
+	return err == nil && v
+}
+
+// Marshal encodes the certificate in a compact binary format
+func (c *Certificate) Marshal() ([]byte, error) {
+	const op = "ed25519.Certificate.Marshal"
+
+	tbs, err := c.tbs()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return append(tbs, c.Signature...), nil
+}
+
+// ParseCertificate decodes a certificate from its compact binary format. The
+// signature is not verified
+func ParseCertificate(b []byte) (*Certificate, error) {
+	const op = "ed25519.ParseCertificate"
+
+	r := bytes.NewReader(b)
+	var header struct {
+		Version       byte
+		Usage         uint32
+		MaxPathLength int32
+		NotBefore     int64
+		NotAfter      int64
+		SubjectKey    [ed25519.PublicKeySize]byte
+		IssuerKey     [ed25519.PublicKeySize]byte
+	}
+
+	err := binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Certificate is truncated", err)
+	} else if header.Version != certificateVersion {
+		return nil, ez.New(op, ez.EINVALID, "Certificate has an unsupported version", nil)
+	}
+
+	subject, err := readName(r)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Certificate has an invalid subject", err)
+	}
+
+	issuer, err := readName(r)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Certificate has an invalid issuer", err)
+	}
+
+	if r.Len() != ed25519.SignatureSize {
+		return nil, ez.New(op, ez.EINVALID, "Certificate has an invalid signature length", nil)
+	}
+	sig := make([]byte, ed25519.SignatureSize)
+	r.Read(sig)
+
+	return &Certificate{
+		Subject:       subject,
+		SubjectKey:    header.SubjectKey[:],
+		Issuer:        issuer,
+		IssuerKey:     header.IssuerKey[:],
+		NotBefore:     time.Unix(header.NotBefore, 0).UTC(),
+		NotAfter:      time.Unix(header.NotAfter, 0).UTC(),
+		Usage:         Usage(header.Usage),
+		MaxPathLength: header.MaxPathLength,
+		Signature:     sig,
+	}, nil
+}
+
+// tbs returns the encoding of the certificate that is signed
+func (c *Certificate) tbs() ([]byte, error) {
+	const op = "ed25519.Certificate.tbs"
+
+	if len(c.SubjectKey) != ed25519.PublicKeySize || len(c.IssuerKey) != ed25519.PublicKeySize {
+		return nil, ez.New(op, ez.EINVALID, "SubjectKey and IssuerKey must be 32 bytes long", nil)
+	} else if len(c.Subject) > certificateMaxName || len(c.Issuer) > certificateMaxName {
+		return nil, ez.New(op, ez.EINVALID, "Subject and Issuer must be shorter than 64KiB", nil)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(certificateVersion)
+	binary.Write(&buf, binary.BigEndian, uint32(c.Usage))
+	binary.Write(&buf, binary.BigEndian, c.MaxPathLength)
+	binary.Write(&buf, binary.BigEndian, c.NotBefore.Unix())
+	binary.Write(&buf, binary.BigEndian, c.NotAfter.Unix())
+	buf.Write(c.SubjectKey)
+	buf.Write(c.IssuerKey)
+	writeName(&buf, c.Subject)
+	writeName(&buf, c.Issuer)
+	return buf.Bytes(), nil
+}
+
+func (c *Certificate) signedData() ([]byte, error) {
+	tbs, err := c.tbs()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(certificateContext), tbs...), nil
+}
+
+func (c *Certificate) validAt(at time.Time) bool {
+	return !at.Before(c.NotBefore) && at.Before(c.NotAfter)
+}
+
+// findIssuer returns the candidate that issued the certificate
+func findIssuer(c *Certificate, candidates []*Certificate) *Certificate {
+	for _, candidate := range candidates {
+		if candidate == nil || candidate.Subject != c.Issuer || !bytes.Equal(candidate.SubjectKey, c.IssuerKey) {
+			continue
+		}
+
+		pub, err := NewPublicKey(candidate.SubjectKey)
+		if err == nil && c.VerifySignature(pub) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// checkPathLengths validates that every issuer in the chain may issue
+// certificates and that the number of intermediates below it is allowed
+func checkPathLengths(chain []*Certificate) error {
+	const op = "ed25519.checkPathLengths"
+
+	for i := 1; i < len(chain); i++ {
+		issuer := chain[i]
+		if issuer.Usage&UsageCertSign == 0 {
+			return ez.New(op, ez.ENOTAUTHORIZED, "Certificate "+issuer.Subject+" is not allowed to issue certificates", nil)
+		}
+
+		below := i - 1
+		if issuer.MaxPathLength >= 0 && below > int(issuer.MaxPathLength) {
+			return ez.New(op, ez.ENOTAUTHORIZED, "Certificate "+issuer.Subject+" exceeds its maximum path length", nil)
+		}
+	}
+
+	return nil
+}
+
+func writeName(buf *bytes.Buffer, name string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(name)))
+	buf.WriteString(name)
+}
+
+func readName(r *bytes.Reader) (string, error) {
+	var n uint16
+	err := binary.Read(r, binary.BigEndian, &n)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,240 @@
+package ed25519
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+type testPKI struct {
+	rootKey, intermediateKey, leafKey *KeyPair
+	root, intermediate, leaf          *Certificate
+}
+
+func newTestPKI(t *testing.T, now time.Time) *testPKI {
+	p := &testPKI{}
+	p.rootKey, _ = NewKeyPair()
+	p.intermediateKey, _ = NewKeyPair()
+	p.leafKey, _ = NewKeyPair()
+
+	var err error
+	p.root, err = p.rootKey.SelfSignCertificate(&Certificate{
+		Subject:       "Root CA",
+		NotBefore:     now.Add(-time.Hour),
+		NotAfter:      now.Add(365 * 24 * time.Hour),
+		Usage:         UsageCertSign,
+		MaxPathLength: 1,
+	})
+	assert.Nil(t, err)
+
+	p.intermediate, err = p.rootKey.IssueCertificate(&Certificate{
+		Subject:    "Devices CA",
+		SubjectKey: p.intermediateKey.PublicKey,
+		NotBefore:  now.Add(-time.Hour),
+		NotAfter:   now.Add(30 * 24 * time.Hour),
+		Usage:      UsageCertSign,
+	}, p.root)
+	assert.Nil(t, err)
+
+	p.leaf, err = p.intermediateKey.IssueCertificate(&Certificate{
+		Subject:    "device-42",
+		SubjectKey: p.leafKey.PublicKey,
+		NotBefore:  now.Add(-time.Hour),
+		NotAfter:   now.Add(24 * time.Hour),
+		Usage:      UsageSign | UsageClientAuth,
+	}, p.intermediate)
+	assert.Nil(t, err)
+
+	return p
+}
+
+func TestIssueCertificate(t *testing.T) {
+	// Setup
+	now := time.Now()
+	p := newTestPKI(t, now)
+
+	// Case 1: Should set the issuer from the issuer certificate
+	assert.Equal(t, "Devices CA", p.leaf.Issuer)
+	assert.Equal(t, p.intermediateKey.PublicKey, p.leaf.IssuerKey)
+
+	intermediatePub, _ := p.intermediateKey.Public()
+	assert.True(t, p.leaf.VerifySignature(intermediatePub))
+
+	// Case 2: Should fail when the issuer can not issue certificates
+	cert, err := p.leafKey.IssueCertificate(&Certificate{
+		Subject:    "sub-device",
+		SubjectKey: p.leafKey.PublicKey,
+		NotBefore:  now,
+		NotAfter:   now.Add(time.Hour),
+	}, p.leaf)
+	assert.Nil(t, cert)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with a certificate of another KeyPair
+	cert, err = p.leafKey.IssueCertificate(&Certificate{
+		Subject:    "sub-device",
+		SubjectKey: p.leafKey.PublicKey,
+		NotBefore:  now,
+		NotAfter:   now.Add(time.Hour),
+	}, p.root)
+	assert.Nil(t, cert)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with an invalid validity window
+	cert, err = p.rootKey.IssueCertificate(&Certificate{
+		Subject:    "device-43",
+		SubjectKey: p.leafKey.PublicKey,
+		NotBefore:  now,
+		NotAfter:   now,
+	}, p.root)
+	assert.Nil(t, cert)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestCertificateVerify(t *testing.T) {
+	// Setup
+	now := time.Now()
+	p := newTestPKI(t, now)
+	intermediates := []*Certificate{p.intermediate}
+	roots := []*Certificate{p.root}
+
+	// Case 1: Should work
+	chain, err := p.leaf.Verify(intermediates, roots, now, UsageClientAuth)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Certificate{p.leaf, p.intermediate, p.root}, chain)
+
+	// Case 2: Should fail with an usage that is not allowed
+	chain, err = p.leaf.Verify(intermediates, roots, now, UsageServerAuth)
+	assert.Nil(t, chain)
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	// Case 3: Should fail without the intermediate
+	chain, err = p.leaf.Verify(nil, roots, now, UsageSign)
+	assert.Nil(t, chain)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 4: Should fail with an untrusted root
+	otherRootKey, _ := NewKeyPair()
+	otherRoot, _ := otherRootKey.SelfSignCertificate(p.root)
+	chain, err = p.leaf.Verify(intermediates, []*Certificate{otherRoot}, now, UsageSign)
+	assert.Nil(t, chain)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 5: Should fail after the leaf expires
+	chain, err = p.leaf.Verify(intermediates, roots, now.Add(48*time.Hour), UsageSign)
+	assert.Nil(t, chain)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 6: Should fail with a tampered certificate
+	tampered := *p.leaf
+	tampered.Subject = "device-666"
+	chain, err = tampered.Verify(intermediates, roots, now, UsageSign)
+	assert.Nil(t, chain)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+}
+
+func TestCertificateVerifyPathLength(t *testing.T) {
+	// Setup
+	now := time.Now()
+	p := newTestPKI(t, now)
+	subKey, _ := NewKeyPair()
+	deviceKey, _ := NewKeyPair()
+
+	sub, _ := p.intermediateKey.IssueCertificate(&Certificate{
+		Subject:    "Sub CA",
+		SubjectKey: subKey.PublicKey,
+		NotBefore:  now.Add(-time.Hour),
+		NotAfter:   now.Add(time.Hour),
+		Usage:      UsageCertSign,
+	}, p.intermediate)
+	device, _ := subKey.IssueCertificate(&Certificate{
+		Subject:    "device-7",
+		SubjectKey: deviceKey.PublicKey,
+		NotBefore:  now.Add(-time.Hour),
+		NotAfter:   now.Add(time.Hour),
+		Usage:      UsageSign,
+	}, sub)
+
+	// Case 1: Should fail when an issuer exceeds its maximum path length
+	chain, err := device.Verify([]*Certificate{p.intermediate, sub}, []*Certificate{p.root}, now, UsageSign)
+	assert.Nil(t, chain)
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	// Case 2: Should work with an unlimited path length
+	p.root.MaxPathLength = -1
+	root, _ := p.rootKey.SelfSignCertificate(p.root)
+	intermediate, _ := p.rootKey.IssueCertificate(&Certificate{
+		Subject:       "Devices CA",
+		SubjectKey:    p.intermediateKey.PublicKey,
+		NotBefore:     now.Add(-time.Hour),
+		NotAfter:      now.Add(time.Hour),
+		Usage:         UsageCertSign,
+		MaxPathLength: 1,
+	}, root)
+
+	chain, err = device.Verify([]*Certificate{intermediate, sub}, []*Certificate{root}, now, UsageSign)
+	assert.Nil(t, err)
+	assert.Len(t, chain, 4)
+}
+
+func TestCertificateEncoding(t *testing.T) {
+	// Setup
+	now := time.Now()
+	p := newTestPKI(t, now)
+
+	// Case 1: Should work with the binary encoding
+	b, err := p.leaf.Marshal()
+	assert.Nil(t, err)
+	parsed, err := ParseCertificate(b)
+	assert.Nil(t, err)
+	reencoded, err := parsed.Marshal()
+	assert.Nil(t, err)
+	assert.Equal(t, b, reencoded)
+
+	chain, err := parsed.Verify([]*Certificate{p.intermediate}, []*Certificate{p.root}, now, UsageSign)
+	assert.Nil(t, err)
+	assert.Len(t, chain, 3)
+
+	// Case 2: Should work with the JSON encoding
+	j, err := json.Marshal(p.leaf)
+	assert.Nil(t, err)
+
+	decoded := &Certificate{}
+	err = json.Unmarshal(j, decoded)
+	assert.Nil(t, err)
+	reencoded, err = decoded.Marshal()
+	assert.Nil(t, err)
+	assert.Equal(t, b, reencoded)
+
+	// Case 3: Should fail with a truncated certificate
+	parsed, err = ParseCertificate(b[:len(b)-1])
+	assert.Nil(t, parsed)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	parsed, err = ParseCertificate(b[:40])
+	assert.Nil(t, parsed)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should not decode a path length that does not fit in the signature
+	j, err = json.Marshal(p.root)
+	assert.Nil(t, err)
+	var fields map[string]interface{}
+	assert.Nil(t, json.Unmarshal(j, &fields))
+	fields["max_path_length"] = 4294967296
+	j, _ = json.Marshal(fields)
+	assert.NotNil(t, json.Unmarshal(j, &Certificate{}))
+
+	// Case 5: Should reject keys that are not 32 bytes long
+	for _, key := range [][]byte{p.leafKey.PublicKey[:31], append(append([]byte{}, p.leafKey.PublicKey...), 0)} {
+		modified := *p.leaf
+		modified.SubjectKey = key
+		_, err = modified.Marshal()
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+		issuerKey, _ := p.intermediateKey.Public()
+		assert.False(t, modified.VerifySignature(issuerKey))
+	}
+}
@@ -16,6 +16,16 @@ type KeyPair struct {
 	*keys.KeyPair
 }
 
+// PublicKey represents an ed25519 public key
+type PublicKey struct {
+	*keys.Key
+}
+
+// PrivateKey represents an ed25519 private key
+type PrivateKey struct {
+	*keys.Key
+}
+
 // NewKeyPair generates a random ed25519 public/private key pair
 func NewKeyPair() (*KeyPair, error) {
 	const op = "ed25519.NewKeyPair"
@@ -29,14 +39,118 @@ func NewKeyPair() (*KeyPair, error) {
 	return &KeyPair{kp}, nil
 }
 
-// LoadKeyPair returns a keypair from existing keys key pair
+// NewGuardedKeyPair generates a random ed25519 key pair whose private key is
+// stored in guarded memory. Call Destroy to release it
+func NewGuardedKeyPair() (*KeyPair, error) {
+	const op = "ed25519.NewGuardedKeyPair"
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating ed25519 keypair", err)
+	}
+
+	kp, err := keys.NewGuardedKeyPair(pub, priv, keys.ED25519)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return &KeyPair{kp}, nil
+}
+
+// LoadKeyPair returns a keypair from existing keys key pair. If the public key
+// is nil it is derived from the private key
 func LoadKeyPair(publicKey, privateKey []byte) (*KeyPair, error) {
 	const op = "ed25519.LoadKeyPair"
 
+	if publicKey == nil && privateKey == nil {
+		return nil, ez.New(op, ez.EINVALID, "A KeyPair requires a PublicKey or a PrivateKey", nil)
+	}
+
+	if privateKey != nil {
+		priv, err := NewPrivateKey(privateKey)
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+
+		if publicKey == nil {
+			publicKey = priv.Public().Value
+		}
+	}
+
+	if publicKey != nil {
+		_, err := NewPublicKey(publicKey)
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+	}
+
 	kp := keys.NewKeyPair(publicKey, privateKey, keys.ED25519)
 	return &KeyPair{kp}, nil
 }
 
+// NewPublicKey returns an ed25519 public key from 32 bytes
+func NewPublicKey(b []byte) (*PublicKey, error) {
+	const op = "ed25519.NewPublicKey"
+
+	if len(b) != ed25519.PublicKeySize {
+		return nil, ez.New(op, ez.EINVALID, "An ed25519 PublicKey must be 32 bytes long", nil)
+	}
+
+	return &PublicKey{keys.New(b, keys.ED25519)}, nil
+}
+
+// NewPrivateKey returns an ed25519 private key from 64 bytes
+func NewPrivateKey(b []byte) (*PrivateKey, error) {
+	const op = "ed25519.NewPrivateKey"
+
+	if len(b) != ed25519.PrivateKeySize {
+		return nil, ez.New(op, ez.EINVALID, "An ed25519 PrivateKey must be 64 bytes long", nil)
+	}
+
+	return &PrivateKey{keys.New(b, keys.ED25519)}, nil
+}
+
+// NewPrivateKeyFromSeed returns an ed25519 private key from a 32 byte seed
+func NewPrivateKeyFromSeed(seed []byte) (*PrivateKey, error) {
+	const op = "ed25519.NewPrivateKeyFromSeed"
+
+	if len(seed) != ed25519.SeedSize {
+		return nil, ez.New(op, ez.EINVALID, "An ed25519 seed must be 32 bytes long", nil)
+	}
+
+	return &PrivateKey{keys.New(ed25519.NewKeyFromSeed(seed), keys.ED25519)}, nil
+}
+
+// Public returns the public key of the KeyPair
+func (kp *KeyPair) Public() (*PublicKey, error) {
+	const op = "ed25519.KeyPair.Public"
+
+	pub, err := NewPublicKey(kp.PublicKey)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "The PublicKey from the KeyPair is not valid", err)
+	}
+
+	return pub, nil
+}
+
+// Private returns the private key of the KeyPair
+func (kp *KeyPair) Private() (*PrivateKey, error) {
+	const op = "ed25519.KeyPair.Private"
+
+	priv, err := NewPrivateKey(kp.PrivateKey)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "The PrivateKey from the KeyPair is not valid", err)
+	}
+
+	return priv, nil
+}
+
+// Public derives the public key of the private key
+func (k *PrivateKey) Public() *PublicKey {
+	pub := ed25519.PrivateKey(k.Value).Public().(ed25519.PublicKey)
+	return &PublicKey{keys.New([]byte(pub), keys.ED25519)}
+}
+
 // Sign creates a signature that can be verified
 func (kp *KeyPair) Sign(message []byte) ([]byte, error) {
 	const op = "ed25519.Sign"
@@ -45,7 +159,28 @@ func (kp *KeyPair) Sign(message []byte) ([]byte, error) {
 		return nil, ez.New(op, ez.EINVALID, "A signature can not be generated if the PrivateKey from the KeyPair is not defined", nil)
 	}
 
-	sig := ed25519.Sign(kp.PrivateKey, message)
+	priv, err := kp.Private()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return priv.Sign(message)
+}
+
+// Sign creates a signature that can be verified with the public key
+func (k *PrivateKey) Sign(message []byte) ([]byte, error) {
+	const op = "ed25519.PrivateKey.Sign"
+
+	if len(k.Value) != ed25519.PrivateKeySize {
+		return nil, ez.New(op, ez.EINVALID, "An ed25519 PrivateKey must be 64 bytes long", nil)
+	}
+
+	// The key is copied to the heap because the standard library caches
+	// expanded keys by address, which fails for keys in guarded memory
+	priv := append(ed25519.PrivateKey(nil), k.Value...)
+	defer keys.Wipe(priv)
+
+	sig := ed25519.Sign(priv, message)
 
 	return sig, nil
 }
@@ -58,7 +193,23 @@ func (kp *KeyPair) VerifySignature(signature, message []byte) (bool, error) {
 		return false, ez.New(op, ez.EINVALID, "A signature can not be verified if the PublicKey from the KeyPair is not defined", nil)
 	}
 
-	v := ed25519.Verify(kp.PublicKey, message, signature)
+	pub, err := kp.Public()
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	return pub.VerifySignature(signature, message)
+}
+
+// VerifySignature validates a signature created by the matching private key
+func (k *PublicKey) VerifySignature(signature, message []byte) (bool, error) {
+	const op = "ed25519.PublicKey.VerifySignature"
+
+	if len(k.Value) != ed25519.PublicKeySize {
+		return false, ez.New(op, ez.EINVALID, "An ed25519 PublicKey must be 32 bytes long", nil)
+	}
+
+	v := ed25519.Verify(k.Value, message, signature)
 
 	return v, nil
 }
@@ -71,31 +222,66 @@ func (kp *KeyPair) GenerateTimeSignature(period int) ([]byte, error) {
 		return nil, ez.New(op, ez.EINVALID, "A signature can not be generated if the PrivateKey from the KeyPair is not defined", nil)
 	}
 
-	counter := uint64(math.Floor(float64(time.Now().Unix()) / float64(period)))
+	priv, err := kp.Private()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return priv.GenerateTimeSignature(period)
+}
+
+// GenerateTimeSignature creates a signature that can be used for the determined period in seconds
+func (k *PrivateKey) GenerateTimeSignature(period int) ([]byte, error) {
+	const op = "ed25519.PrivateKey.GenerateTimeSignature"
+
+	if period <= 0 {
+		return nil, ez.New(op, ez.EINVALID, "Period must be greater than zero", nil)
+	}
+
+	counter := timeCounter(period)
 	str := strconv.FormatUint(counter, 10)
-	sig := ed25519.Sign(kp.PrivateKey, []byte(str))
 
-	return sig, nil
+	return k.Sign([]byte(str))
 }
 
-// VerifyTimeSignature verifies a signature for the determined time period
+// VerifyTimeSignature verifies a signature for the determined time period. It
+// returns false if the PublicKey from the KeyPair is not valid
 func (kp *KeyPair) VerifyTimeSignature(signature []byte, period int) bool {
-	const op = "ed25519.VerifyTimeSignature"
+	pub, err := kp.Public()
+	if err != nil {
+		return false
+	}
+
+	return pub.VerifyTimeSignature(signature, period)
+}
+
+// VerifyTimeSignature verifies a signature for the determined time period
+func (k *PublicKey) VerifyTimeSignature(signature []byte, period int) bool {
+	if len(k.Value) != ed25519.PublicKeySize || period <= 0 {
+		return false
+	}
 
-	counter := uint64(math.Floor(float64(time.Now().Unix()) / float64(period)))
+	counter := timeCounter(period)
 	str := strconv.FormatUint(counter, 10)
-	v := ed25519.Verify(kp.PublicKey, []byte(str), signature)
+	v := ed25519.Verify(k.Value, []byte(str), signature)
 
 	if !v {
-		return kp.verifyPreviousTimeSignature(signature, counter)
+		return k.verifyPreviousTimeSignature(signature, counter)
 	}
 
 	return v
 }
 
-func (kp *KeyPair) verifyPreviousTimeSignature(signature []byte, counter uint64) bool {
-	const op = "ed25519.verifyPreviousTimeSignature"
-
+func (k *PublicKey) verifyPreviousTimeSignature(signature []byte, counter uint64) bool {
 	str := strconv.FormatUint(counter-1, 10)
-	return ed25519.Verify(kp.PublicKey, []byte(str), signature)
+	return ed25519.Verify(k.Value, []byte(str), signature)
+}
+
+func timeCounter(period int) uint64 {
+	return uint64(math.Floor(float64(time.Now().Unix()) / float64(period)))
+}
+
+// MarshalJSON encodes the public key including its value, it is not secret
+func (k PublicKey) MarshalJSON() ([]byte, error) {
+	return k.Key.ExportJSON()
 }
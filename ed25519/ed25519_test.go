@@ -2,9 +2,12 @@ package ed25519
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
 	"github.com/vanclief/go-crypto/utils"
 )
 
@@ -105,3 +108,139 @@ func TestVerifyTimeSignature(t *testing.T) {
 	v = deterministicKeyPair.VerifyTimeSignature(sig, 30)
 	assert.Equal(t, false, v)
 }
+
+func TestLoadKeyPair(t *testing.T) {
+	// Setup
+	keyPair, _ := NewKeyPair()
+
+	// Case 1: Should derive the PublicKey from the PrivateKey
+	loaded, err := LoadKeyPair(nil, keyPair.PrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PublicKey, loaded.PublicKey)
+
+	// Case 2: Should work with only a PublicKey
+	loaded, err = LoadKeyPair(keyPair.PublicKey, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded.PrivateKey)
+
+	// Case 3: Should fail with a PrivateKey of the wrong length
+	loaded, err = LoadKeyPair(nil, keyPair.PrivateKey[:32])
+	assert.Nil(t, loaded)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with a PublicKey of the wrong length
+	loaded, err = LoadKeyPair([]byte("short"), nil)
+	assert.Nil(t, loaded)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail without keys
+	loaded, err = LoadKeyPair(nil, nil)
+	assert.Nil(t, loaded)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestNewPublicKey(t *testing.T) {
+	keyPair, _ := NewKeyPair()
+
+	// Case 1: Should work
+	pub, err := NewPublicKey(keyPair.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, keys.ED25519, pub.Type)
+
+	// Case 2: Should fail with the wrong length
+	pub, err = NewPublicKey(keyPair.PrivateKey)
+	assert.Nil(t, pub)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should encode its value as JSON
+	pub, _ = NewPublicKey(keyPair.PublicKey)
+	b, err := json.Marshal(pub)
+	assert.Nil(t, err)
+
+	decoded := &keys.Key{}
+	assert.Nil(t, json.Unmarshal(b, decoded))
+	assert.Equal(t, pub.Value, decoded.Value)
+}
+
+func TestNewPrivateKey(t *testing.T) {
+	keyPair, _ := NewKeyPair()
+
+	// Case 1: Should work and derive the PublicKey
+	priv, err := NewPrivateKey(keyPair.PrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PublicKey, priv.Public().Value)
+
+	// Case 2: Should work from a seed
+	priv, err = NewPrivateKeyFromSeed(keyPair.PrivateKey[:32])
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PrivateKey, priv.Value)
+
+	// Case 3: Should fail with the wrong length
+	priv, err = NewPrivateKey(keyPair.PublicKey)
+	assert.Nil(t, priv)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestPrivateKeySign(t *testing.T) {
+	// Setup
+	keyPair, _ := NewKeyPair()
+	priv, _ := keyPair.Private()
+	pub, _ := keyPair.Public()
+
+	// Case 1: Should work
+	sig, err := priv.Sign([]byte("don't shoot the messenger"))
+	assert.Nil(t, err)
+
+	v, err := pub.VerifySignature(sig, []byte("don't shoot the messenger"))
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 2: Should be verifiable by the KeyPair
+	v, err = keyPair.VerifySignature(sig, []byte("don't shoot the messenger"))
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 3: Should FAIL with an INVALID message
+	v, err = pub.VerifySignature(sig, []byte("shoot the messenger"))
+	assert.Nil(t, err)
+	assert.False(t, v)
+}
+
+func TestPublicKeyVerifyTimeSignature(t *testing.T) {
+	// Setup
+	keyPair, _ := NewKeyPair()
+	priv, _ := keyPair.Private()
+	pub := priv.Public()
+
+	// Case 1: Should work
+	sig, err := priv.GenerateTimeSignature(30)
+	assert.Nil(t, err)
+	assert.True(t, pub.VerifyTimeSignature(sig, 30))
+
+	// Case 2: Should not work with an invalid period
+	assert.False(t, pub.VerifyTimeSignature(sig, 0))
+
+	// Case 3: Should not panic with a KeyPair without a PublicKey
+	keyPair.PublicKey = nil
+	assert.False(t, keyPair.VerifyTimeSignature(sig, 30))
+}
+
+func TestNewGuardedKeyPair(t *testing.T) {
+	// Setup
+	keyPair, err := NewGuardedKeyPair()
+	assert.Nil(t, err)
+
+	// Case 1: Should sign with the guarded key
+	sig, err := keyPair.Sign([]byte("guarded"))
+	assert.Nil(t, err)
+
+	v, err := keyPair.VerifySignature(sig, []byte("guarded"))
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 2: Should not sign after being destroyed
+	keyPair.Destroy()
+	sig, err = keyPair.Sign([]byte("guarded"))
+	assert.Nil(t, sig)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
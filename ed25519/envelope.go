@@ -0,0 +1,172 @@
+package ed25519
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"time"
+
+	"github.com/vanclief/ez"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	envelopeVersion    byte = 1
+	envelopeContext         = "go-crypto ed25519 envelope v1"
+	envelopeMaxKeyID        = 255
+	envelopeHeaderSize      = 1 + 1 + 8*3 + 4
+)
+
+var (
+	// ErrEnvelopeMalformed is returned when an envelope can not be decoded
+	ErrEnvelopeMalformed = ez.New("ed25519.VerifyEnvelope", ez.EINVALID, "Envelope is malformed", nil)
+	// ErrEnvelopeUnknownKey is returned when the envelope key ID is not in the KeySet
+	ErrEnvelopeUnknownKey = ez.New("ed25519.VerifyEnvelope", ez.ENOTFOUND, "Envelope was signed with an unknown key", nil)
+	// ErrEnvelopeBadSignature is returned when the envelope signature is not valid
+	ErrEnvelopeBadSignature = ez.New("ed25519.VerifyEnvelope", ez.ENOTAUTHENTICATED, "Envelope has an invalid signature", nil)
+	// ErrEnvelopeExpired is returned when the envelope is past its expiry
+	ErrEnvelopeExpired = ez.New("ed25519.VerifyEnvelope", ez.ENOTAUTHENTICATED, "Envelope has expired", nil)
+	// ErrEnvelopeNotYetValid is returned when the envelope is used before its not-before time
+	ErrEnvelopeNotYetValid = ez.New("ed25519.VerifyEnvelope", ez.ENOTAUTHENTICATED, "Envelope is not valid yet", nil)
+)
+
+// Claims represents the claims of a signed envelope. A zero NotBefore or
+// ExpiresAt means the claim is not set
+type Claims struct {
+	KeyID     string
+	IssuedAt  time.Time
+	NotBefore time.Time
+	ExpiresAt time.Time
+}
+
+// Envelope represents a verified signed envelope
+type Envelope struct {
+	Claims
+	Payload []byte
+}
+
+// KeySet represents a set of public keys that can verify envelopes, indexed by key ID
+type KeySet map[string]*PublicKey
+
+// SignEnvelope wraps a payload and its claims in a signed envelope. If the
+// IssuedAt claim is zero the current time is used
+func (kp *KeyPair) SignEnvelope(payload []byte, claims Claims) (string, error) {
+	const op = "ed25519.SignEnvelope"
+
+	priv, err := kp.Private()
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return priv.SignEnvelope(payload, claims)
+}
+
+// SignEnvelope wraps a payload and its claims in a signed envelope. If the
+// IssuedAt claim is zero the current time is used
+func (k *PrivateKey) SignEnvelope(payload []byte, claims Claims) (string, error) {
+	const op = "ed25519.PrivateKey.SignEnvelope"
+
+	if claims.KeyID == "" || len(claims.KeyID) > envelopeMaxKeyID {
+		return "", ez.New(op, ez.EINVALID, "KeyID must be between 1 and 255 bytes long", nil)
+	} else if !claims.ExpiresAt.IsZero() && !claims.NotBefore.IsZero() && !claims.ExpiresAt.After(claims.NotBefore) {
+		return "", ez.New(op, ez.EINVALID, "ExpiresAt must be after NotBefore", nil)
+	}
+
+	if claims.IssuedAt.IsZero() {
+		claims.IssuedAt = time.Now()
+	}
+
+	body := make([]byte, 0, envelopeHeaderSize+len(claims.KeyID)+len(payload)+ed25519.SignatureSize)
+	body = append(body, envelopeVersion, byte(len(claims.KeyID)))
+	body = append(body, claims.KeyID...)
+	body = appendTime(body, claims.IssuedAt)
+	body = appendTime(body, claims.NotBefore)
+	body = appendTime(body, claims.ExpiresAt)
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(payload)))
+	body = append(body, length[:]...)
+	body = append(body, payload...)
+
+	sig, err := k.Sign(envelopeSignedData(body))
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(append(body, sig...)), nil
+}
+
+// VerifyEnvelope verifies a signed envelope with the key selected by its key
+// ID and validates its claims against the current time
+func (ks KeySet) VerifyEnvelope(token string) (*Envelope, error) {
+	return ks.VerifyEnvelopeAt(token, time.Now())
+}
+
+// VerifyEnvelopeAt verifies a signed envelope with the key selected by its key
+// ID and validates its claims against the given time
+func (ks KeySet) VerifyEnvelopeAt(token string, at time.Time) (*Envelope, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) < envelopeHeaderSize+ed25519.SignatureSize || b[0] != envelopeVersion {
+		return nil, ErrEnvelopeMalformed
+	}
+
+	body, sig := b[:len(b)-ed25519.SignatureSize], b[len(b)-ed25519.SignatureSize:]
+
+	kidLen := int(body[1])
+	if len(body) < envelopeHeaderSize+kidLen {
+		return nil, ErrEnvelopeMalformed
+	}
+
+	rest := body[2+kidLen:]
+	env := &Envelope{
+		Claims: Claims{
+			KeyID:     string(body[2 : 2+kidLen]),
+			IssuedAt:  readTime(rest[0:8]),
+			NotBefore: readTime(rest[8:16]),
+			ExpiresAt: readTime(rest[16:24]),
+		},
+	}
+
+	payloadLen := binary.BigEndian.Uint32(rest[24:28])
+	if uint64(len(rest)-28) != uint64(payloadLen) {
+		return nil, ErrEnvelopeMalformed
+	}
+	env.Payload = append([]byte{}, rest[28:]...)
+
+	pub, ok := ks[env.KeyID]
+	if !ok || pub == nil {
+		return nil, ErrEnvelopeUnknownKey
+	}
+
+	v, err := pub.VerifySignature(sig, envelopeSignedData(body))
+	if err != nil || !v {
+		return nil, ErrEnvelopeBadSignature
+	}
+
+	if !env.NotBefore.IsZero() && at.Before(env.NotBefore) {
+		return nil, ErrEnvelopeNotYetValid
+	} else if !env.ExpiresAt.IsZero() && !at.Before(env.ExpiresAt) {
+		return nil, ErrEnvelopeExpired
+	}
+
+	return env, nil
+}
+
+func envelopeSignedData(body []byte) []byte {
+	return append([]byte(envelopeContext), body...)
+}
+
+func appendTime(b []byte, t time.Time) []byte {
+	var v [8]byte
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(v[:], uint64(t.Unix()))
+	}
+	return append(b, v[:]...)
+}
+
+func readTime(b []byte) time.Time {
+	v := int64(binary.BigEndian.Uint64(b))
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.Unix(v, 0)
+}
@@ -0,0 +1,112 @@
+package ed25519
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func TestSignEnvelope(t *testing.T) {
+	// Setup
+	keyPair, _ := NewKeyPair()
+	issuedAt := time.Unix(1600000000, 0)
+
+	// Case 1: Should work
+	token, err := keyPair.SignEnvelope([]byte("payload"), Claims{KeyID: "signer-1", IssuedAt: issuedAt})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", token)
+
+	// Case 2: Should be deterministic for the same claims
+	again, _ := keyPair.SignEnvelope([]byte("payload"), Claims{KeyID: "signer-1", IssuedAt: issuedAt})
+	assert.Equal(t, token, again)
+
+	// Case 3: Should fail without a KeyID
+	token, err = keyPair.SignEnvelope([]byte("payload"), Claims{})
+	assert.Equal(t, "", token)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail when it expires before it is valid
+	token, err = keyPair.SignEnvelope([]byte("payload"), Claims{KeyID: "signer-1", NotBefore: issuedAt, ExpiresAt: issuedAt})
+	assert.Equal(t, "", token)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail without a private key
+	public, _ := LoadKeyPair(keyPair.PublicKey, nil)
+	token, err = public.SignEnvelope([]byte("payload"), Claims{KeyID: "signer-1"})
+	assert.Equal(t, "", token)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestVerifyEnvelope(t *testing.T) {
+	// Setup
+	keyPair, _ := NewKeyPair()
+	other, _ := NewKeyPair()
+	pub, _ := keyPair.Public()
+	otherPub, _ := other.Public()
+	keySet := KeySet{"signer-1": pub, "signer-2": otherPub}
+
+	now := time.Now()
+	claims := Claims{
+		KeyID:     "signer-1",
+		NotBefore: now.Add(-time.Minute),
+		ExpiresAt: now.Add(time.Hour),
+	}
+	token, _ := keyPair.SignEnvelope([]byte("payload"), claims)
+
+	// Case 1: Should work
+	env, err := keySet.VerifyEnvelope(token)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("payload"), env.Payload)
+	assert.Equal(t, "signer-1", env.KeyID)
+	assert.WithinDuration(t, now, env.IssuedAt, 2*time.Second)
+	assert.Equal(t, claims.ExpiresAt.Unix(), env.ExpiresAt.Unix())
+
+	// Case 2: Should fail after expiring
+	env, err = keySet.VerifyEnvelopeAt(token, now.Add(2*time.Hour))
+	assert.Nil(t, env)
+	assert.Equal(t, ErrEnvelopeExpired, err)
+
+	// Case 3: Should fail before being valid
+	env, err = keySet.VerifyEnvelopeAt(token, now.Add(-time.Hour))
+	assert.Nil(t, env)
+	assert.Equal(t, ErrEnvelopeNotYetValid, err)
+
+	// Case 4: Should fail with an unknown key
+	env, err = KeySet{"signer-2": otherPub}.VerifyEnvelope(token)
+	assert.Nil(t, env)
+	assert.Equal(t, ErrEnvelopeUnknownKey, err)
+
+	// Case 5: Should fail when the key ID points to another key
+	env, err = KeySet{"signer-1": otherPub}.VerifyEnvelope(token)
+	assert.Nil(t, env)
+	assert.Equal(t, ErrEnvelopeBadSignature, err)
+
+	// Case 6: Should fail with a tampered payload
+	b, _ := base64.RawURLEncoding.DecodeString(token)
+	b[len(b)-64-1] ^= 1
+	env, err = keySet.VerifyEnvelope(base64.RawURLEncoding.EncodeToString(b))
+	assert.Nil(t, env)
+	assert.Equal(t, ErrEnvelopeBadSignature, err)
+
+	// Case 7: Should fail with a malformed envelope
+	env, err = keySet.VerifyEnvelope("bm90IGFuIGVudmVsb3Bl")
+	assert.Nil(t, env)
+	assert.Equal(t, ErrEnvelopeMalformed, err)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestVerifyEnvelopeWithoutExpiry(t *testing.T) {
+	keyPair, _ := NewKeyPair()
+	pub, _ := keyPair.Public()
+
+	token, _ := keyPair.SignEnvelope(nil, Claims{KeyID: "signer-1"})
+
+	env, err := KeySet{"signer-1": pub}.VerifyEnvelopeAt(token, time.Now().Add(100*365*24*time.Hour))
+	assert.Nil(t, err)
+	assert.Empty(t, env.Payload)
+	assert.True(t, env.ExpiresAt.IsZero())
+	assert.True(t, env.NotBefore.IsZero())
+}
@@ -0,0 +1,525 @@
+package ed25519
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// MinisignDefaultOpsLimit is the scrypt opslimit used by minisign to encrypt secret keys
+	MinisignDefaultOpsLimit uint64 = 1 << 25
+	// MinisignDefaultMemLimit is the scrypt memlimit used by minisign to encrypt secret keys
+	MinisignDefaultMemLimit uint64 = 1 << 30
+
+	minisignUntrustedPrefix = "untrusted comment: "
+	minisignTrustedPrefix   = "trusted comment: "
+	minisignPublicKeyLen    = 42
+	minisignSecretKeyLen    = 158
+	minisignSignatureLen    = 74
+	minisignKeyStreamLen    = 104
+	minisignMaxCommentLen   = 8192 - len(minisignTrustedPrefix)
+
+	// Limits above these are rejected before running scrypt, a crafted key file
+	// could otherwise require TiB of memory or hours of work
+	minisignMaxOpsLimit uint64 = 1 << 30
+	minisignMaxMemLimit uint64 = 1 << 30
+	minisignMaxLogN            = 20
+	minisignMaxP        uint64 = 32
+)
+
+var (
+	minisignAlgorithm       = [2]byte{'E', 'd'}
+	minisignHashedAlgorithm = [2]byte{'E', 'D'}
+	minisignKDFAlgorithm    = [2]byte{'S', 'c'}
+	minisignChkAlgorithm    = [2]byte{'B', '2'}
+)
+
+// MinisignPublicKey represents a minisign public key
+type MinisignPublicKey struct {
+	KeyID     [8]byte
+	PublicKey []byte
+}
+
+// MinisignSecretKey represents a minisign secret key, an ed25519 KeyPair
+// identified by a key ID
+type MinisignSecretKey struct {
+	*KeyPair
+	KeyID [8]byte
+}
+
+// MinisignSignature represents a minisign detached signature
+type MinisignSignature struct {
+	UntrustedComment string
+	TrustedComment   string
+	Algorithm        [2]byte
+	KeyID            [8]byte
+	Signature        []byte
+	GlobalSignature  []byte
+}
+
+// NewMinisignSecretKey returns a minisign secret key for a KeyPair with a
+// random key ID
+func NewMinisignSecretKey(kp *KeyPair) (*MinisignSecretKey, error) {
+	const op = "ed25519.NewMinisignSecretKey"
+
+	if kp == nil || len(kp.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, ez.New(op, ez.EINVALID, "KeyPair must have a 64 byte PrivateKey", nil)
+	}
+
+	sk := &MinisignSecretKey{KeyPair: kp}
+	_, err := io.ReadFull(rand.Reader, sk.KeyID[:])
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the key ID", err)
+	}
+
+	return sk, nil
+}
+
+// KeyIDString returns the key ID in the hexadecimal format displayed by minisign
+func (pk *MinisignPublicKey) KeyIDString() string {
+	return minisignKeyIDString(pk.KeyID)
+}
+
+// ParseMinisignPublicKey parses a minisign public key file, or the single line
+// base64 public key that minisign accepts with the -P flag
+func ParseMinisignPublicKey(data []byte) (*MinisignPublicKey, error) {
+	const op = "ed25519.ParseMinisignPublicKey"
+
+	lines := minisignLines(data)
+	if len(lines) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Public key is empty", nil)
+	}
+
+	encoded := lines[0]
+	if strings.HasPrefix(encoded, minisignUntrustedPrefix) {
+		if len(lines) < 2 {
+			return nil, ez.New(op, ez.EINVALID, "Public key is incomplete", nil)
+		}
+		encoded = lines[1]
+	}
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(b) != minisignPublicKeyLen {
+		return nil, ez.New(op, ez.EINVALID, "Public key has an invalid encoding", err)
+	} else if !bytes.Equal(b[:2], minisignAlgorithm[:]) {
+		return nil, ez.New(op, ez.EINVALID, "Public key has an unsupported signature algorithm", nil)
+	}
+
+	pk := &MinisignPublicKey{PublicKey: append([]byte{}, b[10:]...)}
+	copy(pk.KeyID[:], b[2:10])
+
+	return pk, nil
+}
+
+// Marshal encodes the public key in the minisign public key file format
+func (pk *MinisignPublicKey) Marshal() []byte {
+	b := make([]byte, 0, minisignPublicKeyLen)
+	b = append(b, minisignAlgorithm[:]...)
+	b = append(b, pk.KeyID[:]...)
+	b = append(b, pk.PublicKey...)
+
+	comment := "minisign public key " + pk.KeyIDString()
+	return minisignFile(comment, base64.StdEncoding.EncodeToString(b))
+}
+
+// Verify reads a message from a reader and validates it against a minisign
+// signature. Prehashed signatures are verified in a streaming fashion
+func (pk *MinisignPublicKey) Verify(r io.Reader, sig *MinisignSignature) (bool, error) {
+	const op = "ed25519.MinisignPublicKey.Verify"
+
+	if len(pk.PublicKey) != ed25519.PublicKeySize {
+		return false, ez.New(op, ez.EINVALID, "Public key must be 32 bytes long", nil)
+	} else if sig == nil {
+		return false, ez.New(op, ez.EINVALID, "Signature can not be nil", nil)
+	} else if pk.KeyID != sig.KeyID {
+		return false, ez.New(op, ez.EINVALID, "Signature was created with a different key ID", nil)
+	}
+
+	var message []byte
+	var err error
+	switch sig.Algorithm {
+	case minisignHashedAlgorithm:
+		message, err = minisignPrehash(r)
+	case minisignAlgorithm:
+		message, err = io.ReadAll(r)
+	default:
+		return false, ez.New(op, ez.EINVALID, "Signature has an unsupported algorithm", nil)
+	}
+	if err != nil {
+		return false, ez.New(op, ez.EINTERNAL, "Error while reading the message", err)
+	}
+
+	if !ed25519.Verify(pk.PublicKey, message, sig.Signature) {
+		return false, nil
+	}
+
+	global := append(append([]byte{}, sig.Signature...), sig.TrustedComment...)
+	return ed25519.Verify(pk.PublicKey, global, sig.GlobalSignature), nil
+}
+
+// VerifyFile validates the signature of a file
+func (pk *MinisignPublicKey) VerifyFile(path string, sig *MinisignSignature) (bool, error) {
+	const op = "ed25519.MinisignPublicKey.VerifyFile"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, ez.New(op, ez.ENOTFOUND, "Could not open the file", err)
+	}
+	defer f.Close()
+
+	v, err := pk.Verify(f, sig)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	return v, nil
+}
+
+// Public returns the minisign public key of the secret key
+func (sk *MinisignSecretKey) Public() *MinisignPublicKey {
+	pub := ed25519.PrivateKey(sk.PrivateKey).Public().(ed25519.PublicKey)
+	return &MinisignPublicKey{KeyID: sk.KeyID, PublicKey: []byte(pub)}
+}
+
+// Sign reads a message from a reader and creates a prehashed minisign
+// signature. When the trusted comment is empty a timestamp is used
+func (sk *MinisignSecretKey) Sign(r io.Reader, trustedComment, untrustedComment string) (*MinisignSignature, error) {
+	const op = "ed25519.MinisignSecretKey.Sign"
+
+	if len(sk.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, ez.New(op, ez.EINVALID, "A signature can not be generated if the PrivateKey from the KeyPair is not defined", nil)
+	}
+
+	if trustedComment == "" {
+		trustedComment = fmt.Sprintf("timestamp:%d", time.Now().Unix())
+	}
+	if untrustedComment == "" {
+		untrustedComment = "signature from minisign secret key"
+	}
+
+	if strings.ContainsAny(trustedComment+untrustedComment, "\r\n") {
+		return nil, ez.New(op, ez.EINVALID, "Comments must fit on a single line", nil)
+	} else if len(trustedComment) > minisignMaxCommentLen {
+		return nil, ez.New(op, ez.EINVALID, "Trusted comment is too long", nil)
+	}
+
+	hash, err := minisignPrehash(r)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while reading the message", err)
+	}
+
+	signature, err := sk.KeyPair.Sign(hash)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	sig := &MinisignSignature{
+		UntrustedComment: untrustedComment,
+		TrustedComment:   trustedComment,
+		Algorithm:        minisignHashedAlgorithm,
+		KeyID:            sk.KeyID,
+		Signature:        signature,
+	}
+
+	global := append(append([]byte{}, sig.Signature...), trustedComment...)
+	sig.GlobalSignature, err = sk.KeyPair.Sign(global)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return sig, nil
+}
+
+// SignFile creates a prehashed minisign signature of a file, using the same
+// default trusted comment as the minisign tool
+func (sk *MinisignSecretKey) SignFile(path, trustedComment, untrustedComment string) (*MinisignSignature, error) {
+	const op = "ed25519.MinisignSecretKey.SignFile"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, ez.New(op, ez.ENOTFOUND, "Could not open the file", err)
+	}
+	defer f.Close()
+
+	if trustedComment == "" {
+		trustedComment = fmt.Sprintf("timestamp:%d\tfile:%s\thashed", time.Now().Unix(), filepath.Base(path))
+	}
+
+	sig, err := sk.Sign(f, trustedComment, untrustedComment)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return sig, nil
+}
+
+// ParseMinisignSecretKey parses a minisign secret key file, decrypting it with
+// the password if the key is encrypted. Keys with scrypt limits above 1GiB of
+// memory or 2^30 operations are rejected
+func ParseMinisignSecretKey(data []byte, password string) (*MinisignSecretKey, error) {
+	const op = "ed25519.ParseMinisignSecretKey"
+
+	lines := minisignLines(data)
+	if len(lines) < 2 {
+		return nil, ez.New(op, ez.EINVALID, "Secret key is incomplete", nil)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(b) != minisignSecretKeyLen {
+		return nil, ez.New(op, ez.EINVALID, "Secret key has an invalid encoding", err)
+	}
+	defer keys.Wipe(b)
+
+	if !bytes.Equal(b[:2], minisignAlgorithm[:]) {
+		return nil, ez.New(op, ez.EINVALID, "Secret key has an unsupported signature algorithm", nil)
+	} else if !bytes.Equal(b[4:6], minisignChkAlgorithm[:]) {
+		return nil, ez.New(op, ez.EINVALID, "Secret key has an unsupported checksum algorithm", nil)
+	}
+
+	secret := b[54:]
+	encrypted := bytes.Equal(b[2:4], minisignKDFAlgorithm[:])
+	switch {
+	case encrypted:
+		opsLimit := binary.LittleEndian.Uint64(b[38:46])
+		memLimit := binary.LittleEndian.Uint64(b[46:54])
+
+		stream, err := minisignKeyStream(password, b[6:38], opsLimit, memLimit)
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		defer keys.Wipe(stream)
+
+		for i := range secret {
+			secret[i] ^= stream[i]
+		}
+	case b[2] != 0 || b[3] != 0:
+		return nil, ez.New(op, ez.EINVALID, "Secret key has an unsupported KDF algorithm", nil)
+	}
+
+	// Unencrypted keys written by some tools leave the checksum empty
+	checksum := minisignChecksum(secret[:72])
+	if encrypted || !bytes.Equal(secret[72:], make([]byte, 32)) {
+		if subtle.ConstantTimeCompare(checksum, secret[72:]) != 1 {
+			return nil, ez.New(op, ez.EINVALID, "Could not decrypt secret key, invalid password", nil)
+		}
+	}
+
+	if !bytes.Equal(ed25519.NewKeyFromSeed(secret[8:40])[32:], secret[40:72]) {
+		return nil, ez.New(op, ez.EINVALID, "Secret key is corrupted", nil)
+	}
+
+	priv := append([]byte{}, secret[8:72]...)
+	pub := append([]byte{}, secret[40:72]...)
+
+	sk := &MinisignSecretKey{KeyPair: &KeyPair{keys.NewKeyPair(pub, priv, keys.ED25519)}}
+	copy(sk.KeyID[:], secret[:8])
+
+	return sk, nil
+}
+
+// MarshalEncrypted encodes the secret key in the minisign secret key file
+// format, encrypted with a password using the given scrypt limits. The limits
+// can not exceed MinisignDefaultMemLimit of memory or 2^30 operations
+func (sk *MinisignSecretKey) MarshalEncrypted(password string, opsLimit, memLimit uint64) ([]byte, error) {
+	const op = "ed25519.MinisignSecretKey.MarshalEncrypted"
+
+	if len(sk.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, ez.New(op, ez.EINVALID, "KeyPair must have a 64 byte PrivateKey", nil)
+	}
+
+	b := make([]byte, 0, minisignSecretKeyLen)
+	b = append(b, minisignAlgorithm[:]...)
+	b = append(b, minisignKDFAlgorithm[:]...)
+	b = append(b, minisignChkAlgorithm[:]...)
+
+	salt := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the salt", err)
+	}
+	b = append(b, salt...)
+
+	var limits [16]byte
+	binary.LittleEndian.PutUint64(limits[:8], opsLimit)
+	binary.LittleEndian.PutUint64(limits[8:], memLimit)
+	b = append(b, limits[:]...)
+
+	secret := make([]byte, 0, minisignKeyStreamLen)
+	secret = append(secret, sk.KeyID[:]...)
+	secret = append(secret, sk.PrivateKey...)
+	secret = append(secret, minisignChecksum(secret)...)
+	defer keys.Wipe(secret)
+
+	stream, err := minisignKeyStream(password, salt, opsLimit, memLimit)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer keys.Wipe(stream)
+
+	for i := range secret {
+		b = append(b, secret[i]^stream[i])
+	}
+
+	return minisignFile("minisign encrypted secret key", base64.StdEncoding.EncodeToString(b)), nil
+}
+
+// ParseMinisignSignature parses a minisign signature file
+func ParseMinisignSignature(data []byte) (*MinisignSignature, error) {
+	const op = "ed25519.ParseMinisignSignature"
+
+	lines := minisignLines(data)
+	if len(lines) < 4 {
+		return nil, ez.New(op, ez.EINVALID, "Signature is incomplete", nil)
+	} else if !strings.HasPrefix(lines[0], minisignUntrustedPrefix) {
+		return nil, ez.New(op, ez.EINVALID, "Signature is missing the untrusted comment", nil)
+	} else if !strings.HasPrefix(lines[2], minisignTrustedPrefix) {
+		return nil, ez.New(op, ez.EINVALID, "Signature is missing the trusted comment", nil)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(b) != minisignSignatureLen {
+		return nil, ez.New(op, ez.EINVALID, "Signature has an invalid encoding", err)
+	}
+
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return nil, ez.New(op, ez.EINVALID, "Global signature has an invalid encoding", err)
+	}
+
+	sig := &MinisignSignature{
+		UntrustedComment: strings.TrimPrefix(lines[0], minisignUntrustedPrefix),
+		TrustedComment:   strings.TrimPrefix(lines[2], minisignTrustedPrefix),
+		Signature:        b[10:],
+		GlobalSignature:  global,
+	}
+	copy(sig.Algorithm[:], b[:2])
+	copy(sig.KeyID[:], b[2:10])
+
+	return sig, nil
+}
+
+// Marshal encodes the signature in the minisign signature file format
+func (sig *MinisignSignature) Marshal() []byte {
+	b := make([]byte, 0, minisignSignatureLen)
+	b = append(b, sig.Algorithm[:]...)
+	b = append(b, sig.KeyID[:]...)
+	b = append(b, sig.Signature...)
+
+	var buf bytes.Buffer
+	buf.Write(minisignFile(sig.UntrustedComment, base64.StdEncoding.EncodeToString(b)))
+	buf.WriteString(minisignTrustedPrefix + sig.TrustedComment + "\n")
+	buf.WriteString(base64.StdEncoding.EncodeToString(sig.GlobalSignature) + "\n")
+
+	return buf.Bytes()
+}
+
+func minisignPrehash(r io.Reader) ([]byte, error) {
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+func minisignChecksum(b []byte) []byte {
+	h, _ := blake2b.New256(nil)
+	h.Write(minisignAlgorithm[:])
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// minisignKeyStream derives the stream used to encrypt secret keys, mapping
+// the libsodium opslimit and memlimit to scrypt parameters like minisign does
+func minisignKeyStream(password string, salt []byte, opsLimit, memLimit uint64) ([]byte, error) {
+	const op = "ed25519.minisignKeyStream"
+
+	if opsLimit > minisignMaxOpsLimit || memLimit > minisignMaxMemLimit {
+		return nil, ez.New(op, ez.EINVALID, "Scrypt limits exceed 1GiB of memory or 2^30 operations", nil)
+	}
+
+	if opsLimit < 32768 {
+		opsLimit = 32768
+	}
+
+	logN := func(maxN uint64) uint {
+		n := uint(1)
+		for n < 63 && uint64(1)<<n <= maxN/2 {
+			n++
+		}
+		return n
+	}
+
+	r, p := uint64(8), uint64(1)
+	var n uint
+	if opsLimit < memLimit/32 {
+		n = logN(opsLimit / (r * 4))
+	} else {
+		n = logN(memLimit / (r * 128))
+		maxRP := (opsLimit / 4) / (uint64(1) << n)
+		if maxRP > 0x3fffffff {
+			maxRP = 0x3fffffff
+		}
+		p = maxRP / r
+	}
+
+	if n < 1 || n > minisignMaxLogN || p == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Invalid scrypt limits", nil)
+	}
+
+	// scrypt allocates 128*r*N bytes for its table and 128*r*p for its blocks,
+	// small memLimits with large opsLimits map to a large p instead of a large N
+	memory := 128 * r * ((uint64(1) << n) + p)
+	if p > minisignMaxP || memory > minisignMaxMemLimit+128*r*minisignMaxP {
+		return nil, ez.New(op, ez.EINVALID, "Scrypt parameters exceed 1GiB of memory", nil)
+	}
+
+	stream, err := scrypt.Key([]byte(password), salt, 1<<n, int(r), int(p), minisignKeyStreamLen)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not derive the secret key stream", err)
+	}
+
+	return stream, nil
+}
+
+func minisignKeyIDString(id [8]byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id[:]))
+}
+
+func minisignLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), 16384)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func minisignFile(comment, encoded string) []byte {
+	return []byte(minisignUntrustedPrefix + comment + "\n" + encoded + "\n")
+}
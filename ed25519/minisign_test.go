@@ -0,0 +1,228 @@
+package ed25519
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+// Keys and signatures generated with the minisign tool
+const (
+	minisignTestPublicKey = "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3"
+
+	minisignTestLegacySignature = "untrusted comment: signature from minisign secret key\n" +
+		"RWQf6LRCGA9i59SLOFxz6NxvASXDJeRtuZykwQepbDEGt87ig1BNpWaVWuNrm73YiIiJbq71Wi+dP9eKL8OC351vwIasSSbXxwA=\n" +
+		"trusted comment: timestamp:1635442742\tfile:test\n" +
+		"0YteLgV960ia80vnA/fHbvkyjl/IoP/HNOCaZfrF0CdhAlp7ok+Tpkya+VpWPX5C/Is3q8a/kEDSY7fBmmgJCg==\n"
+
+	minisignTestHashedSignature = "untrusted comment: signature from minisign secret key\n" +
+		"RUQf6LRCGA9i559r3g7V1qNyJDApGip8MfqcadIgT9CuhV3EMhHoN1mGTkUidF/z7SrlQgXdy8ofjb7bNJJylDOocrCo8KLzZwo=\n" +
+		"trusted comment: timestamp:1635443258\tfile:test\thashed\n" +
+		"/cj37GK60vryibFn+ftOgbCvW9NKhKYgjVpFFQUcWPAnjO23wrvVDTt7cloNC06maoBli9q6qwZDXXoaxweICQ==\n"
+
+	minisignTestUnencryptedSecretKey = "untrusted comment: minisign encrypted secret key\n" +
+		"RWQAAEIyAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAOItWpGuGQbG4C9WXaxEYLgZ2xxuqfbuZmDgAhQ8Unot8t7SyxZ0nVh0gESesJ6Ay57fGFJ9T1ajVmanT7MFMCCDbPZ8uqDcSAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n"
+
+	minisignTestUnencryptedPublicKey = "untrusted comment: minisign public key B141866BA4568B38\n" +
+		"RWQ4i1aka4ZBsR0gESesJ6Ay57fGFJ9T1ajVmanT7MFMCCDbPZ8uqDcS\n"
+
+	minisignTestEncryptedSecretKey = "untrusted comment: minisign encrypted secret key\n" +
+		"RWRTY0IyxYlIT2FS5i8PqThE9swBemvY94JDIMqo75UBK3XO/aUAAAACAAAAAAAAAEAAAAAAUhlw8nsT1tuVUekS6Je3iUwoWFdb1xiLonO35G66RiVvM/QgrBtnDa0Dhbt7H3oYMh4aFLiNxMs24gzXqHVsvRVthMeF08fN8r6siRdBpiBZ36B7rox2lmYIYgg5T8qt7tOxo9doAxk=\n"
+
+	minisignTestEncryptedPublicKey = "untrusted comment: minisign public key 9149E58DCF22FFC1\n" +
+		"RWTB/yLPjeVJkXKtzk1nZI0TU+fZPqEaIzg1ABHwfnI8pZNWtifIpWBq\n"
+)
+
+func TestParseMinisignPublicKey(t *testing.T) {
+	// Case 1: Should work with a public key file
+	pk, err := ParseMinisignPublicKey([]byte(minisignTestUnencryptedPublicKey))
+	assert.Nil(t, err)
+	assert.Equal(t, "B141866BA4568B38", pk.KeyIDString())
+	assert.Equal(t, minisignTestUnencryptedPublicKey, string(pk.Marshal()))
+
+	// Case 2: Should work with a single line public key
+	pk, err = ParseMinisignPublicKey([]byte(minisignTestPublicKey))
+	assert.Nil(t, err)
+	assert.Len(t, pk.PublicKey, 32)
+
+	// Case 3: Should fail with an invalid public key
+	pk, err = ParseMinisignPublicKey([]byte("untrusted comment: nope\nAAAA\n"))
+	assert.Nil(t, pk)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestParseMinisignSignature(t *testing.T) {
+	// Case 1: Should work
+	sig, err := ParseMinisignSignature([]byte(minisignTestHashedSignature))
+	assert.Nil(t, err)
+	assert.Equal(t, "signature from minisign secret key", sig.UntrustedComment)
+	assert.Equal(t, "timestamp:1635443258\tfile:test\thashed", sig.TrustedComment)
+	assert.Equal(t, minisignTestHashedSignature, string(sig.Marshal()))
+
+	// Case 2: Should fail without a trusted comment
+	lines := strings.Split(minisignTestHashedSignature, "\n")
+	sig, err = ParseMinisignSignature([]byte(strings.Join([]string{lines[0], lines[1], "timestamp", lines[3]}, "\n")))
+	assert.Nil(t, sig)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestMinisignVerify(t *testing.T) {
+	// Setup
+	pk, _ := ParseMinisignPublicKey([]byte(minisignTestPublicKey))
+	legacy, _ := ParseMinisignSignature([]byte(minisignTestLegacySignature))
+	hashed, _ := ParseMinisignSignature([]byte(minisignTestHashedSignature))
+
+	// Case 1: Should work with a legacy signature
+	v, err := pk.Verify(strings.NewReader("test"), legacy)
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 2: Should work with a prehashed signature
+	v, err = pk.Verify(strings.NewReader("test"), hashed)
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 3: Should FAIL with another message
+	v, err = pk.Verify(strings.NewReader("tset"), hashed)
+	assert.Nil(t, err)
+	assert.False(t, v)
+
+	// Case 4: Should FAIL with a tampered trusted comment
+	hashed.TrustedComment = "timestamp:1635443258\tfile:evil\thashed"
+	v, err = pk.Verify(strings.NewReader("test"), hashed)
+	assert.Nil(t, err)
+	assert.False(t, v)
+
+	// Case 5: Should fail with a different key ID
+	other, _ := ParseMinisignPublicKey([]byte(minisignTestUnencryptedPublicKey))
+	v, err = other.Verify(strings.NewReader("test"), legacy)
+	assert.False(t, v)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestParseMinisignSecretKey(t *testing.T) {
+	// Case 1: Should work with an unencrypted secret key
+	sk, err := ParseMinisignSecretKey([]byte(minisignTestUnencryptedSecretKey), "")
+	assert.Nil(t, err)
+	assert.Equal(t, minisignTestUnencryptedPublicKey, string(sk.Public().Marshal()))
+
+	// Case 2: Should work with an encrypted secret key
+	if testing.Short() {
+		t.Skip("minisign default scrypt limits require 1GiB of memory")
+	}
+
+	sk, err = ParseMinisignSecretKey([]byte(minisignTestEncryptedSecretKey), "testpass")
+	assert.Nil(t, err)
+	assert.Equal(t, minisignTestEncryptedPublicKey, string(sk.Public().Marshal()))
+}
+
+func TestMinisignMarshalEncrypted(t *testing.T) {
+	// Setup
+	kp, _ := NewKeyPair()
+	sk, err := NewMinisignSecretKey(kp)
+	assert.Nil(t, err)
+
+	// Case 1: Should work
+	data, err := sk.MarshalEncrypted("hunter2", 1<<15, 1<<20)
+	assert.Nil(t, err)
+
+	parsed, err := ParseMinisignSecretKey(data, "hunter2")
+	assert.Nil(t, err)
+	assert.Equal(t, sk.KeyID, parsed.KeyID)
+	assert.Equal(t, sk.PrivateKey, parsed.PrivateKey)
+	assert.Equal(t, sk.KeyPair.PublicKey, parsed.KeyPair.PublicKey)
+
+	// Case 2: Should fail with a wrong password
+	parsed, err = ParseMinisignSecretKey(data, "hunter3")
+	assert.Nil(t, parsed)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should not write limits above the ceiling
+	_, err = sk.MarshalEncrypted("hunter2", 1<<15, minisignMaxMemLimit+1)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestParseMinisignSecretKeyLimits(t *testing.T) {
+	// Setup
+	lines := strings.Split(minisignTestEncryptedSecretKey, "\n")
+	b, err := base64.StdEncoding.DecodeString(lines[1])
+	assert.Nil(t, err)
+
+	withLimits := func(opsLimit, memLimit uint64) []byte {
+		modified := append([]byte{}, b...)
+		binary.LittleEndian.PutUint64(modified[38:46], opsLimit)
+		binary.LittleEndian.PutUint64(modified[46:54], memLimit)
+		return []byte(lines[0] + "\n" + base64.StdEncoding.EncodeToString(modified) + "\n")
+	}
+
+	// Case 1: Should fail with an oversized memLimit before running scrypt
+	sk, err := ParseMinisignSecretKey(withLimits(MinisignDefaultOpsLimit, 1<<40), "testpass")
+	assert.Nil(t, sk)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 2: Should fail with an oversized opsLimit before running scrypt
+	sk, err = ParseMinisignSecretKey(withLimits(1<<50, MinisignDefaultMemLimit), "testpass")
+	assert.Nil(t, sk)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail when the limits map to a large p instead of a large N
+	sk, err = ParseMinisignSecretKey(withLimits(1<<30, 0), "testpass")
+	assert.Nil(t, sk)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestMinisignSign(t *testing.T) {
+	// Setup
+	sk, _ := ParseMinisignSecretKey([]byte(minisignTestUnencryptedSecretKey), "")
+	pk := sk.Public()
+	msg := bytes.Repeat([]byte("release artifact"), 1<<16)
+
+	// Case 1: Should work
+	sig, err := sk.Sign(bytes.NewReader(msg), "timestamp:0\tfile:release.tar.gz\thashed", "")
+	assert.Nil(t, err)
+
+	parsed, err := ParseMinisignSignature(sig.Marshal())
+	assert.Nil(t, err)
+	assert.Equal(t, sig, parsed)
+
+	v, err := pk.Verify(bytes.NewReader(msg), parsed)
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 2: Should fail with multi line comments
+	sig, err = sk.Sign(bytes.NewReader(msg), "first\nsecond", "")
+	assert.Nil(t, sig)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestMinisignSignFile(t *testing.T) {
+	// Setup
+	dir, _ := os.MkdirTemp("", "minisign")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "release.tar.gz")
+	os.WriteFile(path, []byte("release artifact"), 0600)
+
+	kp, _ := NewKeyPair()
+	sk, _ := NewMinisignSecretKey(kp)
+
+	// Case 1: Should work
+	sig, err := sk.SignFile(path, "", "")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(sig.TrustedComment, "\tfile:release.tar.gz\thashed"))
+
+	v, err := sk.Public().VerifyFile(path, sig)
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 2: Should fail with a missing file
+	v, err = sk.Public().VerifyFile(filepath.Join(dir, "missing"), sig)
+	assert.False(t, v)
+	assert.Equal(t, ez.ENOTFOUND, ez.ErrorCode(err))
+}
@@ -0,0 +1,551 @@
+package ed25519
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vanclief/ez"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	sshSigMagic      = "SSHSIG"
+	sshSigVersion    = 1
+	sshSigBegin      = "-----BEGIN SSH SIGNATURE-----"
+	sshSigEnd        = "-----END SSH SIGNATURE-----"
+	sshSigLineLength = 70
+	sshKeyType       = "ssh-ed25519"
+)
+
+// SSHSignature represents an SSHSIG signature as created by ssh-keygen -Y sign
+type SSHSignature struct {
+	PublicKey     []byte
+	Namespace     string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// AllowedSigner represents an entry of an OpenSSH allowed_signers file
+type AllowedSigner struct {
+	Principals    []string
+	Namespaces    []string
+	CertAuthority bool
+	ValidAfter    time.Time
+	ValidBefore   time.Time
+	PublicKey     []byte
+}
+
+// AllowedSigners represents the ed25519 entries of an OpenSSH allowed_signers file
+type AllowedSigners []*AllowedSigner
+
+// SignSSH reads a message from a reader and creates an armored SSHSIG
+// signature for a namespace that can be verified with ssh-keygen -Y verify
+func (kp *KeyPair) SignSSH(r io.Reader, namespace string) ([]byte, error) {
+	const op = "ed25519.SignSSH"
+
+	if len(kp.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, ez.New(op, ez.EINVALID, "A signature can not be generated if the PrivateKey from the KeyPair is not defined", nil)
+	} else if namespace == "" {
+		return nil, ez.New(op, ez.EINVALID, "Namespace can not be empty", nil)
+	}
+
+	h, err := sshSigHash(r, "sha512")
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	sig := &SSHSignature{
+		PublicKey:     []byte(ed25519.PrivateKey(kp.PrivateKey).Public().(ed25519.PublicKey)),
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+	}
+	sig.Signature, err = kp.Sign(sig.signedData(h))
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return sig.Marshal(), nil
+}
+
+// VerifySSH validates an armored SSHSIG signature of a message against the
+// PublicKey of the KeyPair
+func (kp *KeyPair) VerifySSH(r io.Reader, namespace string, armored []byte) (bool, error) {
+	const op = "ed25519.VerifySSH"
+
+	if len(kp.PublicKey) != ed25519.PublicKeySize {
+		return false, ez.New(op, ez.EINVALID, "A signature can not be verified if the PublicKey from the KeyPair is not defined", nil)
+	}
+
+	sig, err := ParseSSHSignature(armored)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	if !bytes.Equal(sig.PublicKey, kp.PublicKey) {
+		return false, nil
+	}
+
+	v, err := sig.verify(r, namespace)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	return v, nil
+}
+
+// ParseSSHSignature parses an armored SSHSIG signature
+func ParseSSHSignature(armored []byte) (*SSHSignature, error) {
+	const op = "ed25519.ParseSSHSignature"
+
+	text := strings.TrimSpace(string(armored))
+	if !strings.HasPrefix(text, sshSigBegin) || !strings.HasSuffix(text, sshSigEnd) {
+		return nil, ez.New(op, ez.EINVALID, "Signature is not an armored SSH signature", nil)
+	}
+
+	body := strings.Join(strings.Fields(text[len(sshSigBegin):len(text)-len(sshSigEnd)]), "")
+	blob, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Signature has an invalid encoding", err)
+	}
+
+	if !bytes.HasPrefix(blob, []byte(sshSigMagic)) {
+		return nil, ez.New(op, ez.EINVALID, "Signature is missing the SSHSIG preamble", nil)
+	}
+	r := &sshReader{buf: blob[len(sshSigMagic):]}
+
+	version := r.uint32()
+	publicKey := r.bytes()
+	namespace := r.bytes()
+	r.bytes() // reserved
+	hashAlgorithm := r.bytes()
+	signature := r.bytes()
+	if r.err || len(r.buf) != 0 {
+		return nil, ez.New(op, ez.EINVALID, "Signature is malformed", nil)
+	} else if version != sshSigVersion {
+		return nil, ez.New(op, ez.EINVALID, "Signature has an unsupported version", nil)
+	}
+
+	pub, err := sshParseKey(publicKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	sr := &sshReader{buf: signature}
+	sigType := string(sr.bytes())
+	rawSig := sr.bytes()
+	if sr.err || len(sr.buf) != 0 || sigType != sshKeyType || len(rawSig) != ed25519.SignatureSize {
+		return nil, ez.New(op, ez.EINVALID, "Signature is not a valid ssh-ed25519 signature", nil)
+	}
+
+	return &SSHSignature{
+		PublicKey:     pub,
+		Namespace:     string(namespace),
+		HashAlgorithm: string(hashAlgorithm),
+		Signature:     rawSig,
+	}, nil
+}
+
+// Marshal encodes the signature in the armored SSHSIG format
+func (sig *SSHSignature) Marshal() []byte {
+	w := &sshWriter{}
+	w.raw([]byte(sshSigMagic))
+	w.uint32(sshSigVersion)
+	w.bytes(sshMarshalKey(sig.PublicKey))
+	w.bytes([]byte(sig.Namespace))
+	w.bytes(nil)
+	w.bytes([]byte(sig.HashAlgorithm))
+
+	sw := &sshWriter{}
+	sw.bytes([]byte(sshKeyType))
+	sw.bytes(sig.Signature)
+	w.bytes(sw.buf)
+
+	encoded := base64.StdEncoding.EncodeToString(w.buf)
+
+	var buf bytes.Buffer
+	buf.WriteString(sshSigBegin + "\n")
+	for len(encoded) > sshSigLineLength {
+		buf.WriteString(encoded[:sshSigLineLength] + "\n")
+		encoded = encoded[sshSigLineLength:]
+	}
+	buf.WriteString(encoded + "\n")
+	buf.WriteString(sshSigEnd + "\n")
+
+	return buf.Bytes()
+}
+
+func (sig *SSHSignature) verify(r io.Reader, namespace string) (bool, error) {
+	const op = "ed25519.SSHSignature.verify"
+
+	if sig.Namespace != namespace {
+		return false, ez.New(op, ez.EINVALID, "Signature was created for a different namespace", nil)
+	}
+
+	h, err := sshSigHash(r, sig.HashAlgorithm)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	return ed25519.Verify(sig.PublicKey, sig.signedData(h), sig.Signature), nil
+}
+
+func (sig *SSHSignature) signedData(h []byte) []byte {
+	w := &sshWriter{}
+	w.raw([]byte(sshSigMagic))
+	w.bytes([]byte(sig.Namespace))
+	w.bytes(nil)
+	w.bytes([]byte(sig.HashAlgorithm))
+	w.bytes(h)
+	return w.buf
+}
+
+// ParseAllowedSigners parses an OpenSSH allowed_signers file. Entries for key
+// types other than ssh-ed25519 are skipped
+func ParseAllowedSigners(data []byte) (AllowedSigners, error) {
+	const op = "ed25519.ParseAllowedSigners"
+
+	var signers AllowedSigners
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		signer, err := parseAllowedSigner(line)
+		if err != nil {
+			return nil, ez.New(op, ez.EINVALID, "Invalid allowed signer on line "+strconv.Itoa(n+1), err)
+		} else if signer != nil {
+			signers = append(signers, signer)
+		}
+	}
+
+	return signers, nil
+}
+
+// Verify validates an armored SSHSIG signature of a message for a principal
+// and namespace, like ssh-keygen -Y verify does with an allowed_signers file
+func (as AllowedSigners) Verify(r io.Reader, principal, namespace string, armored []byte) (bool, error) {
+	const op = "ed25519.AllowedSigners.Verify"
+
+	sig, err := ParseSSHSignature(armored)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	now := time.Now()
+	allowed := false
+	for _, signer := range as {
+		if signer.Allows(sig.PublicKey, principal, namespace, now) {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return false, ez.New(op, ez.ENOTAUTHORIZED, "Signer is not allowed for the principal and namespace", nil)
+	}
+
+	v, err := sig.verify(r, namespace)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	return v, nil
+}
+
+// Allows reports whether the entry authorizes a public key to sign for a
+// principal and namespace at a given time
+func (s *AllowedSigner) Allows(publicKey []byte, principal, namespace string, at time.Time) bool {
+	if s.CertAuthority || !bytes.Equal(s.PublicKey, publicKey) {
+		return false
+	} else if !s.ValidAfter.IsZero() && at.Before(s.ValidAfter) {
+		return false
+	} else if !s.ValidBefore.IsZero() && !at.Before(s.ValidBefore) {
+		return false
+	} else if !sshMatchPatternList(principal, s.Principals) {
+		return false
+	}
+
+	return len(s.Namespaces) == 0 || sshMatchPatternList(namespace, s.Namespaces)
+}
+
+func parseAllowedSigner(line string) (*AllowedSigner, error) {
+	const op = "ed25519.parseAllowedSigner"
+
+	fields := sshSplitFields(line)
+	if len(fields) < 3 {
+		return nil, ez.New(op, ez.EINVALID, "Entry must have principals, a key type and a key", nil)
+	}
+
+	signer := &AllowedSigner{Principals: strings.Split(unquote(fields[0]), ",")}
+	fields = fields[1:]
+
+	if !sshIsKeyType(fields[0]) {
+		for _, option := range sshSplitOptions(fields[0]) {
+			name, value := option, ""
+			if i := strings.Index(option, "="); i >= 0 {
+				name, value = option[:i], unquote(option[i+1:])
+			}
+
+			var err error
+			switch strings.ToLower(name) {
+			case "cert-authority":
+				signer.CertAuthority = true
+			case "namespaces":
+				signer.Namespaces = strings.Split(value, ",")
+			case "valid-after":
+				signer.ValidAfter, err = sshParseTime(value)
+			case "valid-before":
+				signer.ValidBefore, err = sshParseTime(value)
+			default:
+				return nil, ez.New(op, ez.EINVALID, "Unsupported option "+name, nil)
+			}
+			if err != nil {
+				return nil, ez.Wrap(op, err)
+			}
+		}
+		fields = fields[1:]
+	}
+
+	if len(fields) < 2 {
+		return nil, ez.New(op, ez.EINVALID, "Entry must have a key type and a key", nil)
+	} else if fields[0] != sshKeyType {
+		return nil, nil
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Key has an invalid encoding", err)
+	}
+
+	signer.PublicKey, err = sshParseKey(blob)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return signer, nil
+}
+
+func sshSigHash(r io.Reader, algorithm string) ([]byte, error) {
+	const op = "ed25519.sshSigHash"
+
+	var h hash.Hash
+	switch algorithm {
+	case "sha512":
+		h = sha512.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, ez.New(op, ez.EINVALID, "Unsupported hash algorithm", nil)
+	}
+
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while reading the message", err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+func sshParseKey(blob []byte) ([]byte, error) {
+	const op = "ed25519.sshParseKey"
+
+	r := &sshReader{buf: blob}
+	keyType := string(r.bytes())
+	key := r.bytes()
+	if r.err || len(r.buf) != 0 {
+		return nil, ez.New(op, ez.EINVALID, "Public key is malformed", nil)
+	} else if keyType != sshKeyType || len(key) != ed25519.PublicKeySize {
+		return nil, ez.New(op, ez.EINVALID, "Public key is not an ssh-ed25519 key", nil)
+	}
+
+	return key, nil
+}
+
+func sshMarshalKey(publicKey []byte) []byte {
+	w := &sshWriter{}
+	w.bytes([]byte(sshKeyType))
+	w.bytes(publicKey)
+	return w.buf
+}
+
+// sshParseTime parses the YYYYMMDD[HHMM[SS]][Z] timestamps used by allowed_signers
+func sshParseTime(value string) (time.Time, error) {
+	const op = "ed25519.sshParseTime"
+
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") || strings.HasSuffix(value, "z") {
+		loc = time.UTC
+		value = value[:len(value)-1]
+	}
+
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok {
+		return time.Time{}, ez.New(op, ez.EINVALID, "Invalid timestamp "+value, nil)
+	}
+
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, ez.New(op, ez.EINVALID, "Invalid timestamp "+value, err)
+	}
+
+	return t, nil
+}
+
+func sshIsKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-") || strings.HasPrefix(s, "sk-")
+}
+
+// sshSplitFields splits a line on whitespace, keeping quoted sections together
+func sshSplitFields(line string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted := false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			current.WriteRune(c)
+		case (c == ' ' || c == '\t') && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// sshSplitOptions splits an options field on commas outside of quotes
+func sshSplitOptions(field string) []string {
+	var options []string
+	quoted := false
+	start := 0
+	for i, c := range field {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ',' && !quoted {
+			options = append(options, field[start:i])
+			start = i + 1
+		}
+	}
+	return append(options, field[start:])
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// sshMatchPatternList matches a value against OpenSSH style patterns, where a
+// negated pattern that matches always rejects the value
+func sshMatchPatternList(value string, patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		negated := strings.HasPrefix(pattern, "!")
+		if negated {
+			pattern = pattern[1:]
+		}
+
+		if sshMatchPattern(value, pattern) {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// sshMatchPattern matches a value against a pattern with * and ? wildcards
+func sshMatchPattern(value, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = pattern[1:]
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(value); i++ {
+				if sshMatchPattern(value[i:], pattern) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || value[0] != pattern[0] {
+				return false
+			}
+		}
+		value, pattern = value[1:], pattern[1:]
+	}
+	return value == ""
+}
+
+// sshReader decodes the SSH wire format described in RFC 4251
+type sshReader struct {
+	buf []byte
+	err bool
+}
+
+func (r *sshReader) uint32() uint32 {
+	if len(r.buf) < 4 {
+		r.err = true
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *sshReader) bytes() []byte {
+	n := r.uint32()
+	if r.err || uint32(len(r.buf)) < n {
+		r.err = true
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// sshWriter encodes the SSH wire format described in RFC 4251
+type sshWriter struct {
+	buf []byte
+}
+
+func (w *sshWriter) raw(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+func (w *sshWriter) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *sshWriter) bytes(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.buf = append(w.buf, b...)
+}
@@ -0,0 +1,164 @@
+package ed25519
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/utils"
+)
+
+// Key and signature generated with ssh-keygen -Y sign -n file
+const (
+	sshTestPrivateKey = "0x2f4b54276c83432ad3faaf4fc3d5e13c5458d7b2820de8d8e860181f651d50c3a495eb1ccf0e9f7aa12b1e1ccffc2fd3b8bd582b1bf5c69f40908bcf24945ef7"
+	sshTestPublicKey  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKSV6xzPDp96oSseHM/8L9O4vVgrG/XGn0CQi88klF73"
+	sshTestMessage    = "hello sshsig\n"
+	sshTestSignature  = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgpJXrHM8On3qhKx4cz/wv07i9WC
+sb9cafQJCLzySUXvcAAAAEZmlsZQAAAAAAAAAGc2hhNTEyAAAAUwAAAAtzc2gtZWQyNTUx
+OQAAAEClEZhrz82HxGeplaTkc8mx0I+C7MDHPDLv1UWsEaeurN+SjECvc/1uTjuUh/3tI1
++4IfnWqL2XeBdterHhOlEH
+-----END SSH SIGNATURE-----
+`
+)
+
+func sshTestKeyPair() *KeyPair {
+	priv, _ := utils.HexToBytes(sshTestPrivateKey)
+	kp, _ := LoadKeyPair(priv[32:], priv)
+	return kp
+}
+
+func TestSignSSH(t *testing.T) {
+	// Setup
+	kp := sshTestKeyPair()
+
+	// Case 1: Should give the same result as ssh-keygen
+	sig, err := kp.SignSSH(strings.NewReader(sshTestMessage), "file")
+	assert.Nil(t, err)
+	assert.Equal(t, sshTestSignature, string(sig))
+
+	// Case 2: Should fail without a namespace
+	sig, err = kp.SignSSH(strings.NewReader(sshTestMessage), "")
+	assert.Nil(t, sig)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail without a private key
+	public, _ := LoadKeyPair(kp.PublicKey, nil)
+	sig, err = public.SignSSH(strings.NewReader(sshTestMessage), "file")
+	assert.Nil(t, sig)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestVerifySSH(t *testing.T) {
+	// Setup
+	kp := sshTestKeyPair()
+	other, _ := NewKeyPair()
+
+	// Case 1: Should work with an ssh-keygen signature
+	v, err := kp.VerifySSH(strings.NewReader(sshTestMessage), "file", []byte(sshTestSignature))
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 2: Should FAIL with another message
+	v, err = kp.VerifySSH(strings.NewReader("hello sshsig"), "file", []byte(sshTestSignature))
+	assert.Nil(t, err)
+	assert.False(t, v)
+
+	// Case 3: Should FAIL with another key pair
+	v, err = other.VerifySSH(strings.NewReader(sshTestMessage), "file", []byte(sshTestSignature))
+	assert.Nil(t, err)
+	assert.False(t, v)
+
+	// Case 4: Should fail with another namespace
+	v, err = kp.VerifySSH(strings.NewReader(sshTestMessage), "git", []byte(sshTestSignature))
+	assert.False(t, v)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestParseSSHSignature(t *testing.T) {
+	// Case 1: Should work
+	sig, err := ParseSSHSignature([]byte(sshTestSignature))
+	assert.Nil(t, err)
+	assert.Equal(t, "file", sig.Namespace)
+	assert.Equal(t, "sha512", sig.HashAlgorithm)
+	assert.Equal(t, sshTestSignature, string(sig.Marshal()))
+
+	// Case 2: Should fail without armor
+	sig, err = ParseSSHSignature([]byte("U1NIU0lHAAAAAQ"))
+	assert.Nil(t, sig)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with a truncated signature
+	sig, err = ParseSSHSignature([]byte("-----BEGIN SSH SIGNATURE-----\nU1NIU0lHAAAAAQ==\n-----END SSH SIGNATURE-----"))
+	assert.Nil(t, sig)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestParseAllowedSigners(t *testing.T) {
+	file := `# Team signers
+alice@example.com,*@ops.example.com namespaces="file,git" ` + sshTestPublicKey + ` alice
+"bob@example.com" valid-after="20200101",valid-before="20300101Z" ` + sshTestPublicKey + `
+carol@example.com ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQ
+*.example.com cert-authority ` + sshTestPublicKey + `
+`
+
+	// Case 1: Should work and skip other key types
+	signers, err := ParseAllowedSigners([]byte(file))
+	assert.Nil(t, err)
+	assert.Len(t, signers, 3)
+	assert.Equal(t, []string{"alice@example.com", "*@ops.example.com"}, signers[0].Principals)
+	assert.Equal(t, []string{"file", "git"}, signers[0].Namespaces)
+	assert.Equal(t, []string{"bob@example.com"}, signers[1].Principals)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), signers[1].ValidBefore)
+	assert.True(t, signers[2].CertAuthority)
+
+	// Case 2: Should fail with an unknown option
+	signers, err = ParseAllowedSigners([]byte(`alice@example.com no-touch-required ` + sshTestPublicKey))
+	assert.Nil(t, signers)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with an invalid key
+	signers, err = ParseAllowedSigners([]byte(`alice@example.com ssh-ed25519 AAAA`))
+	assert.Nil(t, signers)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestAllowedSignersVerify(t *testing.T) {
+	// Setup
+	file := `alice@example.com,*@ops.example.com,!mallory@ops.example.com namespaces="file" ` + sshTestPublicKey + `
+expired@example.com valid-before="20200101" ` + sshTestPublicKey + `
+`
+	signers, _ := ParseAllowedSigners([]byte(file))
+
+	// Case 1: Should work with an allowed principal
+	v, err := signers.Verify(strings.NewReader(sshTestMessage), "alice@example.com", "file", []byte(sshTestSignature))
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 2: Should work with a wildcard principal
+	v, err = signers.Verify(strings.NewReader(sshTestMessage), "bob@ops.example.com", "file", []byte(sshTestSignature))
+	assert.Nil(t, err)
+	assert.True(t, v)
+
+	// Case 3: Should FAIL with another message
+	v, err = signers.Verify(strings.NewReader("tampered"), "alice@example.com", "file", []byte(sshTestSignature))
+	assert.Nil(t, err)
+	assert.False(t, v)
+
+	// Case 4: Should fail with a negated principal
+	v, err = signers.Verify(strings.NewReader(sshTestMessage), "mallory@ops.example.com", "file", []byte(sshTestSignature))
+	assert.False(t, v)
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	// Case 5: Should fail with a namespace not allowed for the principal
+	v, err = signers.Verify(strings.NewReader(sshTestMessage), "alice@example.com", "git", []byte(sshTestSignature))
+	assert.False(t, v)
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	// Case 6: Should fail with an expired signer
+	v, err = signers.Verify(strings.NewReader(sshTestMessage), "expired@example.com", "file", []byte(sshTestSignature))
+	assert.False(t, v)
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+}
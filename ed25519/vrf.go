@@ -0,0 +1,227 @@
+package ed25519
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+
+	"filippo.io/edwards25519"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ECVRF-EDWARDS25519-SHA512-TAI as defined in RFC 9381
+const (
+	// VRFProofSize is the size of a VRF proof
+	VRFProofSize = 80
+	// VRFOutputSize is the size of a VRF output
+	VRFOutputSize = sha512.Size
+
+	vrfSuite           byte = 0x03
+	vrfChallengeSize        = 16
+	vrfDomainEncode    byte = 0x01
+	vrfDomainChallenge byte = 0x02
+	vrfDomainHash      byte = 0x03
+)
+
+// ProveVRF creates a VRF proof for alpha that can be verified with the public key
+func (kp *KeyPair) ProveVRF(alpha []byte) ([]byte, error) {
+	const op = "ed25519.ProveVRF"
+
+	priv, err := kp.Private()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return priv.ProveVRF(alpha)
+}
+
+// ProveVRF creates a VRF proof for alpha that can be verified with the public key
+func (k *PrivateKey) ProveVRF(alpha []byte) ([]byte, error) {
+	const op = "ed25519.PrivateKey.ProveVRF"
+
+	if len(k.Value) != ed25519.PrivateKeySize {
+		return nil, ez.New(op, ez.EINVALID, "An ed25519 PrivateKey must be 64 bytes long", nil)
+	}
+
+	h := sha512.Sum512(k.Value[:ed25519.SeedSize])
+	defer keys.Wipe(h[:])
+	x, err := edwards25519.NewScalar().SetBytesWithClamping(h[:32])
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while deriving the secret scalar", err)
+	}
+	Y := new(edwards25519.Point).ScalarBaseMult(x)
+
+	H, err := vrfEncodeToCurve(Y.Bytes(), alpha)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	Gamma := new(edwards25519.Point).ScalarMult(x, H)
+
+	nonce := sha512.New()
+	nonce.Write(h[32:])
+	nonce.Write(H.Bytes())
+	kScalar, err := edwards25519.NewScalar().SetUniformBytes(nonce.Sum(nil))
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the nonce", err)
+	}
+
+	U := new(edwards25519.Point).ScalarBaseMult(kScalar)
+	V := new(edwards25519.Point).ScalarMult(kScalar, H)
+	c := vrfChallenge(Y, H, Gamma, U, V)
+
+	cScalar, err := vrfChallengeScalar(c)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the challenge", err)
+	}
+	s := edwards25519.NewScalar().MultiplyAdd(cScalar, x, kScalar)
+
+	proof := make([]byte, 0, VRFProofSize)
+	proof = append(proof, Gamma.Bytes()...)
+	proof = append(proof, c...)
+	proof = append(proof, s.Bytes()...)
+
+	return proof, nil
+}
+
+// VerifyVRF validates a VRF proof for alpha
+func (kp *KeyPair) VerifyVRF(alpha, proof []byte) (bool, error) {
+	const op = "ed25519.VerifyVRF"
+
+	pub, err := kp.Public()
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	return pub.VerifyVRF(alpha, proof)
+}
+
+// VerifyVRF validates a VRF proof for alpha created by the matching private
+// key. Public keys of small order are rejected
+func (k *PublicKey) VerifyVRF(alpha, proof []byte) (bool, error) {
+	const op = "ed25519.PublicKey.VerifyVRF"
+
+	Y, err := decodePoint(k.Value)
+	if err != nil {
+		return false, ez.New(op, ez.EINVALID, "The PublicKey is not a valid ed25519 point", err)
+	}
+	if new(edwards25519.Point).MultByCofactor(Y).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return false, ez.New(op, ez.EINVALID, "The PublicKey is a point of small order", nil)
+	}
+
+	Gamma, c, s, err := decodeVRFProof(proof)
+	if err != nil {
+		return false, nil
+	}
+
+	H, err := vrfEncodeToCurve(k.Value, alpha)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	cScalar, err := vrfChallengeScalar(c)
+	if err != nil {
+		return false, nil
+	}
+	negC := edwards25519.NewScalar().Negate(cScalar)
+
+	U := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(negC, Y, s)
+	V := new(edwards25519.Point).VarTimeMultiScalarMult(
+		[]*edwards25519.Scalar{s, negC},
+		[]*edwards25519.Point{H, Gamma},
+	)
+
+	expected := vrfChallenge(Y, H, Gamma, U, V)
+	return subtle.ConstantTimeCompare(c, expected) == 1, nil
+}
+
+// VRFProofToHash returns the VRF output of a proof. The proof must be
+// verified with VerifyVRF before its output can be trusted
+func VRFProofToHash(proof []byte) ([]byte, error) {
+	const op = "ed25519.VRFProofToHash"
+
+	Gamma, _, _, err := decodeVRFProof(proof)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, vrfDomainHash})
+	h.Write(new(edwards25519.Point).MultByCofactor(Gamma).Bytes())
+	h.Write([]byte{0x00})
+
+	return h.Sum(nil), nil
+}
+
+// vrfEncodeToCurve hashes alpha to a point with the try-and-increment method
+func vrfEncodeToCurve(salt, alpha []byte) (*edwards25519.Point, error) {
+	const op = "ed25519.vrfEncodeToCurve"
+
+	for ctr := 0; ctr < 256; ctr++ {
+		h := sha512.New()
+		h.Write([]byte{vrfSuite, vrfDomainEncode})
+		h.Write(salt)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+
+		p, err := decodePoint(h.Sum(nil)[:32])
+		if err == nil {
+			return p.MultByCofactor(p), nil
+		}
+	}
+
+	return nil, ez.New(op, ez.EINTERNAL, "Could not encode the input to a curve point", nil)
+}
+
+func vrfChallenge(points ...*edwards25519.Point) []byte {
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, vrfDomainChallenge})
+	for _, p := range points {
+		h.Write(p.Bytes())
+	}
+	h.Write([]byte{0x00})
+
+	return h.Sum(nil)[:vrfChallengeSize]
+}
+
+func vrfChallengeScalar(c []byte) (*edwards25519.Scalar, error) {
+	b := make([]byte, 32)
+	copy(b, c)
+	return edwards25519.NewScalar().SetCanonicalBytes(b)
+}
+
+func decodeVRFProof(proof []byte) (*edwards25519.Point, []byte, *edwards25519.Scalar, error) {
+	const op = "ed25519.decodeVRFProof"
+
+	if len(proof) != VRFProofSize {
+		return nil, nil, nil, ez.New(op, ez.EINVALID, "A VRF proof must be 80 bytes long", nil)
+	}
+
+	Gamma, err := decodePoint(proof[:32])
+	if err != nil {
+		return nil, nil, nil, ez.New(op, ez.EINVALID, "The VRF proof has an invalid point", err)
+	}
+
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(proof[32+vrfChallengeSize:])
+	if err != nil {
+		return nil, nil, nil, ez.New(op, ez.EINVALID, "The VRF proof has an invalid scalar", err)
+	}
+
+	return Gamma, proof[32 : 32+vrfChallengeSize], s, nil
+}
+
+// decodePoint decodes a point following RFC 8032, which unlike
+// edwards25519.Point.SetBytes rejects non-canonical encodings
+func decodePoint(b []byte) (*edwards25519.Point, error) {
+	const op = "ed25519.decodePoint"
+
+	p, err := new(edwards25519.Point).SetBytes(b)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Invalid point encoding", err)
+	}
+	if subtle.ConstantTimeCompare(p.Bytes(), b) != 1 {
+		return nil, ez.New(op, ez.EINVALID, "Non-canonical point encoding", nil)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,127 @@
+package ed25519
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+// RFC 9381 Appendix B.3
+var vrfTestVectors = []struct {
+	seed, public, alpha, proof, beta string
+}{
+	{
+		seed:   "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		public: "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		alpha:  "",
+		proof:  "8657106690b5526245a92b003bb079ccd1a92130477671f6fc01ad16f26f723f26f8a57ccaed74ee1b190bed1f479d9727d2d0f9b005a6e456a35d4fb0daab1268a1b0db10836d9826a528ca76567805",
+		beta:   "90cf1df3b703cce59e2a35b925d411164068269d7b2d29f3301c03dd757876ff66b71dda49d2de59d03450451af026798e8f81cd2e333de5cdf4f3e140fdd8ae",
+	},
+	{
+		seed:   "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+		public: "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+		alpha:  "72",
+		proof:  "f3141cd382dc42909d19ec5110469e4feae18300e94f304590abdced48aed5933bf0864a62558b3ed7f2fea45c92a465301b3bbf5e3e54ddf2d935be3b67926da3ef39226bbc355bdc9850112c8f4b02",
+		beta:   "eb4440665d3891d668e7e0fcaf587f1b4bd7fbfe99d0eb2211ccec90496310eb5e33821bc613efb94db5e5b54c70a848a0bef4553a41befc57663b56373a5031",
+	},
+	{
+		seed:   "c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7",
+		public: "fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025",
+		alpha:  "af82",
+		proof:  "9bc0f79119cc5604bf02d23b4caede71393cedfbb191434dd016d30177ccbf8096bb474e53895c362d8628ee9f9ea3c0e52c7a5c691b6c18c9979866568add7a2d41b00b05081ed0f58ee5e31b3a970e",
+		beta:   "645427e5d00c62a23fb703732fa5d892940935942101e456ecca7bb217c61c452118fec1219202a0edcf038bb6373241578be7217ba85a2687f7a0310b2df19f",
+	},
+}
+
+func TestProveVRF(t *testing.T) {
+	for _, v := range vrfTestVectors {
+		seed, _ := hex.DecodeString(v.seed)
+		alpha, _ := hex.DecodeString(v.alpha)
+
+		priv, err := NewPrivateKeyFromSeed(seed)
+		assert.Nil(t, err)
+		keyPair, err := LoadKeyPair(nil, priv.Value)
+		assert.Nil(t, err)
+		assert.Equal(t, v.public, hex.EncodeToString(keyPair.PublicKey))
+
+		// Case 1: Should match the RFC 9381 proof
+		proof, err := keyPair.ProveVRF(alpha)
+		assert.Nil(t, err)
+		assert.Equal(t, v.proof, hex.EncodeToString(proof))
+
+		// Case 2: Should match the RFC 9381 output
+		beta, err := VRFProofToHash(proof)
+		assert.Nil(t, err)
+		assert.Equal(t, v.beta, hex.EncodeToString(beta))
+	}
+
+	// Case 3: Should fail without a private key
+	keyPair, _ := NewKeyPair()
+	public, _ := LoadKeyPair(keyPair.PublicKey, nil)
+	proof, err := public.ProveVRF([]byte("round-1"))
+	assert.Nil(t, proof)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestVerifyVRF(t *testing.T) {
+	// Setup
+	v := vrfTestVectors[2]
+	public, _ := hex.DecodeString(v.public)
+	alpha, _ := hex.DecodeString(v.alpha)
+	proof, _ := hex.DecodeString(v.proof)
+	keyPair, _ := LoadKeyPair(public, nil)
+
+	// Case 1: Should work with the RFC 9381 proof
+	valid, err := keyPair.VerifyVRF(alpha, proof)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+
+	// Case 2: Should FAIL with another input
+	valid, err = keyPair.VerifyVRF([]byte("af83"), proof)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	// Case 3: Should FAIL with a tampered proof
+	tampered := append([]byte{}, proof...)
+	tampered[40] ^= 1
+	valid, err = keyPair.VerifyVRF(alpha, tampered)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	// Case 4: Should FAIL with a truncated proof
+	valid, err = keyPair.VerifyVRF(alpha, proof[:VRFProofSize-1])
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	// Case 5: Should FAIL with another public key
+	other, _ := NewKeyPair()
+	valid, err = other.VerifyVRF(alpha, proof)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	// Case 6: Should return an error with a public key of small order
+	identity := make([]byte, 32)
+	identity[0] = 1
+	smallOrder, _ := LoadKeyPair(identity, nil)
+	valid, err = smallOrder.VerifyVRF(alpha, proof)
+	assert.False(t, valid)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestVRFRoundTrip(t *testing.T) {
+	keyPair, _ := NewKeyPair()
+
+	proof, err := keyPair.ProveVRF([]byte("round-42"))
+	assert.Nil(t, err)
+	assert.Len(t, proof, VRFProofSize)
+
+	valid, err := keyPair.VerifyVRF([]byte("round-42"), proof)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+
+	beta, err := VRFProofToHash(proof)
+	assert.Nil(t, err)
+	assert.Len(t, beta, VRFOutputSize)
+}
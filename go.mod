@@ -1,10 +1,21 @@
 module github.com/vanclief/go-crypto
 
-go 1.15
+go 1.26.0
 
 require (
+	filippo.io/edwards25519 v1.0.0
 	github.com/kevinburke/nacl v0.0.0-20201008022143-9492d993f15e
 	github.com/stretchr/testify v1.6.1
 	github.com/vanclief/ez v1.1.3
 	golang.org/x/crypto v0.0.0-20201002170205-7f63de1d35b0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/golang/protobuf v1.3.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
+	google.golang.org/grpc v1.29.1 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
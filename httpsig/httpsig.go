@@ -0,0 +1,444 @@
+package httpsig
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vanclief/ez"
+)
+
+const (
+	// DefaultLabel is the label used for signatures when none is set
+	DefaultLabel = "sig1"
+
+	algorithm            = "ed25519"
+	headerSignature      = "Signature"
+	headerSignatureInput = "Signature-Input"
+	headerContentDigest  = "Content-Digest"
+	componentParams      = "@signature-params"
+)
+
+// DefaultComponents are the components covered by a signature when none are set
+var DefaultComponents = []string{"@method", "@authority", "@path", "@query", "content-digest"}
+
+var derivedComponents = map[string]bool{
+	"@method":         true,
+	"@target-uri":     true,
+	"@authority":      true,
+	"@scheme":         true,
+	"@request-target": true,
+	"@path":           true,
+	"@query":          true,
+}
+
+// ContentDigest returns the Content-Digest (RFC 9530) header value of a body
+// using SHA-256
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// validateComponents checks that the components are supported, lowercase and
+// not repeated
+func validateComponents(components []string) error {
+	const op = "httpsig.validateComponents"
+
+	if len(components) == 0 {
+		return ez.New(op, ez.EINVALID, "At least one component must be covered", nil)
+	}
+
+	seen := map[string]bool{}
+	for _, c := range components {
+		if c == "" || c != strings.ToLower(c) || strings.ContainsAny(c, "\" \t;") {
+			return ez.New(op, ez.EINVALID, "Component "+strconv.Quote(c)+" is not a valid identifier", nil)
+		} else if strings.HasPrefix(c, "@") && !derivedComponents[c] {
+			return ez.New(op, ez.EINVALID, "Component "+c+" is not supported", nil)
+		} else if seen[c] {
+			return ez.New(op, ez.EINVALID, "Component "+c+" is repeated", nil)
+		}
+		seen[c] = true
+	}
+
+	return nil
+}
+
+// signatureBase builds the signature base (RFC 9421 section 2.5) of a request
+func signatureBase(r *http.Request, components []string, params string) ([]byte, error) {
+	const op = "httpsig.signatureBase"
+
+	var b strings.Builder
+	for _, c := range components {
+		v, err := componentValue(r, c)
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+
+		b.WriteString(strconv.Quote(c))
+		b.WriteString(": ")
+		b.WriteString(v)
+		b.WriteByte('\n')
+	}
+
+	b.WriteString(strconv.Quote(componentParams))
+	b.WriteString(": ")
+	b.WriteString(params)
+
+	return []byte(b.String()), nil
+}
+
+// serializeParams serializes the covered components and the signature
+// parameters as the value of @signature-params
+func serializeParams(components []string, created, expires int64, keyID string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, c := range components {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.Quote(c))
+	}
+	b.WriteByte(')')
+
+	b.WriteString(";created=" + strconv.FormatInt(created, 10))
+	if expires > 0 {
+		b.WriteString(";expires=" + strconv.FormatInt(expires, 10))
+	}
+	b.WriteString(";keyid=" + quoteString(keyID))
+
+	return b.String()
+}
+
+func componentValue(r *http.Request, name string) (string, error) {
+	const op = "httpsig.componentValue"
+
+	switch name {
+	case "@method":
+		if r.Method == "" {
+			return http.MethodGet, nil
+		}
+		return strings.ToUpper(r.Method), nil
+	case "@target-uri":
+		return scheme(r) + "://" + authority(r) + r.URL.RequestURI(), nil
+	case "@authority":
+		return authority(r), nil
+	case "@scheme":
+		return scheme(r), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		if p := r.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+
+	if strings.HasPrefix(name, "@") {
+		return "", ez.New(op, ez.EINVALID, "Component "+name+" is not supported", nil)
+	}
+
+	values := r.Header.Values(name)
+	if len(values) == 0 && name == "content-length" && r.ContentLength > 0 {
+		values = []string{strconv.FormatInt(r.ContentLength, 10)}
+	}
+	if len(values) == 0 {
+		return "", ez.New(op, ez.EINVALID, "Request does not have the "+name+" header", nil)
+	}
+
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+
+	return strings.Join(values, ", "), nil
+}
+
+func scheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	} else if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// authority returns the lowercase host of the request without the default port
+func authority(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	host = strings.ToLower(host)
+
+	switch s := scheme(r); {
+	case s == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	case s == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	}
+
+	return host
+}
+
+// verifyContentDigest checks a Content-Digest header against the body
+func verifyContentDigest(header string, body []byte) bool {
+	members, err := parseDictionary(header)
+	if err != nil {
+		return false
+	}
+
+	for _, m := range members {
+		var sum []byte
+		switch m.key {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+
+		return m.bytes != nil && string(m.bytes) == string(sum)
+	}
+
+	return false
+}
+
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// member is an entry of a structured field dictionary (RFC 8941) whose value
+// is either an inner list of strings or a byte sequence
+type member struct {
+	key    string
+	items  []string
+	bytes  []byte
+	params map[string]string
+	raw    string
+}
+
+// parseDictionary parses the subset of RFC 8941 dictionaries used by the
+// Signature, Signature-Input and Content-Digest headers
+func parseDictionary(s string) ([]*member, error) {
+	const op = "httpsig.parseDictionary"
+
+	p := &sfParser{s: s}
+	var members []*member
+
+	p.skip(" ")
+	for !p.done() {
+		m := &member{}
+
+		key, err := p.key()
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		m.key = key
+
+		if !p.consume('=') {
+			return nil, ez.New(op, ez.EINVALID, "Dictionary member "+key+" does not have a value", nil)
+		}
+
+		start := p.i
+		switch {
+		case p.consume('('):
+			m.items, err = p.innerList()
+		case p.consume(':'):
+			m.bytes, err = p.byteSequence()
+		default:
+			err = ez.New(op, ez.EINVALID, "Dictionary member "+key+" has an unsupported value", nil)
+		}
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+
+		m.params, err = p.params()
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		m.raw = p.s[start:p.i]
+		members = append(members, m)
+
+		p.skip(" \t")
+		if p.done() {
+			break
+		} else if !p.consume(',') {
+			return nil, ez.New(op, ez.EINVALID, "Dictionary members must be separated by commas", nil)
+		}
+		p.skip(" \t")
+		if p.done() {
+			return nil, ez.New(op, ez.EINVALID, "Dictionary has a trailing comma", nil)
+		}
+	}
+
+	return members, nil
+}
+
+type sfParser struct {
+	s string
+	i int
+}
+
+func (p *sfParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if p.peek() == c && !p.done() {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *sfParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *sfParser) key() (string, error) {
+	const op = "httpsig.sfParser.key"
+
+	start := p.i
+	if c := p.peek(); !(c >= 'a' && c <= 'z') && c != '*' {
+		return "", ez.New(op, ez.EINVALID, "Invalid key", nil)
+	}
+	for !p.done() {
+		c := p.s[p.i]
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && strings.IndexByte("_-.*", c) < 0 {
+			break
+		}
+		p.i++
+	}
+
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) innerList() ([]string, error) {
+	const op = "httpsig.sfParser.innerList"
+
+	items := []string{}
+	for {
+		p.skip(" ")
+		if p.consume(')') {
+			return items, nil
+		}
+
+		if !p.consume('"') {
+			return nil, ez.New(op, ez.EINVALID, "Inner list items must be strings", nil)
+		}
+		item, err := p.str()
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		if p.peek() == ';' {
+			return nil, ez.New(op, ez.EINVALID, "Component parameters are not supported", nil)
+		}
+		items = append(items, item)
+
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, ez.New(op, ez.EINVALID, "Inner list is not terminated", nil)
+		}
+	}
+}
+
+// str parses a string after its opening quote
+func (p *sfParser) str() (string, error) {
+	const op = "httpsig.sfParser.str"
+
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.i]
+		p.i++
+
+		switch {
+		case c == '"':
+			return b.String(), nil
+		case c == '\\':
+			if next := p.peek(); next != '"' && next != '\\' {
+				return "", ez.New(op, ez.EINVALID, "Invalid escape in string", nil)
+			}
+			b.WriteByte(p.s[p.i])
+			p.i++
+		case c < 0x20 || c > 0x7e:
+			return "", ez.New(op, ez.EINVALID, "Invalid character in string", nil)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", ez.New(op, ez.EINVALID, "String is not terminated", nil)
+}
+
+// byteSequence parses a byte sequence after its opening colon
+func (p *sfParser) byteSequence() ([]byte, error) {
+	const op = "httpsig.sfParser.byteSequence"
+
+	end := strings.IndexByte(p.s[p.i:], ':')
+	if end < 0 {
+		return nil, ez.New(op, ez.EINVALID, "Byte sequence is not terminated", nil)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(p.s[p.i : p.i+end])
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Byte sequence is not valid base64", err)
+	}
+	p.i += end + 1
+
+	return b, nil
+}
+
+func (p *sfParser) params() (map[string]string, error) {
+	const op = "httpsig.sfParser.params"
+
+	params := map[string]string{}
+	for p.consume(';') {
+		p.skip(" ")
+		key, err := p.key()
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+
+		if !p.consume('=') {
+			params[key] = "?1"
+			continue
+		}
+
+		switch c := p.peek(); {
+		case c == '"':
+			p.i++
+			params[key], err = p.str()
+			if err != nil {
+				return nil, ez.Wrap(op, err)
+			}
+		case c == '-' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '?':
+			start := p.i
+			p.i++
+			for !p.done() && strings.IndexByte(" ;,()\"", p.s[p.i]) < 0 {
+				p.i++
+			}
+			params[key] = p.s[start:p.i]
+		default:
+			return nil, ez.New(op, ez.EINVALID, "Parameter "+key+" has an unsupported value", nil)
+		}
+	}
+
+	return params, nil
+}
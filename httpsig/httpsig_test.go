@@ -0,0 +1,259 @@
+package httpsig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/ed25519"
+)
+
+// Key test-key-ed25519 from RFC 9421 Appendix B.1.4, the seed is the end of
+// the PKCS#8 document
+const (
+	testKeyPKCS8  = "MC4CAQAwBQYDK2VwBCIEIJ+DYvh6SEqVTm50DFtMDoQikTmiCqirVv9mWG9qfSnF"
+	testKeyPublic = "JrQLj5P/89iXES9+vFgrIy29clF9CC/oPPsw3c5D0bs="
+)
+
+func testKeyPair(t *testing.T) *ed25519.KeyPair {
+	der, _ := base64.StdEncoding.DecodeString(testKeyPKCS8)
+	priv, err := ed25519.NewPrivateKeyFromSeed(der[len(der)-32:])
+	assert.Nil(t, err)
+
+	keyPair, err := ed25519.LoadKeyPair(nil, priv.Value)
+	assert.Nil(t, err)
+	assert.Equal(t, testKeyPublic, base64.StdEncoding.EncodeToString(keyPair.PublicKey))
+
+	return keyPair
+}
+
+func testRequest() *http.Request {
+	r, _ := http.NewRequest(http.MethodPost, "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(`{"hello": "world"}`))
+	r.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+	r.Header.Set("Content-Length", "18")
+	return r
+}
+
+func TestSignRequest(t *testing.T) {
+	// Setup
+	keyPair := testKeyPair(t)
+	signer, err := NewSigner(keyPair, "test-key-ed25519", "date", "@method", "@path", "@authority", "content-type", "content-length")
+	assert.Nil(t, err)
+	signer.Label = "sig-b26"
+
+	// Case 1: Should match RFC 9421 Appendix B.2.6
+	r := testRequest()
+	err = signer.signAt(r, time.Unix(1618884473, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, `sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`, r.Header.Get("Signature-Input"))
+	assert.Equal(t, "sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:", r.Header.Get("Signature"))
+
+	// Case 2: Should verify the RFC 9421 signature
+	pub, _ := keyPair.Public()
+	verifier, err := NewVerifier(KeySetResolver(ed25519.KeySet{"test-key-ed25519": pub}), "@method", "@path")
+	assert.Nil(t, err)
+
+	keyID, err := verifier.verifyAt(r, time.Unix(1618884473, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, "test-key-ed25519", keyID)
+
+	// Case 3: Should fail when a covered header is missing
+	r = testRequest()
+	r.Header.Del("Date")
+	err = signer.SignRequest(r)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should set the Content-Digest and keep the body
+	signer, _ = NewSigner(keyPair, "test-key-ed25519")
+	r = testRequest()
+	err = signer.SignRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", r.Header.Get("Content-Digest"))
+
+	body, _ := io.ReadAll(r.Body)
+	assert.Equal(t, `{"hello": "world"}`, string(body))
+}
+
+func TestNewSigner(t *testing.T) {
+	keyPair, _ := ed25519.NewKeyPair()
+
+	// Case 1: Should work with the default components
+	signer, err := NewSigner(keyPair, "client-1")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultComponents, signer.Components)
+
+	// Case 2: Should fail with an unsupported derived component
+	signer, err = NewSigner(keyPair, "client-1", "@status")
+	assert.Nil(t, signer)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with a repeated component
+	signer, err = NewSigner(keyPair, "client-1", "@method", "@method")
+	assert.Nil(t, signer)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail without a private key
+	public, _ := ed25519.LoadKeyPair(keyPair.PublicKey, nil)
+	signer, err = NewSigner(public, "client-1")
+	assert.Nil(t, signer)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestMiddleware(t *testing.T) {
+	// Setup
+	keyPair, _ := ed25519.NewKeyPair()
+	other, _ := ed25519.NewKeyPair()
+	pub, _ := keyPair.Public()
+
+	verifier, _ := NewVerifier(KeySetResolver(ed25519.KeySet{"client-1": pub}))
+	server := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(KeyIDFromContext(r.Context()) + ":" + string(body)))
+	})))
+	defer server.Close()
+
+	signer, _ := NewSigner(keyPair, "client-1")
+	client := &http.Client{Transport: signer.Transport(nil)}
+
+	// Case 1: Should work
+	res, err := client.Post(server.URL+"/orders?page=2", "application/json", strings.NewReader(`{"id":1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, `client-1:{"id":1}`, string(body))
+
+	// Case 2: Should fail without a signature
+	res, err = http.Post(server.URL+"/orders", "application/json", strings.NewReader(`{"id":1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res.Body.Close()
+
+	// Case 3: Should fail with an unknown key
+	otherSigner, _ := NewSigner(other, "client-2")
+	res, err = (&http.Client{Transport: otherSigner.Transport(nil)}).Get(server.URL + "/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res.Body.Close()
+
+	// Case 4: Should fail when the key ID points to another key
+	otherSigner, _ = NewSigner(other, "client-1")
+	res, err = (&http.Client{Transport: otherSigner.Transport(nil)}).Get(server.URL + "/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res.Body.Close()
+
+	// Case 5: Should fail when the signature does not cover the required components
+	partial, _ := NewSigner(keyPair, "client-1", "@method", "@path")
+	res, err = (&http.Client{Transport: partial.Transport(nil)}).Get(server.URL + "/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res.Body.Close()
+}
+
+func TestVerify(t *testing.T) {
+	// Setup
+	keyPair, _ := ed25519.NewKeyPair()
+	pub, _ := keyPair.Public()
+	verifier, _ := NewVerifier(KeySetResolver(ed25519.KeySet{"client-1": pub}))
+	signer, _ := NewSigner(keyPair, "client-1")
+	signer.Expiry = time.Minute
+	now := time.Now()
+
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "http://api.example.com/orders/1", strings.NewReader(body))
+		err := signer.signAt(r, now)
+		assert.Nil(t, err)
+		return r
+	}
+
+	// Case 1: Should work
+	keyID, err := verifier.verifyAt(newRequest("data"), now)
+	assert.Nil(t, err)
+	assert.Equal(t, "client-1", keyID)
+
+	// Case 2: Should fail with a tampered body
+	r := newRequest("data")
+	r.Body = io.NopCloser(bytes.NewReader([]byte("date")))
+	_, err = verifier.verifyAt(r, now)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 3: Should fail with a tampered path
+	r = newRequest("data")
+	r.URL.Path = "/orders/2"
+	_, err = verifier.verifyAt(r, now)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 4: Should fail after expiring
+	_, err = verifier.verifyAt(newRequest("data"), now.Add(2*time.Minute))
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 5: Should fail when it was created in the future
+	_, err = verifier.verifyAt(newRequest("data"), now.Add(-2*time.Minute))
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 6: Should fail when it is older than MaxAge
+	signer.Expiry = 0
+	_, err = verifier.verifyAt(newRequest("data"), now.Add(10*time.Minute))
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 7: Should fail with a malformed Signature-Input
+	r = newRequest("data")
+	r.Header.Set("Signature-Input", `sig1=("@method"`)
+	_, err = verifier.verifyAt(r, now)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+
+	// Case 8: Should not read the body when the signature is not valid
+	signer.Expiry = time.Minute
+	r = newRequest("data")
+	r.URL.Path = "/orders/2"
+	body := &countingReader{Reader: strings.NewReader("data")}
+	r.Body = io.NopCloser(body)
+	_, err = verifier.verifyAt(r, now)
+	assert.Equal(t, ez.ENOTAUTHENTICATED, ez.ErrorCode(err))
+	assert.Equal(t, 0, body.reads)
+
+	// Case 9: Should fail when the body is larger than MaxBodySize
+	verifier.MaxBodySize = 4
+	_, err = verifier.verifyAt(newRequest("data"), now)
+	assert.Nil(t, err)
+	_, err = verifier.verifyAt(newRequest("data!"), now)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Reader.Read(p)
+}
+
+func TestParseDictionary(t *testing.T) {
+	// Case 1: Should parse inner lists with parameters and byte sequences
+	members, err := parseDictionary(`sig1=("@method" "x-a\"b");created=1;keyid="k", sig2=:AQID:;p`)
+	assert.Nil(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, []string{"@method", `x-a"b`}, members[0].items)
+	assert.Equal(t, map[string]string{"created": "1", "keyid": "k"}, members[0].params)
+	assert.Equal(t, `("@method" "x-a\"b");created=1;keyid="k"`, members[0].raw)
+	assert.Equal(t, []byte{1, 2, 3}, members[1].bytes)
+	assert.Equal(t, "?1", members[1].params["p"])
+
+	// Case 2: Should fail with malformed dictionaries
+	for _, s := range []string{`sig1`, `sig1=("a"`, `sig1=:AQID`, `Sig1=("a")`, `sig1=("a"),`, `sig1=("a";x)`} {
+		_, err = parseDictionary(s)
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err), s)
+	}
+}
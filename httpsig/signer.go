@@ -0,0 +1,186 @@
+package httpsig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/ed25519"
+)
+
+// Signer signs HTTP requests with an ed25519 private key. Expiry sets the
+// expires parameter relative to the creation time when greater than zero
+type Signer struct {
+	KeyID      string
+	Label      string
+	Components []string
+	Expiry     time.Duration
+	privateKey *ed25519.PrivateKey
+}
+
+// Transport is an http.RoundTripper that signs every request before sending
+// it with the Base RoundTripper, or http.DefaultTransport if Base is nil
+type Transport struct {
+	Signer *Signer
+	Base   http.RoundTripper
+}
+
+// NewSigner returns a Signer for the KeyPair identified by keyID. If no
+// components are given DefaultComponents are covered
+func NewSigner(keyPair *ed25519.KeyPair, keyID string, components ...string) (*Signer, error) {
+	const op = "httpsig.NewSigner"
+
+	if keyPair == nil {
+		return nil, ez.New(op, ez.EINVALID, "KeyPair can not be nil", nil)
+	} else if keyID == "" {
+		return nil, ez.New(op, ez.EINVALID, "KeyID can not be empty", nil)
+	}
+
+	priv, err := keyPair.Private()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	if len(components) == 0 {
+		components = DefaultComponents
+	}
+
+	err = validateComponents(components)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return &Signer{
+		KeyID:      keyID,
+		Label:      DefaultLabel,
+		Components: append([]string{}, components...),
+		privateKey: priv,
+	}, nil
+}
+
+// SignRequest adds the Signature-Input and Signature headers to the request.
+// If content-digest is covered the body is read and a Content-Digest header
+// is set
+func (s *Signer) SignRequest(r *http.Request) error {
+	return s.signAt(r, time.Now())
+}
+
+// Transport returns an http.RoundTripper that signs requests with the Signer
+func (s *Signer) Transport(base http.RoundTripper) http.RoundTripper {
+	return &Transport{Signer: s, Base: base}
+}
+
+// RoundTrip signs a copy of the request and sends it
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	const op = "httpsig.Transport.RoundTrip"
+
+	signed := r.Clone(r.Context())
+	err := t.Signer.SignRequest(signed)
+	if err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, ez.Wrap(op, err)
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(signed)
+}
+
+func (s *Signer) signAt(r *http.Request, at time.Time) error {
+	const op = "httpsig.Signer.SignRequest"
+
+	if s.privateKey == nil {
+		return ez.New(op, ez.EINVALID, "Signer must be created with NewSigner", nil)
+	}
+
+	label := s.Label
+	if label == "" {
+		label = DefaultLabel
+	}
+
+	for _, c := range s.Components {
+		if c == "content-digest" {
+			err := setContentDigest(r)
+			if err != nil {
+				return ez.Wrap(op, err)
+			}
+		}
+	}
+
+	var expires int64
+	if s.Expiry > 0 {
+		expires = at.Add(s.Expiry).Unix()
+	}
+	params := serializeParams(s.Components, at.Unix(), expires, s.KeyID)
+
+	base, err := signatureBase(r, s.Components, params)
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	sig, err := s.privateKey.Sign(base)
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	r.Header.Set(headerSignatureInput, label+"="+params)
+	r.Header.Set(headerSignature, label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+
+	return nil
+}
+
+// setContentDigest reads the body of the request, replaces it with an
+// in-memory copy and sets its Content-Digest header
+func setContentDigest(r *http.Request) error {
+	const op = "httpsig.setContentDigest"
+
+	body, err := readBody(r, 0)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while reading the request body", err)
+	}
+
+	r.Header.Set(headerContentDigest, ContentDigest(body))
+	if r.Body != nil && r.Body != http.NoBody {
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	return nil
+}
+
+// errBodyTooLarge is returned by readBody when the body exceeds its limit
+var errBodyTooLarge = errors.New("body is too large")
+
+// readBody reads and closes the body of the request and replaces it with an
+// in-memory copy. It reads at most limit bytes when limit is greater than zero
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return []byte{}, nil
+	}
+
+	var reader io.Reader = r.Body
+	if limit > 0 {
+		reader = io.LimitReader(r.Body, limit+1)
+	}
+
+	body, err := io.ReadAll(reader)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	} else if limit > 0 && int64(len(body)) > limit {
+		return nil, errBodyTooLarge
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,243 @@
+package httpsig
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/ed25519"
+)
+
+type contextKey struct{}
+
+// KeyResolver returns the public key for the key ID of a signature
+type KeyResolver func(keyID string) (*ed25519.PublicKey, error)
+
+// DefaultMaxBodySize is the largest body a Verifier reads by default to check
+// the Content-Digest header
+const DefaultMaxBodySize = 10 << 20
+
+// Verifier verifies the signatures of HTTP requests. Components lists the
+// components that a signature must cover, MaxAge rejects signatures created
+// too long ago when greater than zero and Skew is the tolerated clock
+// difference with the signer. MaxBodySize rejects larger bodies when
+// content-digest is covered and it is greater than zero
+type Verifier struct {
+	Resolver    KeyResolver
+	Label       string
+	Components  []string
+	MaxAge      time.Duration
+	Skew        time.Duration
+	MaxBodySize int64
+}
+
+// NewVerifier returns a Verifier that resolves keys with the resolver and
+// requires the components to be covered. If no components are given
+// DefaultComponents are required
+func NewVerifier(resolver KeyResolver, components ...string) (*Verifier, error) {
+	const op = "httpsig.NewVerifier"
+
+	if resolver == nil {
+		return nil, ez.New(op, ez.EINVALID, "KeyResolver can not be nil", nil)
+	}
+
+	if len(components) == 0 {
+		components = DefaultComponents
+	}
+
+	err := validateComponents(components)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return &Verifier{
+		Resolver:    resolver,
+		Components:  append([]string{}, components...),
+		MaxAge:      5 * time.Minute,
+		Skew:        time.Minute,
+		MaxBodySize: DefaultMaxBodySize,
+	}, nil
+}
+
+// KeySetResolver returns a KeyResolver that looks up keys in a KeySet
+func KeySetResolver(ks ed25519.KeySet) KeyResolver {
+	return func(keyID string) (*ed25519.PublicKey, error) {
+		const op = "httpsig.KeySetResolver"
+
+		pub, ok := ks[keyID]
+		if !ok || pub == nil {
+			return nil, ez.New(op, ez.ENOTFOUND, "Unknown key "+strconv.Quote(keyID), nil)
+		}
+
+		return pub, nil
+	}
+}
+
+// KeyIDFromContext returns the key ID of the signature verified by the
+// Middleware, or an empty string if the request was not verified
+func KeyIDFromContext(ctx context.Context) string {
+	keyID, _ := ctx.Value(contextKey{}).(string)
+	return keyID
+}
+
+// Middleware returns an http.Handler that only calls next for requests with a
+// valid signature. Other requests are rejected with 401 Unauthorized
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyID, err := v.Verify(r)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, keyID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Verify validates the signature of a request and returns its key ID. If
+// content-digest is covered the body is read and checked against the
+// Content-Digest header once the signature is valid
+func (v *Verifier) Verify(r *http.Request) (string, error) {
+	return v.verifyAt(r, time.Now())
+}
+
+func (v *Verifier) verifyAt(r *http.Request, at time.Time) (string, error) {
+	const op = "httpsig.Verifier.Verify"
+
+	input, sig, err := v.findSignature(r)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	for _, required := range v.Components {
+		if !contains(input.items, required) {
+			return "", ez.New(op, ez.ENOTAUTHENTICATED, "Signature does not cover "+required, nil)
+		}
+	}
+
+	keyID := input.params["keyid"]
+	if keyID == "" {
+		return "", ez.New(op, ez.ENOTAUTHENTICATED, "Signature does not have a keyid", nil)
+	} else if alg, ok := input.params["alg"]; ok && alg != algorithm {
+		return "", ez.New(op, ez.ENOTAUTHENTICATED, "Signature algorithm "+strconv.Quote(alg)+" is not supported", nil)
+	}
+
+	err = v.checkTimes(input.params, at)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	pub, err := v.Resolver(keyID)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	} else if pub == nil {
+		return "", ez.New(op, ez.ENOTFOUND, "Unknown key "+strconv.Quote(keyID), nil)
+	}
+
+	base, err := signatureBase(r, input.items, input.raw)
+	if err != nil {
+		return "", ez.New(op, ez.ENOTAUTHENTICATED, "Signature covers a missing component", err)
+	}
+
+	valid, err := pub.VerifySignature(sig, base)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	} else if !valid {
+		return "", ez.New(op, ez.ENOTAUTHENTICATED, "Signature is not valid", nil)
+	}
+
+	// The signature only covers the Content-Digest header, the body is read
+	// after it is verified so unsigned requests can not make it buffer
+	if contains(input.items, "content-digest") {
+		body, err := readBody(r, v.MaxBodySize)
+		if err == errBodyTooLarge {
+			return "", ez.New(op, ez.EINVALID, "Request body is larger than MaxBodySize", nil)
+		} else if err != nil {
+			return "", ez.New(op, ez.EINTERNAL, "Error while reading the request body", err)
+		}
+
+		if !verifyContentDigest(r.Header.Get(headerContentDigest), body) {
+			return "", ez.New(op, ez.ENOTAUTHENTICATED, "Content-Digest does not match the body", nil)
+		}
+	}
+
+	return keyID, nil
+}
+
+// findSignature returns the Signature-Input member and the signature with the
+// Verifier label, or the first signature if the label is not set
+func (v *Verifier) findSignature(r *http.Request) (*member, []byte, error) {
+	const op = "httpsig.Verifier.findSignature"
+
+	inputs, err := parseDictionary(strings.Join(r.Header.Values(headerSignatureInput), ", "))
+	if err != nil {
+		return nil, nil, ez.New(op, ez.ENOTAUTHENTICATED, "Signature-Input header is malformed", err)
+	}
+
+	sigs, err := parseDictionary(strings.Join(r.Header.Values(headerSignature), ", "))
+	if err != nil {
+		return nil, nil, ez.New(op, ez.ENOTAUTHENTICATED, "Signature header is malformed", err)
+	}
+
+	var input *member
+	for _, m := range inputs {
+		if m.items != nil && (v.Label == "" || m.key == v.Label) {
+			input = m
+			break
+		}
+	}
+	if input == nil {
+		return nil, nil, ez.New(op, ez.ENOTAUTHENTICATED, "Request is not signed", nil)
+	}
+
+	for _, m := range sigs {
+		if m.key == input.key && m.bytes != nil {
+			return input, m.bytes, nil
+		}
+	}
+
+	return nil, nil, ez.New(op, ez.ENOTAUTHENTICATED, "Signature "+input.key+" is missing", nil)
+}
+
+// checkTimes validates the created and expires parameters of a signature
+func (v *Verifier) checkTimes(params map[string]string, at time.Time) error {
+	const op = "httpsig.Verifier.checkTimes"
+
+	created, err := strconv.ParseInt(params["created"], 10, 64)
+	if err != nil {
+		return ez.New(op, ez.ENOTAUTHENTICATED, "Signature does not have a valid created time", err)
+	}
+
+	createdAt := time.Unix(created, 0)
+	if createdAt.After(at.Add(v.Skew)) {
+		return ez.New(op, ez.ENOTAUTHENTICATED, "Signature was created in the future", nil)
+	} else if v.MaxAge > 0 && at.After(createdAt.Add(v.MaxAge+v.Skew)) {
+		return ez.New(op, ez.ENOTAUTHENTICATED, "Signature is too old", nil)
+	}
+
+	if value, ok := params["expires"]; ok {
+		expires, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return ez.New(op, ez.ENOTAUTHENTICATED, "Signature does not have a valid expires time", err)
+		}
+
+		if !at.Before(time.Unix(expires, 0).Add(v.Skew)) {
+			return ez.New(op, ez.ENOTAUTHENTICATED, "Signature has expired", nil)
+		}
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
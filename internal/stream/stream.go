@@ -0,0 +1,192 @@
+// Package stream implements the chunking of the STREAM construction shared by
+// the nacl and age streams. The plaintext is split in chunks of ChunkSize
+// bytes that are sealed with the nonce returned for their counter, and only
+// the final chunk is sealed with last set, so reordered, dropped and truncated
+// chunks fail to decrypt
+package stream
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"io"
+
+	"github.com/vanclief/ez"
+)
+
+const (
+	// ChunkSize is the size of the plaintext of each chunk
+	ChunkSize = 64 * 1024
+	// TagSize is the size of the authentication tag of each chunk
+	TagSize = 16
+	// EncChunkSize is the size of each sealed chunk but the final one
+	EncChunkSize = ChunkSize + TagSize
+)
+
+// NonceFunc returns the nonce of the chunk with the counter, last is only
+// true for the final chunk
+type NonceFunc func(counter uint64, last bool) []byte
+
+// Config describes how the chunks of a stream are sealed
+type Config struct {
+	AEAD  cipher.AEAD
+	Nonce NonceFunc
+	// AdditionalData is authenticated with every chunk
+	AdditionalData []byte
+	// MaxCounter is the largest chunk counter allowed, zero means unlimited
+	MaxCounter uint64
+}
+
+// Writer seals everything written to it into the underlying writer. Close
+// must be called to write the final chunk
+type Writer struct {
+	w       io.Writer
+	config  Config
+	buf     []byte
+	counter uint64
+	err     error
+}
+
+// Reader opens a stream sealed by a Writer
+type Reader struct {
+	r       *bufio.Reader
+	config  Config
+	buf     []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+// NewWriter returns a Writer that writes the sealed chunks to w
+func NewWriter(w io.Writer, config Config) *Writer {
+	return &Writer{w: w, config: config, buf: make([]byte, 0, EncChunkSize)}
+}
+
+// Write seals p. A chunk is only written once it is full and more data
+// follows, the last one is written by Close
+func (s *Writer) Write(p []byte) (int, error) {
+	const op = "stream.Writer.Write"
+
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(s.buf) == ChunkSize {
+			err := s.flush(false)
+			if err != nil {
+				s.err = ez.Wrap(op, err)
+				return written, s.err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):ChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close writes the final chunk. It does not close the underlying writer
+func (s *Writer) Close() error {
+	const op = "stream.Writer.Close"
+
+	if s.err != nil {
+		return s.err
+	}
+
+	err := s.flush(true)
+	if err != nil {
+		s.err = ez.Wrap(op, err)
+		return s.err
+	}
+
+	s.err = ez.New(op, ez.EINVALID, "Writer is closed", nil)
+	return nil
+}
+
+func (s *Writer) flush(last bool) error {
+	const op = "stream.Writer.flush"
+
+	if s.config.MaxCounter != 0 && s.counter > s.config.MaxCounter {
+		return ez.New(op, ez.EINVALID, "Stream is too long", nil)
+	}
+
+	nonce := s.config.Nonce(s.counter, last)
+	chunk := s.config.AEAD.Seal(s.buf[:0], nonce, s.buf, s.config.AdditionalData)
+
+	_, err := s.w.Write(chunk)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while writing a stream chunk", err)
+	}
+
+	s.buf = s.buf[:0]
+	s.counter++
+
+	return nil
+}
+
+// NewReader returns a Reader that opens the sealed chunks read from r
+func NewReader(r io.Reader, config Config) *Reader {
+	return &Reader{r: bufio.NewReader(r), config: config, buf: make([]byte, EncChunkSize)}
+}
+
+// Read opens the stream. It returns an error if a chunk was modified,
+// reordered or removed, including the final one
+func (s *Reader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		} else if s.done {
+			return 0, io.EOF
+		}
+
+		s.err = s.next()
+	}
+
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+
+	return n, nil
+}
+
+func (s *Reader) next() error {
+	const op = "stream.Reader.Read"
+
+	n, err := io.ReadFull(s.r, s.buf)
+	last := false
+	switch err {
+	case nil:
+		_, peekErr := s.r.Peek(1)
+		last = peekErr == io.EOF
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		if _, ok := err.(*ez.Error); ok {
+			// Errors from readers of this module, like the age armor, keep their code
+			return ez.Wrap(op, err)
+		}
+		return ez.New(op, ez.EINTERNAL, "Error while reading a stream chunk", err)
+	}
+
+	if n < TagSize || (s.config.MaxCounter != 0 && s.counter > s.config.MaxCounter) {
+		return ez.New(op, ez.EINVALID, "Stream is truncated", nil)
+	}
+
+	nonce := s.config.Nonce(s.counter, last)
+	plain, err := s.config.AEAD.Open(s.buf[:0], nonce, s.buf[:n], s.config.AdditionalData)
+	if err != nil {
+		return ez.New(op, ez.EINVALID, "Could not open stream chunk, the stream was modified or truncated", nil)
+	} else if last && len(plain) == 0 && s.counter > 0 {
+		return ez.New(op, ez.EINVALID, "Stream has an empty final chunk", nil)
+	}
+
+	s.plain = plain
+	s.counter++
+	s.done = last
+
+	return nil
+}
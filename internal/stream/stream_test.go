@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func testConfig(t *testing.T, maxCounter uint64) Config {
+	aead, err := chacha20poly1305.New(make([]byte, chacha20poly1305.KeySize))
+	assert.Nil(t, err)
+
+	return Config{
+		AEAD: aead,
+		Nonce: func(counter uint64, last bool) []byte {
+			nonce := make([]byte, chacha20poly1305.NonceSize)
+			binary.BigEndian.PutUint64(nonce, counter)
+			if last {
+				nonce[len(nonce)-1] = 1
+			}
+			return nonce
+		},
+		AdditionalData: []byte("header"),
+		MaxCounter:     maxCounter,
+	}
+}
+
+func TestStream(t *testing.T) {
+	// Setup
+	config := testConfig(t, 0)
+
+	// Case 1: Should work with sizes around the chunk boundaries
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 2 * ChunkSize} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf, config)
+		_, err := w.Write(plaintext)
+		assert.Nil(t, err)
+		assert.Nil(t, w.Close())
+
+		decrypted, err := io.ReadAll(NewReader(&buf, config))
+		assert.Nil(t, err)
+		assert.Equal(t, plaintext, decrypted, size)
+	}
+
+	// Case 2: Should fail with other additional data
+	var buf bytes.Buffer
+	w := NewWriter(&buf, config)
+	w.Write([]byte("payload"))
+	assert.Nil(t, w.Close())
+
+	other := config
+	other.AdditionalData = []byte("other")
+	_, err := io.ReadAll(NewReader(bytes.NewReader(buf.Bytes()), other))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail to write once closed
+	_, err = w.Write([]byte("more"))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestStreamMaxCounter(t *testing.T) {
+	// Setup
+	config := testConfig(t, 1)
+	plaintext := make([]byte, 2*ChunkSize+1)
+
+	// Case 1: Should fail to write more chunks than allowed
+	w := NewWriter(io.Discard, config)
+	_, err := w.Write(plaintext)
+	assert.Nil(t, err)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(w.Close()))
+
+	// Case 2: Should fail to read more chunks than allowed
+	var buf bytes.Buffer
+	w = NewWriter(&buf, testConfig(t, 0))
+	w.Write(plaintext)
+	assert.Nil(t, w.Close())
+
+	_, err = io.ReadAll(NewReader(&buf, config))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
@@ -0,0 +1,499 @@
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/nacl"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	// ECDHES is the ECDH-ES direct key agreement algorithm
+	ECDHES Algorithm = "ECDH-ES"
+	// ECDHESA256KW is the ECDH-ES key agreement algorithm with AES-256 Key Wrap
+	ECDHESA256KW Algorithm = "ECDH-ES+A256KW"
+
+	// A256GCM is the AES-256-GCM content encryption algorithm
+	A256GCM Encryption = "A256GCM"
+	// XC20P is the XChaCha20-Poly1305 content encryption algorithm
+	XC20P Encryption = "XC20P"
+
+	keyType  = "OKP"
+	curve    = "X25519"
+	keySize  = 32
+	tagSize  = 16
+	kwIVSize = 8
+)
+
+// randReader is the source of randomness used for ephemeral keys, content
+// encryption keys and initialization vectors
+var randReader io.Reader = rand.Reader
+
+// defaultKWIV is the initial value defined in RFC 3394 section 2.2.3.1
+var defaultKWIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// Algorithm is the key management algorithm of a JWE
+type Algorithm string
+
+// Encryption is the content encryption algorithm of a JWE
+type Encryption string
+
+// JWK represents an X25519 public key as a JSON Web Key (RFC 8037)
+type JWK struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+}
+
+// Header represents the JOSE protected header of a JWE
+type Header struct {
+	Algorithm           Algorithm  `json:"alg"`
+	Encryption          Encryption `json:"enc"`
+	KeyID               string     `json:"kid,omitempty"`
+	Type                string     `json:"typ,omitempty"`
+	ContentType         string     `json:"cty,omitempty"`
+	EphemeralPublicKey  *JWK       `json:"epk,omitempty"`
+	AgreementPartyUInfo string     `json:"apu,omitempty"`
+	AgreementPartyVInfo string     `json:"apv,omitempty"`
+	Compression         string     `json:"zip,omitempty"`
+	Critical            []string   `json:"crit,omitempty"`
+}
+
+// NewJWK returns a JWK from an X25519 public key
+func NewJWK(publicKey []byte) *JWK {
+	return &JWK{
+		KeyType: keyType,
+		Curve:   curve,
+		X:       encodeSegment(publicKey),
+	}
+}
+
+// PublicKey returns the raw X25519 public key of a JWK
+func (jwk *JWK) PublicKey() ([]byte, error) {
+	const op = "jwe.JWK.PublicKey"
+
+	if jwk.KeyType != keyType || jwk.Curve != curve {
+		return nil, ez.New(op, ez.EINVALID, "JWK must be an OKP key on the X25519 curve", nil)
+	}
+
+	pub, err := decodeSegment(jwk.X)
+	if err != nil || len(pub) != keySize {
+		return nil, ez.New(op, ez.EINVALID, "JWK has an invalid X25519 public key", err)
+	}
+
+	return pub, nil
+}
+
+// Encrypt creates a JWE in compact serialization for the public key of the
+// recipient KeyPair
+func Encrypt(plaintext []byte, recipient *nacl.KeyPair, alg Algorithm, enc Encryption) (string, error) {
+	const op = "jwe.Encrypt"
+
+	token, err := EncryptWithHeader(plaintext, recipient, &Header{Algorithm: alg, Encryption: enc})
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return token, nil
+}
+
+// EncryptWithHeader creates a JWE in compact serialization for the public key
+// of the recipient KeyPair using the provided header. The ephemeral public key
+// is always generated and set by this function
+func EncryptWithHeader(plaintext []byte, recipient *nacl.KeyPair, header *Header) (string, error) {
+	const op = "jwe.EncryptWithHeader"
+
+	if recipient == nil || len(recipient.PublicKey) != keySize {
+		return "", ez.New(op, ez.EINVALID, "Recipient must have a 32 byte X25519 PublicKey", nil)
+	} else if header == nil {
+		return "", ez.New(op, ez.EINVALID, "Header can not be nil", nil)
+	} else if header.Compression != "" || len(header.Critical) > 0 {
+		return "", ez.New(op, ez.EINVALID, "Compression and critical headers are not supported", nil)
+	}
+
+	err := validateAlgorithms(header.Algorithm, header.Encryption)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	ephemeralPriv := make([]byte, keySize)
+	_, err = io.ReadFull(randReader, ephemeralPriv)
+	if err != nil {
+		return "", ez.New(op, ez.EINTERNAL, "Error while generating the ephemeral key", err)
+	}
+
+	ephemeralPub, err := curve25519.X25519(ephemeralPriv, curve25519.Basepoint)
+	if err != nil {
+		return "", ez.New(op, ez.EINTERNAL, "Error while generating the ephemeral key", err)
+	}
+
+	h := *header
+	h.EphemeralPublicKey = NewJWK(ephemeralPub)
+
+	kek, err := deriveKey(ephemeralPriv, recipient.PublicKey, &h)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	var cek, encryptedKey []byte
+	if h.Algorithm == ECDHES {
+		cek = kek
+	} else {
+		cek = make([]byte, keySize)
+		_, err = io.ReadFull(randReader, cek)
+		if err != nil {
+			return "", ez.New(op, ez.EINTERNAL, "Error while generating the content encryption key", err)
+		}
+
+		encryptedKey, err = keyWrap(kek, cek)
+		if err != nil {
+			return "", ez.Wrap(op, err)
+		}
+	}
+
+	protected, err := json.Marshal(&h)
+	if err != nil {
+		return "", ez.New(op, ez.EINTERNAL, "Error while encoding the protected header", err)
+	}
+	encodedHeader := encodeSegment(protected)
+
+	iv, ciphertext, tag, err := encryptContent(h.Encryption, cek, []byte(encodedHeader), plaintext)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	segments := []string{
+		encodedHeader,
+		encodeSegment(encryptedKey),
+		encodeSegment(iv),
+		encodeSegment(ciphertext),
+		encodeSegment(tag),
+	}
+
+	return strings.Join(segments, "."), nil
+}
+
+// Decrypt opens a JWE in compact serialization with the private key of the
+// recipient KeyPair and returns the plaintext and the protected header
+func Decrypt(token string, recipient *nacl.KeyPair) ([]byte, *Header, error) {
+	const op = "jwe.Decrypt"
+
+	if recipient == nil || len(recipient.PrivateKey) != keySize {
+		return nil, nil, ez.New(op, ez.EINVALID, "Recipient must have a 32 byte X25519 PrivateKey", nil)
+	}
+
+	segments := strings.Split(token, ".")
+	if len(segments) != 5 {
+		return nil, nil, ez.New(op, ez.EINVALID, "JWE compact serialization must have 5 segments", nil)
+	}
+
+	protected, err := decodeSegment(segments[0])
+	if err != nil {
+		return nil, nil, ez.New(op, ez.EINVALID, "JWE has an invalid protected header encoding", err)
+	}
+
+	header := &Header{}
+	err = json.Unmarshal(protected, header)
+	if err != nil {
+		return nil, nil, ez.New(op, ez.EINVALID, "JWE has an invalid protected header", err)
+	}
+
+	if header.Compression != "" || len(header.Critical) > 0 {
+		return nil, nil, ez.New(op, ez.EINVALID, "Compression and critical headers are not supported", nil)
+	} else if header.EphemeralPublicKey == nil {
+		return nil, nil, ez.New(op, ez.EINVALID, "JWE is missing the ephemeral public key", nil)
+	}
+
+	err = validateAlgorithms(header.Algorithm, header.Encryption)
+	if err != nil {
+		return nil, nil, ez.Wrap(op, err)
+	}
+
+	decoded := make([][]byte, 4)
+	for i, s := range segments[1:] {
+		decoded[i], err = decodeSegment(s)
+		if err != nil {
+			return nil, nil, ez.New(op, ez.EINVALID, "JWE has an invalid segment encoding", err)
+		}
+	}
+	encryptedKey, iv, ciphertext, tag := decoded[0], decoded[1], decoded[2], decoded[3]
+
+	ephemeralPub, err := header.EphemeralPublicKey.PublicKey()
+	if err != nil {
+		return nil, nil, ez.Wrap(op, err)
+	}
+
+	kek, err := deriveKey(recipient.PrivateKey, ephemeralPub, header)
+	if err != nil {
+		return nil, nil, ez.Wrap(op, err)
+	}
+
+	var cek []byte
+	if header.Algorithm == ECDHES {
+		if len(encryptedKey) != 0 {
+			return nil, nil, ez.New(op, ez.EINVALID, "ECDH-ES JWE must have an empty encrypted key", nil)
+		}
+		cek = kek
+	} else {
+		cek, err = keyUnwrap(kek, encryptedKey)
+		if err != nil {
+			return nil, nil, ez.Wrap(op, err)
+		} else if len(cek) != keySize {
+			return nil, nil, ez.New(op, ez.EINVALID, "JWE must wrap a 32 byte content encryption key", nil)
+		}
+	}
+
+	plaintext, err := decryptContent(header.Encryption, cek, []byte(segments[0]), iv, ciphertext, tag)
+	if err != nil {
+		return nil, nil, ez.Wrap(op, err)
+	}
+
+	return plaintext, header, nil
+}
+
+func validateAlgorithms(alg Algorithm, enc Encryption) error {
+	const op = "jwe.validateAlgorithms"
+
+	if alg != ECDHES && alg != ECDHESA256KW {
+		return ez.New(op, ez.EINVALID, "Unsupported key management algorithm", nil)
+	} else if enc != A256GCM && enc != XC20P {
+		return ez.New(op, ez.EINVALID, "Unsupported content encryption algorithm", nil)
+	}
+
+	return nil
+}
+
+// deriveKey performs the X25519 key agreement and derives the key encryption
+// key (or the content encryption key for ECDH-ES) with the Concat KDF
+func deriveKey(privateKey, publicKey []byte, header *Header) ([]byte, error) {
+	const op = "jwe.deriveKey"
+
+	z, err := curve25519.X25519(privateKey, publicKey)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not perform the X25519 key agreement", err)
+	}
+
+	key, err := deriveKeyFromSecret(z, header, keySize)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return key, nil
+}
+
+// deriveKeyFromSecret derives a key of size bytes from the shared secret with
+// the Concat KDF, using the algorithm and party info of the header
+func deriveKeyFromSecret(z []byte, header *Header, size int) ([]byte, error) {
+	const op = "jwe.deriveKeyFromSecret"
+
+	apu, err := decodeSegment(header.AgreementPartyUInfo)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Header has an invalid apu encoding", err)
+	}
+
+	apv, err := decodeSegment(header.AgreementPartyVInfo)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Header has an invalid apv encoding", err)
+	}
+
+	algID := string(header.Algorithm)
+	if header.Algorithm == ECDHES {
+		algID = string(header.Encryption)
+	}
+
+	return concatKDF(z, []byte(algID), apu, apv, size), nil
+}
+
+// concatKDF implements the Concat KDF from NIST SP 800-56A as profiled by
+// RFC 7518 section 4.6.2
+func concatKDF(z, algID, apu, apv []byte, size int) []byte {
+	otherInfo := lengthPrefixed(algID)
+	otherInfo = append(otherInfo, lengthPrefixed(apu)...)
+	otherInfo = append(otherInfo, lengthPrefixed(apv)...)
+	otherInfo = appendUint32(otherInfo, uint32(size*8))
+
+	var out []byte
+	for counter := uint32(1); len(out) < size; counter++ {
+		h := sha256.New()
+		h.Write(appendUint32(nil, counter))
+		h.Write(z)
+		h.Write(otherInfo)
+		out = h.Sum(out)
+	}
+
+	return out[:size]
+}
+
+func lengthPrefixed(b []byte) []byte {
+	return append(appendUint32(nil, uint32(len(b))), b...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// keyWrap wraps a key with the AES Key Wrap algorithm from RFC 3394
+func keyWrap(kek, key []byte) ([]byte, error) {
+	const op = "jwe.keyWrap"
+
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, ez.New(op, ez.EINVALID, "Key to wrap must be a multiple of 64 bits", nil)
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Invalid key encryption key", err)
+	}
+
+	n := len(key) / 8
+	r := make([][]byte, n)
+	for i := range r {
+		r[i] = append([]byte{}, key[i*8:i*8+8]...)
+	}
+
+	buf := make([]byte, 16)
+	copy(buf, defaultKWIV)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(buf[8:], r[i])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i + 1)
+			for k := 0; k < 8; k++ {
+				buf[k] ^= byte(t >> uint(56-8*k))
+			}
+			copy(r[i], buf[8:])
+		}
+	}
+
+	out := make([]byte, 0, len(key)+kwIVSize)
+	out = append(out, buf[:8]...)
+	for i := range r {
+		out = append(out, r[i]...)
+	}
+
+	return out, nil
+}
+
+// keyUnwrap unwraps a key with the AES Key Wrap algorithm from RFC 3394
+func keyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	const op = "jwe.keyUnwrap"
+
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, ez.New(op, ez.EINVALID, "Wrapped key must be a multiple of 64 bits", nil)
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Invalid key encryption key", err)
+	}
+
+	n := len(wrapped)/8 - 1
+	r := make([][]byte, n)
+	for i := range r {
+		r[i] = append([]byte{}, wrapped[(i+1)*8:(i+2)*8]...)
+	}
+
+	buf := make([]byte, 16)
+	copy(buf, wrapped[:8])
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			for k := 0; k < 8; k++ {
+				buf[k] ^= byte(t >> uint(56-8*k))
+			}
+			copy(buf[8:], r[i])
+			block.Decrypt(buf, buf)
+			copy(r[i], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(buf[:8], defaultKWIV) != 1 {
+		return nil, ez.New(op, ez.EINVALID, "Could not unwrap key, invalid wrapped key or credentials", nil)
+	}
+
+	out := make([]byte, 0, n*8)
+	for i := range r {
+		out = append(out, r[i]...)
+	}
+
+	return out, nil
+}
+
+func newAEAD(enc Encryption, cek []byte) (cipher.AEAD, error) {
+	if enc == XC20P {
+		return chacha20poly1305.NewX(cek)
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func encryptContent(enc Encryption, cek, aad, plaintext []byte) ([]byte, []byte, []byte, error) {
+	const op = "jwe.encryptContent"
+
+	aead, err := newAEAD(enc, cek)
+	if err != nil {
+		return nil, nil, nil, ez.New(op, ez.EINTERNAL, "Error while creating the content cipher", err)
+	}
+
+	iv := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(randReader, iv)
+	if err != nil {
+		return nil, nil, nil, ez.New(op, ez.EINTERNAL, "Error while generating the initialization vector", err)
+	}
+
+	sealed := aead.Seal(nil, iv, plaintext, aad)
+	split := len(sealed) - tagSize
+
+	return iv, sealed[:split], sealed[split:], nil
+}
+
+func decryptContent(enc Encryption, cek, aad, iv, ciphertext, tag []byte) ([]byte, error) {
+	const op = "jwe.decryptContent"
+
+	aead, err := newAEAD(enc, cek)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Error while creating the content cipher", err)
+	}
+
+	if len(iv) != aead.NonceSize() || len(tag) != tagSize {
+		return nil, ez.New(op, ez.EINVALID, "JWE has an invalid initialization vector or tag length", nil)
+	}
+
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(sealed, ciphertext...)
+	sealed = append(sealed, tag...)
+
+	plaintext, err := aead.Open(nil, iv, sealed, aad)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not decrypt JWE, invalid token or credentials", nil)
+	}
+
+	return plaintext, nil
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,255 @@
+package jwe
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"github.com/vanclief/go-crypto/nacl"
+)
+
+// RFC 8037 Appendix A.6 (keys from RFC 7748 section 6.1)
+const (
+	bobPublicHex       = "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"
+	bobPrivateHex      = "5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb"
+	ephemeralPrivHex   = "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"
+	ephemeralPubBase64 = "hSDwCYkwp1R0i33ctD73Wg2_Og0mOBr066SpjqqbTmo"
+	sharedSecretHex    = "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func bobKeyPair() *nacl.KeyPair {
+	return &nacl.KeyPair{KeyPair: keys.NewKeyPair(mustHex(bobPublicHex), mustHex(bobPrivateHex), keys.C25519)}
+}
+
+func withRandReader(r []byte, f func()) {
+	randReader = bytes.NewReader(r)
+	defer func() { randReader = rand.Reader }()
+	f()
+}
+
+func TestConcatKDF(t *testing.T) {
+	// RFC 7518 Appendix C
+	z := []byte{
+		158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132,
+		38, 156, 251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121,
+		140, 254, 144, 196}
+
+	out := concatKDF(z, []byte("A128GCM"), []byte("Alice"), []byte("Bob"), 16)
+	assert.Equal(t, "VqqN6vgjbSBcIijNcacQGg", encodeSegment(out))
+}
+
+func TestKeyWrap(t *testing.T) {
+	kek := mustHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+
+	// Case 1: RFC 3394 section 4.5, 192 bits of key data
+	key := mustHex("00112233445566778899aabbccddeeff0001020304050607")
+	wrapped, err := keyWrap(kek, key)
+	assert.Nil(t, err)
+	assert.Equal(t, mustHex("a8f9bc1612c68b3ff6e6f4fbe30e71e4769c8b80a32cb8958cd5d17d6b254da1"), wrapped)
+
+	unwrapped, err := keyUnwrap(kek, wrapped)
+	assert.Nil(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	// Case 2: RFC 3394 section 4.6, 256 bits of key data
+	key = mustHex("00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f")
+	wrapped, err = keyWrap(kek, key)
+	assert.Nil(t, err)
+	assert.Equal(t, mustHex("28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21"), wrapped)
+
+	// Case 3: Should fail to unwrap with a tampered key
+	wrapped[0] ^= 1
+	unwrapped, err = keyUnwrap(kek, wrapped)
+	assert.Nil(t, unwrapped)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestEncryptContent(t *testing.T) {
+	// RFC 7516 Appendix A.1
+	cek := []byte{
+		177, 161, 244, 128, 84, 143, 225, 115, 63, 180, 3, 255, 107, 154,
+		212, 246, 138, 7, 110, 91, 112, 46, 34, 105, 47, 130, 203, 46, 122,
+		234, 64, 252}
+	iv := []byte{227, 197, 117, 252, 2, 219, 233, 68, 180, 225, 77, 219}
+	aad := []byte("eyJhbGciOiJSU0EtT0FFUCIsImVuYyI6IkEyNTZHQ00ifQ")
+	plaintext := []byte("The true sign of intelligence is not knowledge but imagination.")
+
+	withRandReader(iv, func() {
+		gotIV, ciphertext, tag, err := encryptContent(A256GCM, cek, aad, plaintext)
+		assert.Nil(t, err)
+		assert.Equal(t, iv, gotIV)
+		assert.Equal(t, "5eym8TW_c8SuK0ltJ3rpYIzOeDQz7TALvtu6UG9oMo4vpzs9tX_EFShS8iB7j6jiSdiwkIr3ajwQzaBtQD_A", encodeSegment(ciphertext))
+		assert.Equal(t, "XFBoMYUZodetZdvTiFvSkQ", encodeSegment(tag))
+
+		decrypted, err := decryptContent(A256GCM, cek, aad, iv, ciphertext, tag)
+		assert.Nil(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	})
+}
+
+func TestDeriveKey(t *testing.T) {
+	// Case 1: Should derive the RFC 7518 Appendix C key from its shared secret
+	z := []byte{
+		158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132,
+		38, 156, 251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121,
+		140, 254, 144, 196}
+	header := &Header{Algorithm: ECDHES, Encryption: "A128GCM", AgreementPartyUInfo: "QWxpY2U", AgreementPartyVInfo: "Qm9i"}
+
+	key, err := deriveKeyFromSecret(z, header, 16)
+	assert.Nil(t, err)
+	assert.Equal(t, "VqqN6vgjbSBcIijNcacQGg", encodeSegment(key))
+
+	// Case 2: Should agree on the RFC 8037 Appendix A.6 shared secret from both sides
+	header = &Header{Algorithm: ECDHES, Encryption: A256GCM}
+	expected, err := deriveKeyFromSecret(mustHex(sharedSecretHex), header, keySize)
+	assert.Nil(t, err)
+
+	kek, err := deriveKey(mustHex(ephemeralPrivHex), mustHex(bobPublicHex), header)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, kek)
+
+	ephemeralPub, err := decodeSegment(ephemeralPubBase64)
+	assert.Nil(t, err)
+	kek, err = deriveKey(mustHex(bobPrivateHex), ephemeralPub, header)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, kek)
+
+	// Case 3: Should fail with an invalid apu encoding
+	_, err = deriveKeyFromSecret(z, &Header{Algorithm: ECDHES, AgreementPartyUInfo: "!"}, 16)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestEncrypt(t *testing.T) {
+	recipient := bobKeyPair()
+	plaintext := []byte("Live long and prosper.")
+
+	// Case 1: Should use the RFC 8037 ephemeral key
+	random := append(mustHex(ephemeralPrivHex), make([]byte, 12)...)
+	withRandReader(random, func() {
+		token, err := Encrypt(plaintext, recipient, ECDHES, A256GCM)
+		assert.Nil(t, err)
+		assert.Len(t, strings.Split(token, "."), 5)
+
+		decrypted, header, err := Decrypt(token, recipient)
+		assert.Nil(t, err)
+		assert.Equal(t, plaintext, decrypted)
+		assert.Equal(t, ephemeralPubBase64, header.EphemeralPublicKey.X)
+		assert.Equal(t, "OKP", header.EphemeralPublicKey.KeyType)
+		assert.Equal(t, "X25519", header.EphemeralPublicKey.Curve)
+	})
+
+	// Case 2: Should fail with an unsupported algorithm
+	token, err := Encrypt(plaintext, recipient, Algorithm("RSA-OAEP"), A256GCM)
+	assert.Equal(t, "", token)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail without a public key
+	token, err = Encrypt(plaintext, &nacl.KeyPair{KeyPair: keys.NewKeyPair(nil, nil, keys.C25519)}, ECDHES, A256GCM)
+	assert.Equal(t, "", token)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestEncryptWithHeader(t *testing.T) {
+	recipient, _ := nacl.NewKeyPair()
+	header := &Header{
+		Algorithm:           ECDHESA256KW,
+		Encryption:          XC20P,
+		KeyID:               "bob",
+		AgreementPartyUInfo: encodeSegment([]byte("Alice")),
+		AgreementPartyVInfo: encodeSegment([]byte("Bob")),
+	}
+
+	token, err := EncryptWithHeader([]byte("claims"), recipient, header)
+	assert.Nil(t, err)
+
+	decrypted, decoded, err := Decrypt(token, recipient)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("claims"), decrypted)
+	assert.Equal(t, "bob", decoded.KeyID)
+	assert.Nil(t, header.EphemeralPublicKey)
+}
+
+func TestDecrypt(t *testing.T) {
+	// Setup
+	recipient, _ := nacl.NewKeyPair()
+	other, _ := nacl.NewKeyPair()
+	msg := []byte("PII bearing claims")
+
+	algorithms := []Algorithm{ECDHES, ECDHESA256KW}
+	encryptions := []Encryption{A256GCM, XC20P}
+
+	// Case 1: Should work with every algorithm combination
+	for _, alg := range algorithms {
+		for _, enc := range encryptions {
+			token, err := Encrypt(msg, recipient, alg, enc)
+			assert.Nil(t, err)
+
+			decrypted, header, err := Decrypt(token, recipient)
+			assert.Nil(t, err)
+			assert.Equal(t, msg, decrypted)
+			assert.Equal(t, alg, header.Algorithm)
+			assert.Equal(t, enc, header.Encryption)
+
+			// Should fail with another recipient
+			decrypted, _, err = Decrypt(token, other)
+			assert.Nil(t, decrypted)
+			assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+		}
+	}
+
+	token, _ := Encrypt(msg, recipient, ECDHESA256KW, A256GCM)
+	segments := strings.Split(token, ".")
+
+	// Case 2: Should fail with a tampered header
+	tampered := append([]string{encodeSegment([]byte(`{"alg":"ECDH-ES+A256KW","enc":"A256GCM"}`))}, segments[1:]...)
+	decrypted, _, err := Decrypt(strings.Join(tampered, "."), recipient)
+	assert.Nil(t, decrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with a tampered ciphertext
+	ciphertext, _ := decodeSegment(segments[3])
+	ciphertext[0] ^= 1
+	tampered = []string{segments[0], segments[1], segments[2], encodeSegment(ciphertext), segments[4]}
+	decrypted, _, err = Decrypt(strings.Join(tampered, "."), recipient)
+	assert.Nil(t, decrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with a malformed token
+	decrypted, _, err = Decrypt("not.a.jwe", recipient)
+	assert.Nil(t, decrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail when the wrapped key is shorter than the content encryption key
+	header := &Header{}
+	protected, _ := decodeSegment(segments[0])
+	assert.Nil(t, json.Unmarshal(protected, header))
+	ephemeralPub, err := header.EphemeralPublicKey.PublicKey()
+	assert.Nil(t, err)
+	kek, err := deriveKey(recipient.PrivateKey, ephemeralPub, header)
+	assert.Nil(t, err)
+
+	cek := make([]byte, 16)
+	wrapped, err := keyWrap(kek, cek)
+	assert.Nil(t, err)
+	iv, ciphertext, tag, err := encryptContent(A256GCM, cek, []byte(segments[0]), msg)
+	assert.Nil(t, err)
+
+	short := []string{segments[0], encodeSegment(wrapped), encodeSegment(iv), encodeSegment(ciphertext), encodeSegment(tag)}
+	decrypted, _, err = Decrypt(strings.Join(short, "."), recipient)
+	assert.Nil(t, decrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
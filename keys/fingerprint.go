@@ -0,0 +1,171 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	randomartWidth   = 17
+	randomartHeight  = 9
+	randomartSymbols = " .o+=*BOX@%&#/^SE"
+	keyIDSize        = 8
+)
+
+// Fingerprint returns the SHA-256 fingerprint of the key in the OpenSSH style,
+// for example SHA256:4NY6tXI7MfqlxSCOUVN6dqAtvxOjbbTOxwqCGrGAk88
+func (k *Key) Fingerprint() string {
+	return formatFingerprint(k.digest())
+}
+
+// FingerprintHex returns the SHA-256 fingerprint of the key as hex with colons
+func (k *Key) FingerprintHex() string {
+	return formatFingerprintHex(k.digest())
+}
+
+// KeyID returns a short identifier of the key, the first 8 bytes of its
+// SHA-256 fingerprint in hex
+func (k *Key) KeyID() string {
+	return formatKeyID(k.digest())
+}
+
+// Randomart returns the OpenSSH visual representation of the key fingerprint
+func (k *Key) Randomart() string {
+	return randomart(k.Type, len(k.Value)*8, k.digest())
+}
+
+func (k *Key) digest() []byte {
+	sum := sha256.Sum256(k.Value)
+	return sum[:]
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the public key in the OpenSSH
+// style. For ed25519 key pairs it matches the output of ssh-keygen -l
+func (kp *KeyPair) Fingerprint() string {
+	return formatFingerprint(kp.digest())
+}
+
+// FingerprintHex returns the SHA-256 fingerprint of the public key as hex with
+// colons
+func (kp *KeyPair) FingerprintHex() string {
+	return formatFingerprintHex(kp.digest())
+}
+
+// KeyID returns a short identifier of the public key, the first 8 bytes of its
+// SHA-256 fingerprint in hex
+func (kp *KeyPair) KeyID() string {
+	return formatKeyID(kp.digest())
+}
+
+// Randomart returns the OpenSSH visual representation of the public key
+// fingerprint. For ed25519 key pairs it matches the output of ssh-keygen -lv
+func (kp *KeyPair) Randomart() string {
+	return randomart(kp.Type, len(kp.PublicKey)*8, kp.digest())
+}
+
+// digest hashes the public key, using the SSH wire encoding for ed25519 keys
+// so fingerprints can be compared with OpenSSH
+func (kp *KeyPair) digest() []byte {
+	blob := kp.PublicKey
+	if kp.Type == ED25519 {
+		blob = sshString(nil, []byte("ssh-ed25519"))
+		blob = sshString(blob, kp.PublicKey)
+	}
+
+	sum := sha256.Sum256(blob)
+	return sum[:]
+}
+
+func sshString(b, s []byte) []byte {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(s)))
+	return append(append(b, l[:]...), s...)
+}
+
+func formatFingerprint(digest []byte) string {
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(digest)
+}
+
+func formatFingerprintHex(digest []byte) string {
+	parts := make([]string, len(digest))
+	for i, b := range digest {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func formatKeyID(digest []byte) string {
+	return fmt.Sprintf("%X", digest[:keyIDSize])
+}
+
+// randomart implements the drunken bishop algorithm used by OpenSSH
+func randomart(t Type, bits int, digest []byte) string {
+	var field [randomartWidth][randomartHeight]int
+	maxSymbol := len(randomartSymbols) - 1
+
+	x, y := randomartWidth/2, randomartHeight/2
+	for _, b := range digest {
+		for i := 0; i < 4; i++ {
+			if b&0x1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&0x2 != 0 {
+				y++
+			} else {
+				y--
+			}
+
+			x = clamp(x, 0, randomartWidth-1)
+			y = clamp(y, 0, randomartHeight-1)
+
+			if field[x][y] < maxSymbol-2 {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+
+	field[randomartWidth/2][randomartHeight/2] = maxSymbol - 1
+	field[x][y] = maxSymbol
+
+	title := fmt.Sprintf("[%s %d]", strings.ToUpper(string(t)), bits)
+	if len(title) >= randomartWidth {
+		title = fmt.Sprintf("[%s]", strings.ToUpper(string(t)))
+	}
+	if len(title) >= randomartWidth {
+		title = title[:randomartWidth-1]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(randomartBorder(title))
+	for j := 0; j < randomartHeight; j++ {
+		sb.WriteByte('|')
+		for i := 0; i < randomartWidth; i++ {
+			sb.WriteByte(randomartSymbols[field[i][j]])
+		}
+		sb.WriteString("|\n")
+	}
+	sb.WriteString(strings.TrimSuffix(randomartBorder("[SHA256]"), "\n"))
+
+	return sb.String()
+}
+
+func randomartBorder(title string) string {
+	left := (randomartWidth - len(title)) / 2
+	right := randomartWidth - len(title) - left
+	return "+" + strings.Repeat("-", left) + title + strings.Repeat("-", right) + "+\n"
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	} else if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package keys
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Public key generated with ssh-keygen -t ed25519
+const testPublicKeyHex = "a495eb1ccf0e9f7aa12b1e1ccffc2fd3b8bd582b1bf5c69f40908bcf24945ef7"
+
+const expectedRandomart = `+--[ED25519 256]--+
+|        o        |
+|       = .       |
+|      B + .      |
+|..   o O .       |
+|*   . + S        |
+|.* . = BoO       |
+|o E o B.O++      |
+| o   ..O.*o      |
+|.      .B+       |
++----[SHA256]-----+`
+
+func testKeyPair() *KeyPair {
+	pub, _ := hex.DecodeString(testPublicKeyHex)
+	return NewKeyPair(pub, nil, ED25519)
+}
+
+func TestKeyPairFingerprint(t *testing.T) {
+	kp := testKeyPair()
+
+	// Case 1: Should give the same result as ssh-keygen -l
+	assert.Equal(t, "SHA256:4NY6tXI7MfqlxSCOUVN6dqAtvxOjbbTOxwqCGrGAk88", kp.Fingerprint())
+
+	// Case 2: Should not depend on the private key
+	kp.PrivateKey = []byte("secret")
+	assert.Equal(t, "SHA256:4NY6tXI7MfqlxSCOUVN6dqAtvxOjbbTOxwqCGrGAk88", kp.Fingerprint())
+
+	// Case 3: Should depend on the key type
+	c := NewKeyPair(kp.PublicKey, nil, C25519)
+	assert.NotEqual(t, kp.Fingerprint(), c.Fingerprint())
+}
+
+func TestKeyPairFingerprintHex(t *testing.T) {
+	kp := testKeyPair()
+	fp := kp.FingerprintHex()
+
+	assert.Equal(t, "e0:d6:3a:b5:72:3b:31:fa:a5:c5:20:8e:51:53:7a:76:a0:2d:bf:13:a3:6d:b4:ce:c7:0a:82:1a:b1:80:93:cf", fp)
+	assert.Len(t, strings.Split(fp, ":"), 32)
+}
+
+func TestKeyPairKeyID(t *testing.T) {
+	kp := testKeyPair()
+	assert.Equal(t, "E0D63AB5723B31FA", kp.KeyID())
+}
+
+func TestKeyPairRandomart(t *testing.T) {
+	// Case 1: Should give the same result as ssh-keygen -lv
+	kp := testKeyPair()
+	assert.Equal(t, expectedRandomart, kp.Randomart())
+
+	// Case 2: Should fit long key types in the border
+	c := NewKeyPair(kp.PublicKey, nil, C25519)
+	lines := strings.Split(c.Randomart(), "\n")
+	assert.Len(t, lines, 11)
+	assert.Equal(t, "+[CURVE25519 256]-+", lines[0])
+	for _, line := range lines {
+		assert.Len(t, line, 19)
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	k := New([]byte("This is a string"), Argon2)
+
+	assert.Equal(t, "SHA256:TpUYV1QiyQhzloh84gR3q19VCkqj0WHFwiqZawq7izU", k.Fingerprint())
+	assert.Len(t, strings.Split(k.FingerprintHex(), ":"), 32)
+	assert.Len(t, k.KeyID(), 16)
+	assert.True(t, strings.HasPrefix(k.Randomart(), "+--[ARGON2 128]---+\n"))
+}
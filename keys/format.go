@@ -0,0 +1,90 @@
+package keys
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+const redacted = "REDACTED"
+
+// redactedKey is the JSON representation of a key that hides its value. It has
+// no value field so decoding it never produces key material
+type redactedKey struct {
+	Type        Type   `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// plainKey and plainKeyPair have the default JSON encoding, which includes
+// the key bytes
+type plainKey Key
+type plainKeyPair KeyPair
+
+// String returns the type and fingerprint of the key, never its value
+func (k Key) String() string {
+	return fmt.Sprintf("%s key %s (%s)", k.Type, k.Fingerprint(), redacted)
+}
+
+// Format implements fmt.Formatter so every verb prints the redacted String
+func (k Key) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, k.String())
+}
+
+// LogValue implements slog.LogValuer so the key value is never logged
+func (k Key) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", string(k.Type)),
+		slog.String("fingerprint", k.Fingerprint()),
+	)
+}
+
+// MarshalJSON encodes the type and fingerprint of the key, never its value.
+// Use ExportJSON to encode the key value
+func (k Key) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redactedKey{Type: k.Type, Fingerprint: k.Fingerprint()})
+}
+
+// ExportJSON encodes the key including its value. The output can be decoded
+// with json.Unmarshal and must be handled as a secret
+func (k *Key) ExportJSON() ([]byte, error) {
+	return json.Marshal((*plainKey)(k))
+}
+
+// String returns the type and public key fingerprint of the KeyPair, never its
+// private key
+func (kp KeyPair) String() string {
+	return fmt.Sprintf("%s key pair %s (%s)", kp.Type, kp.Fingerprint(), redacted)
+}
+
+// Format implements fmt.Formatter so every verb prints the redacted String
+func (kp KeyPair) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, kp.String())
+}
+
+// LogValue implements slog.LogValuer so the private key is never logged
+func (kp KeyPair) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", string(kp.Type)),
+		slog.String("fingerprint", kp.Fingerprint()),
+	)
+}
+
+// MarshalJSON encodes the type and public key fingerprint of the KeyPair,
+// never its keys. Use ExportJSON to encode the keys
+func (kp KeyPair) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redactedKey{Type: kp.Type, Fingerprint: kp.Fingerprint()})
+}
+
+// ExportJSON encodes the KeyPair including its private key. The output can be
+// decoded with json.Unmarshal and must be handled as a secret
+func (kp *KeyPair) ExportJSON() ([]byte, error) {
+	return json.Marshal((*plainKeyPair)(kp))
+}
+
+func formatRedacted(f fmt.State, verb rune, s string) {
+	if verb == 'q' {
+		s = strconv.Quote(s)
+	}
+	fmt.Fprint(f, s)
+}
@@ -0,0 +1,127 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("super secret key material 123456")
+
+func TestKeyFormat(t *testing.T) {
+	// Setup
+	key := New(append([]byte{}, testSecret...), C25519)
+	expected := "Curve25519 key " + key.Fingerprint() + " (REDACTED)"
+
+	// Case 1: Should redact every verb
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%x", "%X", "%d"} {
+		assert.Equal(t, expected, fmt.Sprintf(verb, key), verb)
+		assert.NotContains(t, fmt.Sprintf(verb, key), "secret", verb)
+	}
+	assert.Equal(t, `"`+expected+`"`, fmt.Sprintf("%q", key))
+
+	// Case 2: Should redact keys inside other values
+	s := fmt.Sprintf("%+v", struct{ Key *Key }{key})
+	assert.Contains(t, s, expected)
+
+	// Case 3: Should redact keys passed by value and struct fields of type Key
+	assert.Equal(t, expected, fmt.Sprintf("%v", *key))
+	s = fmt.Sprintf("%+v", struct{ Key Key }{*key})
+	assert.Contains(t, s, expected)
+	assert.NotContains(t, s, "secret")
+	assert.NotContains(t, fmt.Sprintf("%#v", struct{ Key Key }{*key}), "secret")
+
+	// Case 4: Should handle nil keys
+	var nilKey *Key
+	assert.Equal(t, "<nil>", fmt.Sprintf("%v", nilKey))
+}
+
+func TestKeyJSON(t *testing.T) {
+	// Setup
+	key := New(append([]byte{}, testSecret...), C25519)
+
+	// Case 1: Should redact the value
+	b, err := json.Marshal(key)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"type":"Curve25519","fingerprint":"`+key.Fingerprint()+`"}`, string(b))
+
+	// Case 2: Should not decode the redacted encoding into key material
+	decoded := &Key{}
+	err = json.Unmarshal(b, decoded)
+	assert.Nil(t, err)
+	assert.Nil(t, decoded.Value)
+	assert.Equal(t, key.Type, decoded.Type)
+
+	// Case 3: Should redact keys encoded by value and in struct fields
+	b, err = json.Marshal(struct {
+		Key     Key
+		KeyPair KeyPair
+	}{*key, *NewKeyPair([]byte("public"), key.Value, C25519)})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "c3VwZXIgc2VjcmV0")
+	assert.Contains(t, string(b), key.Fingerprint())
+
+	// Case 4: Should export the value when asked explicitly
+	b, err = key.ExportJSON()
+	assert.Nil(t, err)
+
+	exported := &Key{}
+	err = json.Unmarshal(b, exported)
+	assert.Nil(t, err)
+	assert.Equal(t, key.Value, exported.Value)
+	assert.Equal(t, key.Type, exported.Type)
+}
+
+func TestKeyPairFormat(t *testing.T) {
+	// Setup
+	kp := NewKeyPair([]byte("public"), append([]byte{}, testSecret...), ED25519)
+	expected := "ed25519 key pair " + kp.Fingerprint() + " (REDACTED)"
+
+	// Case 1: Should redact every verb, also when passed by value
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%x"} {
+		assert.Equal(t, expected, fmt.Sprintf(verb, kp), verb)
+		assert.Equal(t, expected, fmt.Sprintf(verb, *kp), verb)
+	}
+
+	// Case 2: Should redact the JSON encoding
+	b, err := json.Marshal(kp)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "c3VwZXIgc2VjcmV0")
+	assert.Contains(t, string(b), kp.Fingerprint())
+
+	decoded := &KeyPair{}
+	err = json.Unmarshal(b, decoded)
+	assert.Nil(t, err)
+	assert.Nil(t, decoded.PrivateKey)
+	assert.Nil(t, decoded.PublicKey)
+
+	// Case 3: Should export the keys when asked explicitly
+	b, err = kp.ExportJSON()
+	assert.Nil(t, err)
+
+	exported := &KeyPair{}
+	err = json.Unmarshal(b, exported)
+	assert.Nil(t, err)
+	assert.Equal(t, kp.PrivateKey, exported.PrivateKey)
+	assert.Equal(t, kp.PublicKey, exported.PublicKey)
+}
+
+func TestLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	key := New(append([]byte{}, testSecret...), C25519)
+	kp := NewKeyPair([]byte("public"), append([]byte{}, testSecret...), ED25519)
+	logger.Info("loaded", "key", key, "keypair", kp, "value", *key)
+
+	out := buf.String()
+	assert.False(t, strings.Contains(out, "secret"))
+	assert.Contains(t, out, "key.type=Curve25519")
+	assert.Contains(t, out, "key.fingerprint="+key.Fingerprint())
+	assert.Contains(t, out, "keypair.fingerprint="+kp.Fingerprint())
+}
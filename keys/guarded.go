@@ -0,0 +1,54 @@
+package keys
+
+import "github.com/vanclief/ez"
+
+// GuardedBuffer is a fixed size buffer for secrets. On Linux it is allocated
+// outside of the Go heap between two inaccessible guard pages, locked in
+// memory so it is never swapped out and excluded from core dumps. On other
+// platforms it is a regular buffer that is wiped when destroyed
+type GuardedBuffer struct {
+	data   []byte
+	region []byte
+}
+
+// NewGuardedBuffer allocates a zeroed GuardedBuffer of the given size
+func NewGuardedBuffer(size int) (*GuardedBuffer, error) {
+	const op = "keys.NewGuardedBuffer"
+
+	if size <= 0 {
+		return nil, ez.New(op, ez.EINVALID, "Size must be greater than zero", nil)
+	}
+
+	b, err := allocGuarded(size)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while allocating guarded memory", err)
+	}
+
+	return b, nil
+}
+
+// Bytes returns the contents of the buffer. The slice must not be used after
+// the buffer is destroyed
+func (b *GuardedBuffer) Bytes() []byte {
+	return b.data
+}
+
+// Destroy wipes the buffer and releases its memory. It is safe to call more
+// than once
+func (b *GuardedBuffer) Destroy() error {
+	const op = "keys.GuardedBuffer.Destroy"
+
+	if b.data == nil {
+		return nil
+	}
+
+	Wipe(b.data)
+	b.data = nil
+
+	err := freeGuarded(b)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while releasing guarded memory", err)
+	}
+
+	return nil
+}
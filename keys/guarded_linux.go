@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package keys
+
+import (
+	"os"
+	"syscall"
+)
+
+// madvDontDump is MADV_DONTDUMP, which is not exported by package syscall
+const madvDontDump = 0x10
+
+// allocGuarded maps the buffer pages with a guard page on each side. The data
+// is placed at the end of the locked pages so an overflow hits the guard page
+func allocGuarded(size int) (*GuardedBuffer, error) {
+	page := os.Getpagesize()
+	inner := (size + page - 1) / page * page
+
+	region, err := syscall.Mmap(-1, 0, inner+2*page, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+
+	err = guardRegion(region, page, inner)
+	if err != nil {
+		syscall.Munmap(region)
+		return nil, err
+	}
+
+	data := region[page+inner-size : page+inner]
+	return &GuardedBuffer{data: data[:size:size], region: region}, nil
+}
+
+func guardRegion(region []byte, page, inner int) error {
+	err := syscall.Mprotect(region[:page], syscall.PROT_NONE)
+	if err != nil {
+		return err
+	}
+
+	err = syscall.Mprotect(region[page+inner:], syscall.PROT_NONE)
+	if err != nil {
+		return err
+	}
+
+	err = syscall.Mlock(region[page : page+inner])
+	if err != nil {
+		return err
+	}
+
+	// Excluding the pages from core dumps is best effort
+	syscall.Madvise(region[page:page+inner], madvDontDump)
+
+	return nil
+}
+
+func freeGuarded(b *GuardedBuffer) error {
+	region := b.region
+	b.region = nil
+
+	page := os.Getpagesize()
+	syscall.Munlock(region[page : len(region)-page])
+
+	return syscall.Munmap(region)
+}
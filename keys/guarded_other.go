@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package keys
+
+// allocGuarded allocates a regular buffer on platforms without guarded memory
+func allocGuarded(size int) (*GuardedBuffer, error) {
+	return &GuardedBuffer{data: make([]byte, size)}, nil
+}
+
+func freeGuarded(b *GuardedBuffer) error {
+	return nil
+}
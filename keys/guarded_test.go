@@ -0,0 +1,59 @@
+package keys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func TestNewGuardedBuffer(t *testing.T) {
+	// Case 1: Should work
+	b, err := NewGuardedBuffer(64)
+	assert.Nil(t, err)
+	assert.Equal(t, make([]byte, 64), b.Bytes())
+	assert.Equal(t, 64, cap(b.Bytes()))
+
+	copy(b.Bytes(), "secret")
+	assert.Equal(t, "secret", string(b.Bytes()[:6]))
+
+	// Case 2: Should release the memory once
+	assert.Nil(t, b.Destroy())
+	assert.Nil(t, b.Bytes())
+	assert.Nil(t, b.Destroy())
+
+	// Case 3: Should fail with an invalid size
+	b, err = NewGuardedBuffer(0)
+	assert.Nil(t, b)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestNewGuarded(t *testing.T) {
+	// Setup
+	value := []byte("0123456789abcdef0123456789abcdef")
+
+	// Case 1: Should copy the value and wipe the original
+	key, err := NewGuarded(value, C25519)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", string(key.Value))
+	assert.Equal(t, make([]byte, 32), value)
+
+	// Case 2: Should release the value
+	key.Destroy()
+	assert.Nil(t, key.Value)
+}
+
+func TestNewGuardedKeyPair(t *testing.T) {
+	pub := []byte{1, 2, 3}
+	priv := []byte{4, 5, 6}
+
+	kp, err := NewGuardedKeyPair(pub, priv, ED25519)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{4, 5, 6}, kp.PrivateKey)
+	assert.Equal(t, []byte{0, 0, 0}, priv)
+
+	kp.Destroy()
+	assert.Nil(t, kp.PrivateKey)
+	assert.Nil(t, kp.PublicKey)
+	assert.Equal(t, []byte{0, 0, 0}, pub)
+}
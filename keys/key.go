@@ -16,11 +16,12 @@ type Type string
 type Key struct {
 	Value []byte
 	Type  Type
+	guard *GuardedBuffer
 }
 
 // New returns new key instance
 func New(value []byte, t Type) *Key {
-	return &Key{value, t}
+	return &Key{Value: value, Type: t}
 }
 
 // KeyPair represents a pair of cryptographic keys
@@ -28,6 +29,7 @@ type KeyPair struct {
 	PublicKey  []byte
 	PrivateKey []byte
 	Type       Type
+	guard      *GuardedBuffer
 }
 
 // NewKeyPair returns new KeyPair instance
@@ -1 +1,34 @@
 package keys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyDestroy(t *testing.T) {
+	// Setup
+	value := []byte{1, 2, 3, 4}
+	key := New(value, C25519)
+
+	// Case 1: Should zero the value
+	key.Destroy()
+	assert.Nil(t, key.Value)
+	assert.Equal(t, []byte{0, 0, 0, 0}, value)
+
+	// Case 2: Should be safe to call twice
+	key.Destroy()
+	assert.Nil(t, key.Value)
+}
+
+func TestKeyPairDestroy(t *testing.T) {
+	pub := []byte{1, 2}
+	priv := []byte{3, 4}
+	kp := NewKeyPair(pub, priv, ED25519)
+
+	kp.Destroy()
+	assert.Nil(t, kp.PublicKey)
+	assert.Nil(t, kp.PrivateKey)
+	assert.Equal(t, []byte{0, 0}, pub)
+	assert.Equal(t, []byte{0, 0}, priv)
+}
@@ -0,0 +1,79 @@
+package keys
+
+import "runtime"
+
+// Wipe overwrites a buffer with zeros
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+// Destroy zeroes the key and releases its guarded memory if it has any. Keys
+// sharing the same buffer, like the ones returned by Public or Private, must
+// not be used after the key is destroyed
+func (k *Key) Destroy() {
+	if k.guard != nil {
+		k.guard.Destroy()
+		k.guard = nil
+	} else {
+		Wipe(k.Value)
+	}
+	k.Value = nil
+}
+
+// Destroy zeroes both keys of the KeyPair and releases its guarded memory if
+// it has any. Keys sharing the same buffers, like the ones returned by Public
+// or Private, must not be used after the KeyPair is destroyed
+func (kp *KeyPair) Destroy() {
+	if kp.guard != nil {
+		kp.guard.Destroy()
+		kp.guard = nil
+	} else {
+		Wipe(kp.PrivateKey)
+	}
+	Wipe(kp.PublicKey)
+
+	kp.PrivateKey = nil
+	kp.PublicKey = nil
+}
+
+// NewGuarded returns a key whose value is stored in a GuardedBuffer. The value
+// is copied and the original is wiped
+func NewGuarded(value []byte, t Type) (*Key, error) {
+	guard, err := guardedCopy(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{Value: guard.Bytes(), Type: t, guard: guard}, nil
+}
+
+// NewGuardedKeyPair returns a KeyPair whose private key is stored in a
+// GuardedBuffer. The private key is copied and the original is wiped
+func NewGuardedKeyPair(pub []byte, priv []byte, t Type) (*KeyPair, error) {
+	guard, err := guardedCopy(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{
+		PublicKey:  pub,
+		PrivateKey: guard.Bytes(),
+		Type:       t,
+		guard:      guard,
+	}, nil
+}
+
+func guardedCopy(value []byte) (*GuardedBuffer, error) {
+	guard, err := NewGuardedBuffer(len(value))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(guard.Bytes(), value)
+	Wipe(value)
+
+	return guard, nil
+}
@@ -0,0 +1,201 @@
+package kms
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/aead"
+	"github.com/vanclief/go-crypto/keys"
+)
+
+// A ciphertext is encoded as the version byte, the length of the master key ID
+// and the master key ID, the length of the wrapped data key as 2 big endian
+// bytes and the wrapped data key, followed by the aead envelope of the payload.
+// The header is not bound to the payload, the KMS authenticates the wrapped
+// key and a data key from another ciphertext can not open the envelope, so a
+// ciphertext can be rewrapped without touching the payload
+const (
+	version        byte = 1
+	maxKeyID            = 255
+	maxWrappedKey       = 0xffff
+	dataKeyID           = "data"
+	fixedSize           = 1 + 1 + 2
+	defaultDataAlg      = aead.XChaCha20Poly1305
+)
+
+// ErrMalformed is returned when a ciphertext can not be decoded
+var ErrMalformed = ez.New("kms.Decrypt", ez.EINVALID, "Ciphertext is malformed", nil)
+
+// KMS wraps and unwraps data keys with master keys that never leave it
+type KMS interface {
+	// WrapKey encrypts a data key with the master key identified by keyID
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped by the master key identified by keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Ciphertext represents a payload encrypted with a data key and the data key
+// wrapped by a master key
+type Ciphertext struct {
+	KeyID      string
+	WrappedKey []byte
+	Envelope   *aead.Envelope
+}
+
+// Encrypt encrypts the plaintext with a new XChaCha20-Poly1305 data key and
+// stores it wrapped by the master key identified by keyID
+func Encrypt(ctx context.Context, k KMS, keyID string, plaintext, associatedData []byte) ([]byte, error) {
+	return EncryptWith(ctx, k, keyID, defaultDataAlg, plaintext, associatedData)
+}
+
+// EncryptWith encrypts the plaintext with a new data key of the algorithm and
+// stores it wrapped by the master key identified by keyID
+func EncryptWith(ctx context.Context, k KMS, keyID string, alg aead.Algorithm, plaintext, associatedData []byte) ([]byte, error) {
+	const op = "kms.EncryptWith"
+
+	if k == nil {
+		return nil, ez.New(op, ez.EINVALID, "KMS can not be nil", nil)
+	} else if keyID == "" || len(keyID) > maxKeyID {
+		return nil, ez.New(op, ez.EINVALID, "KeyID must be between 1 and 255 bytes long", nil)
+	}
+
+	dataKey, err := aead.NewKey(alg)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer dataKey.Destroy()
+
+	wrapped, err := k.WrapKey(ctx, keyID, dataKey.Value)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if len(wrapped) > maxWrappedKey {
+		return nil, ez.New(op, ez.EINTERNAL, "Wrapped data key is too long", nil)
+	}
+
+	c, err := aead.NewFromKey(dataKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	env, err := aead.SealEnvelope(c, dataKeyID, plaintext, associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	ct := &Ciphertext{KeyID: keyID, WrappedKey: wrapped, Envelope: env}
+
+	return ct.Bytes(), nil
+}
+
+// Decrypt unwraps the data key of a ciphertext created by Encrypt and decrypts
+// the payload
+func Decrypt(ctx context.Context, k KMS, ciphertext, associatedData []byte) ([]byte, error) {
+	const op = "kms.Decrypt"
+
+	if k == nil {
+		return nil, ez.New(op, ez.EINVALID, "KMS can not be nil", nil)
+	}
+
+	ct, err := Parse(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := k.UnwrapKey(ctx, ct.KeyID, ct.WrappedKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer keys.Wipe(dataKey)
+
+	plaintext, err := ct.Envelope.Open(dataKey, associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return plaintext, nil
+}
+
+// Rewrap wraps the data key of a ciphertext with another master key without
+// decrypting the payload
+func Rewrap(ctx context.Context, k KMS, ciphertext []byte, keyID string) ([]byte, error) {
+	const op = "kms.Rewrap"
+
+	if k == nil {
+		return nil, ez.New(op, ez.EINVALID, "KMS can not be nil", nil)
+	} else if keyID == "" || len(keyID) > maxKeyID {
+		return nil, ez.New(op, ez.EINVALID, "KeyID must be between 1 and 255 bytes long", nil)
+	}
+
+	ct, err := Parse(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := k.UnwrapKey(ctx, ct.KeyID, ct.WrappedKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer keys.Wipe(dataKey)
+
+	wrapped, err := k.WrapKey(ctx, keyID, dataKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if len(wrapped) > maxWrappedKey {
+		return nil, ez.New(op, ez.EINTERNAL, "Wrapped data key is too long", nil)
+	}
+
+	ct.KeyID = keyID
+	ct.WrappedKey = wrapped
+
+	return ct.Bytes(), nil
+}
+
+// Parse decodes a ciphertext created by Encrypt
+func Parse(b []byte) (*Ciphertext, error) {
+	if len(b) < fixedSize || b[0] != version {
+		return nil, ErrMalformed
+	}
+
+	kidLen := int(b[1])
+	b = b[2:]
+	if kidLen == 0 || len(b) < kidLen+2 {
+		return nil, ErrMalformed
+	}
+
+	ct := &Ciphertext{KeyID: string(b[:kidLen])}
+	b = b[kidLen:]
+
+	wrappedLen := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < wrappedLen {
+		return nil, ErrMalformed
+	}
+	ct.WrappedKey = append([]byte{}, b[:wrappedLen]...)
+
+	env, err := aead.ParseEnvelope(b[wrappedLen:])
+	if err != nil || env.KeyID != dataKeyID {
+		return nil, ErrMalformed
+	}
+	ct.Envelope = env
+
+	return ct, nil
+}
+
+// Bytes returns the binary encoding of the ciphertext
+func (ct *Ciphertext) Bytes() []byte {
+	return append(ct.header(), ct.Envelope.Bytes()...)
+}
+
+func (ct *Ciphertext) header() []byte {
+	var wrappedLen [2]byte
+	binary.BigEndian.PutUint16(wrappedLen[:], uint16(len(ct.WrappedKey)))
+
+	h := make([]byte, 0, fixedSize+len(ct.KeyID)+len(ct.WrappedKey))
+	h = append(h, version, byte(len(ct.KeyID)))
+	h = append(h, ct.KeyID...)
+	h = append(h, wrappedLen[:]...)
+	h = append(h, ct.WrappedKey...)
+
+	return h
+}
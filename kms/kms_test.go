@@ -0,0 +1,101 @@
+package kms
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/aead"
+)
+
+func newLocalKMS(t *testing.T, ids ...string) *LocalKMS {
+	l, err := OpenLocalKMS(filepath.Join(t.TempDir(), "keys.json"), "correct horse battery staple")
+	assert.Nil(t, err)
+
+	for _, id := range ids {
+		assert.Nil(t, l.CreateKey(id))
+	}
+
+	return l
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	l := newLocalKMS(t, "master-1", "master-2")
+	ad := []byte("invoice:7")
+
+	// Case 1: Should decrypt with the same KMS
+	ciphertext, err := Encrypt(ctx, l, "master-1", []byte("payload"), ad)
+	assert.Nil(t, err)
+
+	ct, err := Parse(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, "master-1", ct.KeyID)
+	assert.Equal(t, aead.XChaCha20Poly1305, ct.Envelope.Algorithm)
+
+	plaintext, err := Decrypt(ctx, l, ciphertext, ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("payload"), plaintext)
+
+	// Case 2: Should encrypt with another data key algorithm
+	ciphertext, err = EncryptWith(ctx, l, "master-2", aead.AES256GCM, []byte("payload"), ad)
+	assert.Nil(t, err)
+	plaintext, err = Decrypt(ctx, l, ciphertext, ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("payload"), plaintext)
+
+	// Case 3: Should fail with other associated data
+	_, err = Decrypt(ctx, l, ciphertext, []byte("invoice:8"))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with an unknown master key
+	_, err = Encrypt(ctx, l, "master-3", []byte("payload"), nil)
+	assert.Equal(t, ez.ENOTFOUND, ez.ErrorCode(err))
+
+	// Case 5: Should fail when the wrapped key is moved to another master key
+	ct, _ = Parse(ciphertext)
+	ct.KeyID = "master-1"
+	_, err = Decrypt(ctx, l, ct.Bytes(), ad)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should fail with a data key from another ciphertext
+	other, _ := Encrypt(ctx, l, "master-2", []byte("other"), ad)
+	otherCt, _ := Parse(other)
+	ct, _ = Parse(ciphertext)
+	ct.WrappedKey = otherCt.WrappedKey
+	_, err = Decrypt(ctx, l, ct.Bytes(), ad)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 7: Should fail with malformed ciphertexts
+	for _, b := range [][]byte{nil, {1}, {2, 1, 'a', 0, 0}, ciphertext[:len(ciphertext)/2]} {
+		_, err = Decrypt(ctx, l, b, ad)
+		assert.Equal(t, ErrMalformed, err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	l := newLocalKMS(t, "old", "new")
+	ciphertext, _ := Encrypt(ctx, l, "old", []byte("payload"), nil)
+
+	// Case 1: Should move the data key to another master key and keep the payload
+	rewrapped, err := Rewrap(ctx, l, ciphertext, "new")
+	assert.Nil(t, err)
+
+	before, _ := Parse(ciphertext)
+	after, _ := Parse(rewrapped)
+	assert.Equal(t, "new", after.KeyID)
+	assert.Equal(t, before.Envelope, after.Envelope)
+
+	plaintext, err := Decrypt(ctx, l, rewrapped, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("payload"), plaintext)
+
+	// Case 2: Should fail with an unknown master key
+	_, err = Rewrap(ctx, l, ciphertext, "missing")
+	assert.Equal(t, ez.ENOTFOUND, ez.ErrorCode(err))
+}
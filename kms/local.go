@@ -0,0 +1,286 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/aead"
+	"github.com/vanclief/go-crypto/argon2"
+	"github.com/vanclief/go-crypto/keys"
+	"github.com/vanclief/go-crypto/utils"
+)
+
+// The master keys of a LocalKMS are stored in a JSON file, each one encrypted
+// with XChaCha20-Poly1305 under a key derived from the passphrase with argon2
+// and bound to its ID. Data keys are wrapped the same way with the master key.
+// The file also seals a known value so the passphrase is checked even when it
+// has no keys
+const (
+	localVersion   = 1
+	localSaltSize  = 32
+	localAlgorithm = aead.XChaCha20Poly1305
+	localVerifier  = "kms.LocalKMS"
+	localVerifyAD  = "verify"
+)
+
+// LocalKMS is a KMS that keeps its master keys in a local file sealed with a
+// passphrase. It is safe for concurrent use
+type LocalKMS struct {
+	mu   sync.RWMutex
+	path string
+	salt string
+	kek  aead.AEAD
+	keys map[string]*keys.Key
+}
+
+type localFile struct {
+	Version  int               `json:"version"`
+	Salt     string            `json:"salt"`
+	Verifier []byte            `json:"verifier"`
+	Keys     map[string][]byte `json:"keys"`
+}
+
+// OpenLocalKMS opens the key file at path with a passphrase, creating an empty
+// one if it does not exist
+func OpenLocalKMS(path, passphrase string) (*LocalKMS, error) {
+	const op = "kms.OpenLocalKMS"
+
+	l := &LocalKMS{path: path, keys: make(map[string]*keys.Key)}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		l.salt, err = utils.GenerateRandomString(localSaltSize)
+		if err != nil {
+			return nil, ez.New(op, ez.EINTERNAL, "Error while generating the salt", err)
+		}
+
+		err = l.setPassphrase(passphrase)
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+
+		err = l.save()
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+
+		return l, nil
+	} else if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while reading the key file", err)
+	}
+
+	var f localFile
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Key file is malformed", err)
+	} else if f.Version != localVersion {
+		return nil, ez.New(op, ez.EINVALID, "Key file has an unsupported version", nil)
+	} else if len(f.Verifier) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Key file is missing the passphrase verifier", nil)
+	}
+
+	l.salt = f.Salt
+	err = l.setPassphrase(passphrase)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	verifier, err := l.kek.Decrypt(f.Verifier, []byte(localVerifyAD))
+	if err != nil || string(verifier) != localVerifier {
+		l.Close()
+		return nil, ez.New(op, ez.ENOTAUTHORIZED, "Could not open key file, invalid file or passphrase", nil)
+	}
+
+	for id, sealed := range f.Keys {
+		value, err := l.kek.Decrypt(sealed, []byte(id))
+		if err != nil {
+			l.Close()
+			return nil, ez.New(op, ez.ENOTAUTHORIZED, "Could not open key file, invalid file or passphrase", nil)
+		}
+		l.keys[id] = keys.New(value, keys.Type(localAlgorithm))
+	}
+
+	return l, nil
+}
+
+// CreateKey generates a master key with an ID and saves it to the key file
+func (l *LocalKMS) CreateKey(id string) error {
+	const op = "kms.LocalKMS.CreateKey"
+
+	if id == "" || len(id) > maxKeyID {
+		return ez.New(op, ez.EINVALID, "KeyID must be between 1 and 255 bytes long", nil)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.kek == nil {
+		return ez.New(op, ez.EINVALID, "LocalKMS is closed", nil)
+	} else if _, ok := l.keys[id]; ok {
+		return ez.New(op, ez.EINVALID, "Master key "+id+" already exists", nil)
+	}
+
+	key, err := aead.NewKey(localAlgorithm)
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	l.keys[id] = key
+	err = l.save()
+	if err != nil {
+		delete(l.keys, id)
+		key.Destroy()
+		return ez.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// KeyIDs returns the sorted IDs of the master keys
+func (l *LocalKMS) KeyIDs() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ids := make([]string, 0, len(l.keys))
+	for id := range l.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// WrapKey encrypts a data key with a master key
+func (l *LocalKMS) WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	const op = "kms.LocalKMS.WrapKey"
+
+	c, err := l.master(op, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := c.Encrypt(dataKey, []byte(keyID))
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return wrapped, nil
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey with the same master key
+func (l *LocalKMS) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	const op = "kms.LocalKMS.UnwrapKey"
+
+	c, err := l.master(op, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := c.Decrypt(wrapped, []byte(keyID))
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not unwrap data key, invalid key or master key", nil)
+	}
+
+	return dataKey, nil
+}
+
+// Close zeroes the master keys held in memory. The key file is not modified
+func (l *LocalKMS) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, key := range l.keys {
+		key.Destroy()
+		delete(l.keys, id)
+	}
+	l.kek = nil
+}
+
+func (l *LocalKMS) master(op, keyID string) (aead.AEAD, error) {
+	// The cipher is created while holding the lock because Close zeroes the keys
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	key, ok := l.keys[keyID]
+	if l.kek == nil {
+		return nil, ez.New(op, ez.EINVALID, "LocalKMS is closed", nil)
+	} else if !ok {
+		return nil, ez.New(op, ez.ENOTFOUND, "Master key "+keyID+" does not exist", nil)
+	}
+
+	c, err := aead.NewFromKey(key)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return c, nil
+}
+
+func (l *LocalKMS) setPassphrase(passphrase string) error {
+	const op = "kms.LocalKMS.setPassphrase"
+
+	key, err := argon2.KDF(passphrase, l.salt)
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+	defer key.Destroy()
+
+	l.kek, err = aead.New(localAlgorithm, key.Value)
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// save writes the key file to a temporary file that replaces it, so a failed
+// write never leaves a truncated key file
+func (l *LocalKMS) save() error {
+	const op = "kms.LocalKMS.save"
+
+	verifier, err := l.kek.Encrypt([]byte(localVerifier), []byte(localVerifyAD))
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	f := localFile{Version: localVersion, Salt: l.salt, Verifier: verifier, Keys: make(map[string][]byte)}
+	for id, key := range l.keys {
+		sealed, err := l.kek.Encrypt(key.Value, []byte(id))
+		if err != nil {
+			return ez.Wrap(op, err)
+		}
+		f.Keys[id] = sealed
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while encoding the key file", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while creating the key file", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.path)
+	}
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while writing the key file", err)
+	}
+
+	return nil
+}
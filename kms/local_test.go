@@ -0,0 +1,102 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func TestLocalKMS(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	passphrase := "correct horse battery staple"
+
+	l, err := OpenLocalKMS(path, passphrase)
+	assert.Nil(t, err)
+	assert.Nil(t, l.CreateKey("master"))
+	ciphertext, err := Encrypt(ctx, l, "master", []byte("payload"), nil)
+	assert.Nil(t, err)
+
+	// Case 1: Should not store the master keys in the clear
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), base64.StdEncoding.EncodeToString(l.keys["master"].Value))
+
+	// Case 2: Should reopen the key file with the passphrase
+	l.Close()
+	l, err = OpenLocalKMS(path, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"master"}, l.KeyIDs())
+
+	plaintext, err := Decrypt(ctx, l, ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("payload"), plaintext)
+
+	// Case 3: Should fail with another passphrase
+	_, err = OpenLocalKMS(path, "wrong passphrase")
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	// Case 4: Should not create a key twice
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(l.CreateKey("master")))
+
+	// Case 5: Should fail once closed
+	l.Close()
+	_, err = l.WrapKey(ctx, "master", make([]byte, 32))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should fail with a malformed key file
+	assert.Nil(t, os.WriteFile(path, []byte("{"), 0600))
+	_, err = OpenLocalKMS(path, passphrase)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 7: Should fail with another passphrase when the key file has no keys
+	path = filepath.Join(t.TempDir(), "empty.json")
+	l, err = OpenLocalKMS(path, passphrase)
+	assert.Nil(t, err)
+	l.Close()
+
+	_, err = OpenLocalKMS(path, "wrong passphrase")
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	l, err = OpenLocalKMS(path, passphrase)
+	assert.Nil(t, err)
+	assert.Empty(t, l.KeyIDs())
+}
+
+func TestLocalKMSConcurrency(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	l, err := OpenLocalKMS(filepath.Join(t.TempDir(), "keys.json"), "correct horse battery staple")
+	assert.Nil(t, err)
+	assert.Nil(t, l.CreateKey("master"))
+	wrapped, err := l.WrapKey(ctx, "master", make([]byte, 32))
+	assert.Nil(t, err)
+
+	var wg, started sync.WaitGroup
+
+	// Case 1: Should not use a master key while it is destroyed
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				l.WrapKey(ctx, "master", make([]byte, 32))
+				l.UnwrapKey(ctx, "master", wrapped)
+				if j == 0 {
+					started.Done()
+				}
+			}
+		}()
+	}
+	started.Wait()
+	l.Close()
+	wg.Wait()
+}
@@ -0,0 +1,195 @@
+package nacl
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	libnacl "github.com/kevinburke/nacl"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+)
+
+// KeyFromHex returns a NaCl key from a 64 character hex string, with or
+// without a 0x prefix
+func KeyFromHex(s string) (*Key, error) {
+	const op = "NaCL.KeyFromHex"
+
+	b, err := decodeHexKey(op, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{keys.New(b, keys.C25519)}, nil
+}
+
+// KeyFromBase64 returns a NaCl key from a standard or URL-safe base64 string,
+// with or without padding
+func KeyFromBase64(s string) (*Key, error) {
+	const op = "NaCL.KeyFromBase64"
+
+	b, err := decodeBase64Key(op, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{keys.New(b, keys.C25519)}, nil
+}
+
+// KeyFromRaw returns a NaCl key from a copy of 32 raw bytes
+func KeyFromRaw(b []byte) (*Key, error) {
+	const op = "NaCL.KeyFromRaw"
+
+	if err := validateKey(op, b, "Key"); err != nil {
+		return nil, err
+	}
+
+	return &Key{keys.New(append([]byte{}, b...), keys.C25519)}, nil
+}
+
+// ParseKey returns a NaCl key from a hex or base64 string, detecting the
+// encoding from its length
+func ParseKey(s string) (*Key, error) {
+	const op = "NaCL.ParseKey"
+
+	b, err := decodeKey(op, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{keys.New(b, keys.C25519)}, nil
+}
+
+// ParsePublicKey returns a Curve25519 public key from a hex or base64 string
+func ParsePublicKey(s string) (*PublicKey, error) {
+	const op = "NaCL.ParsePublicKey"
+
+	b, err := decodeKey(op, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicKey{keys.New(b, keys.C25519)}, nil
+}
+
+// ParsePrivateKey returns a Curve25519 private key from a hex or base64 string
+func ParsePrivateKey(s string) (*PrivateKey, error) {
+	const op = "NaCL.ParsePrivateKey"
+
+	b, err := decodeKey(op, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrivateKey{keys.New(b, keys.C25519)}, nil
+}
+
+// Hex returns the key as a hex string with a 0x prefix
+func (k *Key) Hex() string {
+	return encodeHex(k.Value)
+}
+
+// Base64 returns the key as a padded standard base64 string
+func (k *Key) Base64() string {
+	return base64.StdEncoding.EncodeToString(k.Value)
+}
+
+// Base64URL returns the key as an unpadded URL-safe base64 string
+func (k *Key) Base64URL() string {
+	return base64.RawURLEncoding.EncodeToString(k.Value)
+}
+
+// Hex returns the public key as a hex string with a 0x prefix
+func (k *PublicKey) Hex() string {
+	return encodeHex(k.Value)
+}
+
+// Base64 returns the public key as a padded standard base64 string
+func (k *PublicKey) Base64() string {
+	return base64.StdEncoding.EncodeToString(k.Value)
+}
+
+// Base64URL returns the public key as an unpadded URL-safe base64 string
+func (k *PublicKey) Base64URL() string {
+	return base64.RawURLEncoding.EncodeToString(k.Value)
+}
+
+// MarshalJSON encodes the public key including its value, it is not secret
+func (k PublicKey) MarshalJSON() ([]byte, error) {
+	return k.Key.ExportJSON()
+}
+
+// Hex returns the private key as a hex string with a 0x prefix
+func (k *PrivateKey) Hex() string {
+	return encodeHex(k.Value)
+}
+
+// Base64 returns the private key as a padded standard base64 string
+func (k *PrivateKey) Base64() string {
+	return base64.StdEncoding.EncodeToString(k.Value)
+}
+
+// Base64URL returns the private key as an unpadded URL-safe base64 string
+func (k *PrivateKey) Base64URL() string {
+	return base64.RawURLEncoding.EncodeToString(k.Value)
+}
+
+func encodeHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// decodeKey decodes a 32 byte key from hex, which is 64 characters long
+// without its prefix, or from base64, which is 43 or 44 characters long. Base64
+// keys can also start with 0x, so only the length decides the encoding
+func decodeKey(op string, s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	hexLen := hex.EncodedLen(libnacl.KeySize)
+	prefixed := strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")
+
+	switch {
+	case len(s) == hexLen, prefixed && len(s) == hexLen+2:
+		return decodeHexKey(op, s)
+	case len(s) == base64.RawStdEncoding.EncodedLen(libnacl.KeySize), len(s) == base64.StdEncoding.EncodedLen(libnacl.KeySize):
+		return decodeBase64Key(op, s)
+	}
+
+	return nil, ez.New(op, ez.EINVALID, "A NaCl Key must be 32 bytes encoded in hex or base64", nil)
+}
+
+func decodeHexKey(op string, s string) ([]byte, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+
+	if len(s) != hex.EncodedLen(libnacl.KeySize) {
+		return nil, ez.New(op, ez.EINVALID, "A NaCl Key in hex must be 64 characters long", nil)
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Key is not valid hex", err)
+	}
+
+	return b, nil
+}
+
+func decodeBase64Key(op string, s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+
+	b, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Key is not valid base64", err)
+	}
+
+	if err := validateKey(op, b, "Key"); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -0,0 +1,116 @@
+package nacl
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+const (
+	testKeyHex    = "268fe78e064700fe6b98e47dc0758a4f966bd027299b685642c607ea376b7d47"
+	testKeyBase64 = "Jo/njgZHAP5rmOR9wHWKT5Zr0Ccpm2hWQsYH6jdrfUc="
+)
+
+func TestKeyFromHex(t *testing.T) {
+	// Case 1: Should work with and without the 0x prefix
+	for _, s := range []string{testKeyHex, "0x" + testKeyHex, "0X" + testKeyHex} {
+		key, err := KeyFromHex(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, "0x"+testKeyHex, key.Hex())
+	}
+
+	// Case 2: Should fail with invalid input instead of panicking
+	for _, s := range []string{"", "0", "0x", testKeyHex[2:], "e1" + testKeyHex, "zz" + testKeyHex[2:]} {
+		key, err := KeyFromHex(s)
+		assert.Nil(t, key, s)
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err), s)
+	}
+}
+
+func TestKeyFromBase64(t *testing.T) {
+	// Setup
+	expected, _ := KeyFromHex(testKeyHex)
+
+	// Case 1: Should work with standard and URL-safe base64
+	for _, s := range []string{testKeyBase64, "Jo/njgZHAP5rmOR9wHWKT5Zr0Ccpm2hWQsYH6jdrfUc", expected.Base64URL()} {
+		key, err := KeyFromBase64(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected.Value, key.Value, s)
+	}
+	assert.Equal(t, testKeyBase64, expected.Base64())
+	assert.Equal(t, "Jo_njgZHAP5rmOR9wHWKT5Zr0Ccpm2hWQsYH6jdrfUc", expected.Base64URL())
+
+	// Case 2: Should fail with invalid input
+	for _, s := range []string{"", "Jo/njgZHAP5rmOR9", "Jo/njgZHAP5rmOR9wHWKT5Zr0Ccpm2hWQsYH6jdrfUc!"} {
+		key, err := KeyFromBase64(s)
+		assert.Nil(t, key, s)
+		assert.Equal(t, ez.EINVALID, ez.ErrorCode(err), s)
+	}
+}
+
+func TestKeyFromRaw(t *testing.T) {
+	b := make([]byte, 32)
+	b[0] = 7
+
+	key, err := KeyFromRaw(b)
+	assert.Nil(t, err)
+	b[0] = 8
+	assert.Equal(t, byte(7), key.Value[0])
+
+	key, err = KeyFromRaw(b[:31])
+	assert.Nil(t, key)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestParseKey(t *testing.T) {
+	// Setup
+	keyPair, _ := NewKeyPair()
+	pub, _ := keyPair.Public()
+	priv, _ := keyPair.Private()
+
+	// Case 1: Should detect the encoding
+	for _, s := range []string{testKeyHex, "0x" + testKeyHex, testKeyBase64, " " + testKeyBase64 + "\n"} {
+		key, err := ParseKey(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, "0x"+testKeyHex, key.Hex(), s)
+	}
+
+	// Case 2: Should round trip public and private keys
+	parsedPub, err := ParsePublicKey(pub.Base64())
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PublicKey, parsedPub.Value)
+
+	parsedPriv, err := ParsePrivateKey(priv.Hex())
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PrivateKey, parsedPriv.Value)
+
+	parsedPriv, err = ParsePrivateKey(priv.Base64URL())
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PrivateKey, parsedPriv.Value)
+
+	// Case 3: Should decode base64 keys that start with 0x
+	b := append([]byte{0xd3, 0x1f}, make([]byte, 30)...)
+	for _, s := range []string{base64.StdEncoding.EncodeToString(b), base64.RawStdEncoding.EncodeToString(b)} {
+		assert.True(t, strings.HasPrefix(s, "0x"), s)
+
+		key, err := ParseKey(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, b, key.Value, s)
+
+		parsedPub, err := ParsePublicKey(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, b, parsedPub.Value, s)
+
+		parsedPriv, err := ParsePrivateKey(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, b, parsedPriv.Value, s)
+	}
+
+	// Case 4: Should fail with an unknown encoding
+	key, err := ParseKey("not a key")
+	assert.Nil(t, key)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
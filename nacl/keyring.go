@@ -0,0 +1,378 @@
+package nacl
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/aead"
+	"github.com/vanclief/go-crypto/keys"
+)
+
+// Ciphertexts of a Keyring are aead envelopes with XChaCha20-Poly1305 that
+// record the ID of the key that encrypted them
+const (
+	keyringVersion   = 1
+	keyringAlgorithm = aead.XChaCha20Poly1305
+)
+
+// Keyring represents a set of keys indexed by ID. The primary key encrypts,
+// every key decrypts. It is safe for concurrent use
+type Keyring struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]*Key
+}
+
+type sealedKeyring struct {
+	Version int             `json:"version"`
+	Primary string          `json:"primary"`
+	Keys    []sealedRingKey `json:"keys"`
+}
+
+type sealedRingKey struct {
+	ID  string `json:"id"`
+	Key []byte `json:"key"`
+}
+
+// NewKeyring returns a Keyring with a copy of the key as its primary key
+func NewKeyring(id string, key *Key) (*Keyring, error) {
+	const op = "NaCL.NewKeyring"
+
+	kr := &Keyring{keys: make(map[string]*Key)}
+	err := kr.Rotate(id, key)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return kr, nil
+}
+
+// Rotate adds a key and makes it the primary key. The previous primary key is
+// kept to decrypt existing ciphertexts. A key already added with Add is
+// promoted when its ID is given with a nil key or the same key
+func (kr *Keyring) Rotate(id string, key *Key) error {
+	const op = "NaCL.Keyring.Rotate"
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if existing, ok := kr.keys[id]; ok {
+		if key != nil && (key.Key == nil || subtle.ConstantTimeCompare(key.Value, existing.Value) != 1) {
+			return ez.New(op, ez.EINVALID, "Key "+id+" is already in the Keyring with another value", nil)
+		}
+		kr.primary = id
+		return nil
+	}
+
+	err := kr.add(op, id, key)
+	if err != nil {
+		return err
+	}
+	kr.primary = id
+
+	return nil
+}
+
+// Add adds a key that is only used to decrypt. The Keyring keeps a copy of
+// the key, so the caller can still use and destroy its own
+func (kr *Keyring) Add(id string, key *Key) error {
+	const op = "NaCL.Keyring.Add"
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	return kr.add(op, id, key)
+}
+
+// Remove removes a key that is not the primary key. Ciphertexts encrypted with
+// it can no longer be decrypted
+func (kr *Keyring) Remove(id string) error {
+	const op = "NaCL.Keyring.Remove"
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if id == kr.primary {
+		return ez.New(op, ez.EINVALID, "The primary key can not be removed", nil)
+	} else if _, ok := kr.keys[id]; !ok {
+		return ez.New(op, ez.ENOTFOUND, "Key "+id+" is not in the Keyring", nil)
+	}
+
+	delete(kr.keys, id)
+
+	return nil
+}
+
+// Primary returns the ID of the primary key
+func (kr *Keyring) Primary() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	return kr.primary
+}
+
+// IDs returns the sorted IDs of every key
+func (kr *Keyring) IDs() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	return kr.ids()
+}
+
+// Encrypt encrypts the plaintext with the primary key, binding it to the
+// associated data
+func (kr *Keyring) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
+	const op = "NaCL.Keyring.Encrypt"
+
+	// The cipher copies the key, so it is created under the lock in case the
+	// Keyring is destroyed concurrently
+	kr.mu.RLock()
+	id, key := kr.primary, kr.keys[kr.primary]
+	if key == nil {
+		kr.mu.RUnlock()
+		return nil, ez.New(op, ez.EINVALID, "Keyring was destroyed", nil)
+	}
+	c, err := aead.New(keyringAlgorithm, key.Value)
+	kr.mu.RUnlock()
+
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	e, err := aead.SealEnvelope(c, id, plaintext, associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return e.Bytes(), nil
+}
+
+// Decrypt decrypts a ciphertext created by Encrypt with the key that
+// encrypted it
+func (kr *Keyring) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
+	const op = "NaCL.Keyring.Decrypt"
+
+	e, err := aead.ParseEnvelope(ciphertext)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if e.Algorithm != keyringAlgorithm {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext was not encrypted by a Keyring", nil)
+	}
+
+	// The key is copied under the lock in case the Keyring is destroyed
+	// concurrently
+	kr.mu.RLock()
+	key, ok := kr.keys[e.KeyID]
+	var value []byte
+	if ok {
+		value = append([]byte{}, key.Value...)
+	}
+	kr.mu.RUnlock()
+
+	if !ok {
+		return nil, ez.New(op, ez.ENOTFOUND, "Ciphertext was encrypted with unknown key "+e.KeyID, nil)
+	}
+	defer keys.Wipe(value)
+
+	plaintext, err := e.Open(value, associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return plaintext, nil
+}
+
+// KeyID returns the ID of the key that encrypted a ciphertext
+func (kr *Keyring) KeyID(ciphertext []byte) (string, error) {
+	const op = "NaCL.Keyring.KeyID"
+
+	e, err := aead.ParseEnvelope(ciphertext)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return e.KeyID, nil
+}
+
+// IsStale returns true if a ciphertext was not encrypted with the primary key
+func (kr *Keyring) IsStale(ciphertext []byte) (bool, error) {
+	const op = "NaCL.Keyring.IsStale"
+
+	id, err := kr.KeyID(ciphertext)
+	if err != nil {
+		return false, ez.Wrap(op, err)
+	}
+
+	return id != kr.Primary(), nil
+}
+
+// Reencrypt decrypts a stale ciphertext and encrypts it with the primary key.
+// A ciphertext that is not stale is returned as is, the boolean reports if it
+// was rewrapped
+func (kr *Keyring) Reencrypt(ciphertext, associatedData []byte) ([]byte, bool, error) {
+	const op = "NaCL.Keyring.Reencrypt"
+
+	stale, err := kr.IsStale(ciphertext)
+	if err != nil {
+		return nil, false, ez.Wrap(op, err)
+	} else if !stale {
+		return ciphertext, false, nil
+	}
+
+	plaintext, err := kr.Decrypt(ciphertext, associatedData)
+	if err != nil {
+		return nil, false, ez.Wrap(op, err)
+	}
+	defer keys.Wipe(plaintext)
+
+	rewrapped, err := kr.Encrypt(plaintext, associatedData)
+	if err != nil {
+		return nil, false, ez.Wrap(op, err)
+	}
+
+	return rewrapped, true, nil
+}
+
+// Seal encodes the Keyring as JSON with every key encrypted with a 32 byte key
+// encryption key. Each key is bound to its ID, the primary key ID and the IDs of
+// every other key, so none of them can be changed or dropped. A whole document
+// sealed earlier with the same key encryption key still opens
+func (kr *Keyring) Seal(kek []byte) ([]byte, error) {
+	const op = "NaCL.Keyring.Seal"
+
+	c, err := aead.New(keyringAlgorithm, kek)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	ids := kr.ids()
+	sealed := sealedKeyring{Version: keyringVersion, Primary: kr.primary}
+	for _, id := range ids {
+		b, err := c.Encrypt(kr.keys[id].Value, keyringAD(sealed.Version, sealed.Primary, ids, id))
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		sealed.Keys = append(sealed.Keys, sealedRingKey{ID: id, Key: b})
+	}
+
+	b, err := json.Marshal(sealed)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while encoding the Keyring", err)
+	}
+
+	return b, nil
+}
+
+// OpenKeyring decodes a Keyring encoded by Seal with its key encryption key
+func OpenKeyring(b []byte, kek []byte) (*Keyring, error) {
+	const op = "NaCL.OpenKeyring"
+
+	c, err := aead.New(keyringAlgorithm, kek)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	var sealed sealedKeyring
+	err = json.Unmarshal(b, &sealed)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Keyring is malformed", err)
+	} else if sealed.Version != keyringVersion {
+		return nil, ez.New(op, ez.EINVALID, "Keyring has an unsupported version", nil)
+	}
+
+	ids := make([]string, len(sealed.Keys))
+	for i, k := range sealed.Keys {
+		ids[i] = k.ID
+	}
+
+	kr := &Keyring{keys: make(map[string]*Key)}
+	for _, k := range sealed.Keys {
+		value, err := c.Decrypt(k.Key, keyringAD(sealed.Version, sealed.Primary, ids, k.ID))
+		if err != nil {
+			kr.Destroy()
+			return nil, ez.New(op, ez.EINVALID, "Could not open Keyring, invalid keyring or credentials", nil)
+		}
+
+		err = kr.add(op, k.ID, &Key{keys.New(value, keys.C25519)})
+		keys.Wipe(value)
+		if err != nil {
+			kr.Destroy()
+			return nil, err
+		}
+	}
+
+	if _, ok := kr.keys[sealed.Primary]; !ok {
+		kr.Destroy()
+		return nil, ez.New(op, ez.EINVALID, "Keyring primary key is missing", nil)
+	}
+	kr.primary = sealed.Primary
+
+	return kr, nil
+}
+
+// Destroy zeroes every key of the Keyring, the keys given by the caller are
+// not modified
+func (kr *Keyring) Destroy() {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	for id, key := range kr.keys {
+		key.Destroy()
+		delete(kr.keys, id)
+	}
+	kr.primary = ""
+}
+
+// ids returns the sorted IDs of every key, the caller must hold the lock
+func (kr *Keyring) ids() []string {
+	ids := make([]string, 0, len(kr.keys))
+	for id := range kr.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func (kr *Keyring) add(op, id string, key *Key) error {
+	if id == "" || len(id) > 255 {
+		return ez.New(op, ez.EINVALID, "Key ID must be between 1 and 255 bytes long", nil)
+	} else if key == nil || key.Key == nil {
+		return ez.New(op, ez.EINVALID, "Key can not be nil", nil)
+	} else if err := validateKey(op, key.Value, "Key"); err != nil {
+		return err
+	} else if _, ok := kr.keys[id]; ok {
+		return ez.New(op, ez.EINVALID, "Key "+id+" is already in the Keyring", nil)
+	}
+
+	// The Keyring destroys its keys, so it never keeps the key of the caller
+	kr.keys[id] = &Key{keys.New(append([]byte{}, key.Value...), keys.C25519)}
+
+	return nil
+}
+
+// keyringAD is the associated data of a sealed key: the version, the primary
+// key ID, the IDs of every key in order and the ID of the key, each ID prefixed
+// with its length
+func keyringAD(version int, primary string, ids []string, id string) []byte {
+	ad := []byte{byte(version), byte(len(primary))}
+	ad = append(ad, primary...)
+
+	var count [4]byte
+	binary.BigEndian.PutUint32(count[:], uint32(len(ids)))
+	ad = append(ad, count[:]...)
+	for _, i := range ids {
+		ad = append(ad, byte(len(i)))
+		ad = append(ad, i...)
+	}
+
+	ad = append(ad, byte(len(id)))
+	return append(ad, id...)
+}
@@ -0,0 +1,212 @@
+package nacl
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func TestKeyring(t *testing.T) {
+	// Setup
+	kr, err := NewKeyring("2024-q1", NewKey())
+	assert.Nil(t, err)
+	ad := []byte("record:42")
+
+	old, err := kr.Encrypt([]byte("secret"), ad)
+	assert.Nil(t, err)
+
+	// Case 1: Should decrypt with the primary key
+	plaintext, err := kr.Decrypt(old, ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+
+	stale, err := kr.IsStale(old)
+	assert.Nil(t, err)
+	assert.False(t, stale)
+
+	// Case 2: Should decrypt old ciphertexts after a rotation and report them as stale
+	assert.Nil(t, kr.Rotate("2024-q2", NewKey()))
+	assert.Equal(t, "2024-q2", kr.Primary())
+	assert.Equal(t, []string{"2024-q1", "2024-q2"}, kr.IDs())
+
+	plaintext, err = kr.Decrypt(old, ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+
+	stale, err = kr.IsStale(old)
+	assert.Nil(t, err)
+	assert.True(t, stale)
+
+	// Case 3: Should reencrypt stale ciphertexts with the primary key
+	rewrapped, changed, err := kr.Reencrypt(old, ad)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+
+	id, err := kr.KeyID(rewrapped)
+	assert.Nil(t, err)
+	assert.Equal(t, "2024-q2", id)
+
+	same, changed, err := kr.Reencrypt(rewrapped, ad)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, rewrapped, same)
+
+	// Case 4: Should fail once the old key is removed
+	assert.Nil(t, kr.Remove("2024-q1"))
+	_, err = kr.Decrypt(old, ad)
+	assert.Equal(t, ez.ENOTFOUND, ez.ErrorCode(err))
+
+	plaintext, err = kr.Decrypt(rewrapped, ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+
+	// Case 5: Should fail with other associated data
+	_, err = kr.Decrypt(rewrapped, []byte("record:43"))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should not remove the primary key or add a duplicate ID
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(kr.Remove("2024-q2")))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(kr.Add("2024-q2", NewKey())))
+	assert.Equal(t, ez.ENOTFOUND, ez.ErrorCode(kr.Remove("2023-q4")))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(kr.Add("", NewKey())))
+}
+
+func TestKeyringRotate(t *testing.T) {
+	// Setup
+	key := NewKey()
+	staged := NewKey()
+	kr, err := NewKeyring("2024-q1", key)
+	assert.Nil(t, err)
+	ad := []byte("record:42")
+
+	// Case 1: Should promote a key that was added before
+	assert.Nil(t, kr.Add("2024-q2", staged))
+	assert.Equal(t, "2024-q1", kr.Primary())
+	assert.Nil(t, kr.Rotate("2024-q2", nil))
+	assert.Equal(t, "2024-q2", kr.Primary())
+	assert.Nil(t, kr.Rotate("2024-q1", key))
+	assert.Equal(t, "2024-q1", kr.Primary())
+
+	// Case 2: Should fail to promote an ID with another key
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(kr.Rotate("2024-q2", NewKey())))
+	assert.Equal(t, "2024-q1", kr.Primary())
+
+	// Case 3: Should fail to rotate to a new ID without a key
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(kr.Rotate("2024-q3", nil)))
+
+	// Case 4: Should not destroy the keys of the caller
+	ciphertext, err := kr.Encrypt([]byte("secret"), ad)
+	assert.Nil(t, err)
+	kr.Destroy()
+	assert.NotEqual(t, make([]byte, len(key.Value)), key.Value)
+	assert.NotEqual(t, make([]byte, len(staged.Value)), staged.Value)
+
+	other, err := NewKeyring("2024-q1", key)
+	assert.Nil(t, err)
+	plaintext, err := other.Decrypt(ciphertext, ad)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+}
+
+func TestKeyringSeal(t *testing.T) {
+	// Setup
+	kek := NewKey().Value
+	kr, _ := NewKeyring("a", NewKey())
+	assert.Nil(t, kr.Add("b", NewKey()))
+	ciphertext, _ := kr.Encrypt([]byte("secret"), nil)
+
+	// Case 1: Should reopen the sealed Keyring
+	sealed, err := kr.Seal(kek)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(sealed), kr.keys["a"].Base64())
+
+	opened, err := OpenKeyring(sealed, kek)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", opened.Primary())
+	assert.Equal(t, []string{"a", "b"}, opened.IDs())
+
+	plaintext, err := opened.Decrypt(ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+
+	// Case 2: Should fail with another key encryption key
+	_, err = OpenKeyring(sealed, NewKey().Value)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with malformed JSON
+	_, err = OpenKeyring([]byte("{"), kek)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail when a key is moved to another ID
+	swapped := bytes.Replace(sealed, []byte(`"id":"a"`), []byte(`"id":"c"`), 1)
+	_, err = OpenKeyring(swapped, kek)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail when the primary key is changed
+	rolledBack := bytes.Replace(sealed, []byte(`"primary":"a"`), []byte(`"primary":"b"`), 1)
+	assert.NotEqual(t, sealed, rolledBack)
+	_, err = OpenKeyring(rolledBack, kek)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should fail when a key is dropped
+	var doc sealedKeyring
+	assert.Nil(t, json.Unmarshal(sealed, &doc))
+	doc.Keys = doc.Keys[:1]
+	dropped, _ := json.Marshal(doc)
+	_, err = OpenKeyring(dropped, kek)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 7: Should fail to encrypt after being destroyed
+	opened.Destroy()
+	_, err = opened.Encrypt([]byte("secret"), nil)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestKeyringConcurrency(t *testing.T) {
+	// Setup
+	kr, _ := NewKeyring("0", NewKey())
+	var wg sync.WaitGroup
+
+	// Case 1: Should encrypt and decrypt while rotating
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c, err := kr.Encrypt([]byte("secret"), nil)
+				assert.Nil(t, err)
+				_, err = kr.Decrypt(c, nil)
+				assert.Nil(t, err)
+			}
+		}()
+	}
+	for i := 1; i < 10; i++ {
+		assert.Nil(t, kr.Rotate(string(rune('0'+i)), NewKey()))
+	}
+	wg.Wait()
+
+	// Case 2: Should not use a key while it is destroyed
+	ciphertext, _ := kr.Encrypt([]byte("secret"), nil)
+	var started sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				kr.Encrypt([]byte("secret"), nil)
+				kr.Decrypt(ciphertext, nil)
+				if j == 0 {
+					started.Done()
+				}
+			}
+		}()
+	}
+	started.Wait()
+	kr.Destroy()
+	wg.Wait()
+}
@@ -0,0 +1,366 @@
+package nacl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/aead"
+	"github.com/vanclief/go-crypto/keys"
+)
+
+// A multi-recipient ciphertext is the version byte, a flags byte, the number
+// of recipients as 2 big endian bytes and every recipient, followed by the
+// payload as an aead envelope with XChaCha20-Poly1305 under a random content
+// key. A recipient is the 8 byte key ID of its public key, omitted when the
+// recipients are hidden, and the content key sealed to it in an anonymous box.
+// The recipients are not bound to the payload, so they can be added or removed
+// without re-encrypting it
+const (
+	multiVersion      = 1
+	multiFlagHidden   = 1
+	multiHeaderSize   = 1 + 1 + 2
+	multiKeyIDSize    = 8
+	multiWrappedSize  = aead.KeySize + AnonymousOverhead
+	multiMaxRecipient = 0xffff
+	multiContentKeyID = "content"
+	multiAlgorithm    = aead.XChaCha20Poly1305
+)
+
+// ErrNotRecipient is returned when a KeyPair can not open any of the
+// recipients of a ciphertext
+var ErrNotRecipient = ez.New("NaCL.DecryptMulti", ez.ENOTFOUND, "KeyPair is not a recipient of the ciphertext", nil)
+
+type multiCiphertext struct {
+	hidden     bool
+	recipients []multiRecipient
+	payload    []byte
+}
+
+type multiRecipient struct {
+	keyID   []byte // nil when hidden
+	wrapped []byte
+}
+
+// EncryptMulti encrypts the plaintext once for several recipients. Each
+// recipient can be found by the key ID of its public key
+func EncryptMulti(plaintext, associatedData []byte, recipients ...*PublicKey) ([]byte, error) {
+	const op = "NaCL.EncryptMulti"
+
+	b, err := encryptMulti(plaintext, associatedData, recipients, false)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return b, nil
+}
+
+// EncryptMultiHidden encrypts the plaintext once for several recipients
+// without recording who they are. Decryption tries every recipient
+func EncryptMultiHidden(plaintext, associatedData []byte, recipients ...*PublicKey) ([]byte, error) {
+	const op = "NaCL.EncryptMultiHidden"
+
+	b, err := encryptMulti(plaintext, associatedData, recipients, true)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return b, nil
+}
+
+func encryptMulti(plaintext, associatedData []byte, recipients []*PublicKey, hidden bool) ([]byte, error) {
+	const op = "NaCL.encryptMulti"
+
+	contentKey, err := aead.NewKey(multiAlgorithm)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer keys.Wipe(contentKey.Value)
+
+	c, err := aead.New(multiAlgorithm, contentKey.Value)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	e, err := aead.SealEnvelope(c, multiContentKeyID, plaintext, associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	m := &multiCiphertext{hidden: hidden, payload: e.Bytes()}
+	err = m.addRecipients(op, contentKey.Value, recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.bytes(), nil
+}
+
+// DecryptMulti decrypts a ciphertext created by EncryptMulti or
+// EncryptMultiHidden with the KeyPair of one of its recipients
+func DecryptMulti(ciphertext, associatedData []byte, kp *KeyPair) ([]byte, error) {
+	const op = "NaCL.DecryptMulti"
+
+	m, err := parseMulti(op, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	contentKey, err := m.contentKey(kp)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Wipe(contentKey)
+
+	e, err := aead.ParseEnvelope(m.payload)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	plaintext, err := e.Open(contentKey, associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return plaintext, nil
+}
+
+// MultiRecipients returns the key IDs of the recipients of a ciphertext, in
+// the format of PublicKey.KeyID. A ciphertext with hidden recipients returns
+// none
+func MultiRecipients(ciphertext []byte) ([]string, error) {
+	const op = "NaCL.MultiRecipients"
+
+	m, err := parseMulti(op, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, r := range m.recipients {
+		if r.keyID != nil {
+			ids = append(ids, fmt.Sprintf("%X", r.keyID))
+		}
+	}
+
+	return ids, nil
+}
+
+// AddRecipients returns the ciphertext with the content key also sealed to the
+// new recipients. The KeyPair must be an existing recipient. The payload is
+// not re-encrypted
+func AddRecipients(ciphertext []byte, kp *KeyPair, recipients ...*PublicKey) ([]byte, error) {
+	const op = "NaCL.AddRecipients"
+
+	m, err := parseMulti(op, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	contentKey, err := m.contentKey(kp)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Wipe(contentKey)
+
+	err = m.addRecipients(op, contentKey, recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.bytes(), nil
+}
+
+// RemoveRecipients returns the ciphertext without the recipients. The payload
+// is not re-encrypted, so a removed recipient that kept the content key can
+// still decrypt it. Hidden recipients can only be replaced with SetRecipients
+func RemoveRecipients(ciphertext []byte, recipients ...*PublicKey) ([]byte, error) {
+	const op = "NaCL.RemoveRecipients"
+
+	m, err := parseMulti(op, ciphertext)
+	if err != nil {
+		return nil, err
+	} else if m.hidden {
+		return nil, ez.New(op, ez.EINVALID, "Hidden recipients can not be removed by public key", nil)
+	}
+
+	for _, pub := range recipients {
+		if pub == nil || pub.Key == nil {
+			return nil, ez.New(op, ez.EINVALID, "Recipient PublicKey can not be nil", nil)
+		}
+
+		i := m.find(multiKeyID(pub.Value))
+		if i < 0 {
+			return nil, ez.New(op, ez.ENOTFOUND, "PublicKey "+pub.KeyID()+" is not a recipient", nil)
+		}
+		m.recipients = append(m.recipients[:i], m.recipients[i+1:]...)
+	}
+
+	if len(m.recipients) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "At least one recipient must remain", nil)
+	}
+
+	return m.bytes(), nil
+}
+
+// SetRecipients returns the ciphertext with the content key sealed to exactly
+// the given recipients. The KeyPair must be an existing recipient, it is only
+// kept if it is in the list. The payload is not re-encrypted
+func SetRecipients(ciphertext []byte, kp *KeyPair, recipients ...*PublicKey) ([]byte, error) {
+	const op = "NaCL.SetRecipients"
+
+	m, err := parseMulti(op, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	contentKey, err := m.contentKey(kp)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Wipe(contentKey)
+
+	m.recipients = nil
+	err = m.addRecipients(op, contentKey, recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.bytes(), nil
+}
+
+func (m *multiCiphertext) addRecipients(op string, contentKey []byte, recipients []*PublicKey) error {
+	if len(recipients) == 0 {
+		return ez.New(op, ez.EINVALID, "At least one recipient is required", nil)
+	} else if len(m.recipients)+len(recipients) > multiMaxRecipient {
+		return ez.New(op, ez.EINVALID, "Ciphertext can not have more than 65535 recipients", nil)
+	}
+
+	for _, pub := range recipients {
+		if pub == nil || pub.Key == nil {
+			return ez.New(op, ez.EINVALID, "Recipient PublicKey can not be nil", nil)
+		}
+
+		r := multiRecipient{}
+		if !m.hidden {
+			r.keyID = multiKeyID(pub.Value)
+			if m.find(r.keyID) >= 0 {
+				return ez.New(op, ez.EINVALID, "PublicKey "+pub.KeyID()+" is already a recipient", nil)
+			}
+		}
+
+		wrapped, err := BoxSealAnonymous(contentKey, pub.Value)
+		if err != nil {
+			return ez.Wrap(op, err)
+		}
+		r.wrapped = wrapped
+
+		m.recipients = append(m.recipients, r)
+	}
+
+	return nil
+}
+
+// contentKey opens the content key with the recipient of the KeyPair, or with
+// each recipient in turn when they are hidden
+func (m *multiCiphertext) contentKey(kp *KeyPair) ([]byte, error) {
+	const op = "NaCL.DecryptMulti"
+
+	if kp == nil || kp.KeyPair == nil {
+		return nil, ez.New(op, ez.EINVALID, "KeyPair can not be nil", nil)
+	}
+
+	keyID := multiKeyID(kp.PublicKey)
+	for _, r := range m.recipients {
+		if r.keyID != nil && !bytes.Equal(r.keyID, keyID) {
+			continue
+		}
+
+		contentKey, err := BoxOpenAnonymous(r.wrapped, kp.PublicKey, kp.PrivateKey)
+		if err == nil {
+			return contentKey, nil
+		} else if r.keyID != nil {
+			return nil, ez.Wrap(op, err)
+		}
+	}
+
+	return nil, ErrNotRecipient
+}
+
+func (m *multiCiphertext) find(keyID []byte) int {
+	for i, r := range m.recipients {
+		if bytes.Equal(r.keyID, keyID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *multiCiphertext) bytes() []byte {
+	recipientSize := multiWrappedSize
+	if !m.hidden {
+		recipientSize += multiKeyIDSize
+	}
+
+	b := make([]byte, multiHeaderSize, multiHeaderSize+len(m.recipients)*recipientSize+len(m.payload))
+	b[0] = multiVersion
+	if m.hidden {
+		b[1] = multiFlagHidden
+	}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(m.recipients)))
+
+	for _, r := range m.recipients {
+		b = append(b, r.keyID...)
+		b = append(b, r.wrapped...)
+	}
+
+	return append(b, m.payload...)
+}
+
+func parseMulti(op string, b []byte) (*multiCiphertext, error) {
+	if len(b) < multiHeaderSize {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is too short", nil)
+	} else if b[0] != multiVersion {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext has an unsupported version", nil)
+	} else if b[1]&^multiFlagHidden != 0 {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext has unknown flags", nil)
+	}
+
+	m := &multiCiphertext{hidden: b[1]&multiFlagHidden != 0}
+	count := int(binary.BigEndian.Uint16(b[2:]))
+	b = b[multiHeaderSize:]
+
+	recipientSize := multiWrappedSize
+	if !m.hidden {
+		recipientSize += multiKeyIDSize
+	}
+	if count == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext has no recipients", nil)
+	} else if len(b) < count*recipientSize {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is too short", nil)
+	}
+
+	for i := 0; i < count; i++ {
+		r := multiRecipient{}
+		if !m.hidden {
+			r.keyID = append([]byte{}, b[:multiKeyIDSize]...)
+			b = b[multiKeyIDSize:]
+		}
+		r.wrapped = append([]byte{}, b[:multiWrappedSize]...)
+		b = b[multiWrappedSize:]
+
+		m.recipients = append(m.recipients, r)
+	}
+	m.payload = append([]byte{}, b...)
+
+	return m, nil
+}
+
+// multiKeyID is the first 8 bytes of the SHA-256 of the public key, the bytes
+// of PublicKey.KeyID
+func multiKeyID(publicKey []byte) []byte {
+	sum := sha256.Sum256(publicKey)
+	return sum[:multiKeyIDSize]
+}
@@ -0,0 +1,142 @@
+package nacl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func newMultiKeyPair(t *testing.T) (*KeyPair, *PublicKey) {
+	kp, err := NewKeyPair()
+	assert.Nil(t, err)
+	pub, err := kp.Public()
+	assert.Nil(t, err)
+	return kp, pub
+}
+
+func TestEncryptMulti(t *testing.T) {
+	// Setup
+	alice, alicePub := newMultiKeyPair(t)
+	bob, bobPub := newMultiKeyPair(t)
+	eve, _ := newMultiKeyPair(t)
+	ad := []byte("doc:7")
+
+	ciphertext, err := EncryptMulti([]byte("minutes"), ad, alicePub, bobPub)
+	assert.Nil(t, err)
+
+	// Case 1: Should decrypt with the KeyPair of any recipient
+	for _, kp := range []*KeyPair{alice, bob} {
+		plaintext, err := DecryptMulti(ciphertext, ad, kp)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("minutes"), plaintext)
+	}
+
+	// Case 2: Should list the recipients by key ID
+	ids, err := MultiRecipients(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{alicePub.KeyID(), bobPub.KeyID()}, ids)
+
+	// Case 3: Should fail for a KeyPair that is not a recipient
+	_, err = DecryptMulti(ciphertext, ad, eve)
+	assert.Equal(t, ErrNotRecipient, err)
+
+	// Case 4: Should fail with other associated data
+	_, err = DecryptMulti(ciphertext, []byte("doc:8"), alice)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail when the payload is modified
+	modified := append([]byte{}, ciphertext...)
+	modified[len(modified)-1] ^= 1
+	_, err = DecryptMulti(modified, ad, alice)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should reject invalid input
+	_, err = EncryptMulti([]byte("minutes"), ad)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = EncryptMulti([]byte("minutes"), ad, alicePub, alicePub)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = DecryptMulti(ciphertext[:10], ad, alice)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestEncryptMultiHidden(t *testing.T) {
+	// Setup
+	alice, alicePub := newMultiKeyPair(t)
+	bob, bobPub := newMultiKeyPair(t)
+	eve, evePub := newMultiKeyPair(t)
+
+	ciphertext, err := EncryptMultiHidden([]byte("minutes"), nil, alicePub, bobPub)
+	assert.Nil(t, err)
+
+	// Case 1: Should decrypt with the KeyPair of any recipient
+	for _, kp := range []*KeyPair{alice, bob} {
+		plaintext, err := DecryptMulti(ciphertext, nil, kp)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("minutes"), plaintext)
+	}
+
+	// Case 2: Should not reveal the recipients
+	ids, err := MultiRecipients(ciphertext)
+	assert.Nil(t, err)
+	assert.Empty(t, ids)
+	assert.NotContains(t, string(ciphertext), string(multiKeyID(alicePub.Value)))
+
+	// Case 3: Should fail for a KeyPair that is not a recipient
+	_, err = DecryptMulti(ciphertext, nil, eve)
+	assert.Equal(t, ErrNotRecipient, err)
+
+	// Case 4: Should replace the recipients without removing them by public key
+	_, err = RemoveRecipients(ciphertext, bobPub)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	replaced, err := SetRecipients(ciphertext, alice, alicePub, evePub)
+	assert.Nil(t, err)
+	_, err = DecryptMulti(replaced, nil, bob)
+	assert.Equal(t, ErrNotRecipient, err)
+	plaintext, err := DecryptMulti(replaced, nil, eve)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("minutes"), plaintext)
+}
+
+func TestMultiRecipientsChanges(t *testing.T) {
+	// Setup
+	alice, alicePub := newMultiKeyPair(t)
+	bob, bobPub := newMultiKeyPair(t)
+	carol, carolPub := newMultiKeyPair(t)
+
+	ciphertext, err := EncryptMulti([]byte("minutes"), nil, alicePub)
+	assert.Nil(t, err)
+	payload := ciphertext[multiHeaderSize+multiKeyIDSize+multiWrappedSize:]
+
+	// Case 1: Should add recipients without re-encrypting the payload
+	added, err := AddRecipients(ciphertext, alice, bobPub, carolPub)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, added[len(added)-len(payload):])
+
+	plaintext, err := DecryptMulti(added, nil, carol)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("minutes"), plaintext)
+
+	// Case 2: Should only let a recipient add others
+	_, err = AddRecipients(ciphertext, bob, bobPub)
+	assert.Equal(t, ErrNotRecipient, err)
+	_, err = AddRecipients(added, alice, bobPub)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should remove recipients without re-encrypting the payload
+	removed, err := RemoveRecipients(added, bobPub)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, removed[len(removed)-len(payload):])
+
+	ids, _ := MultiRecipients(removed)
+	assert.Equal(t, []string{alicePub.KeyID(), carolPub.KeyID()}, ids)
+	_, err = DecryptMulti(removed, nil, bob)
+	assert.Equal(t, ErrNotRecipient, err)
+
+	// Case 4: Should keep at least one recipient
+	_, err = RemoveRecipients(removed, alicePub, carolPub)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = RemoveRecipients(removed, bobPub)
+	assert.Equal(t, ez.ENOTFOUND, ez.ErrorCode(err))
+}
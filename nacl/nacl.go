@@ -9,6 +9,7 @@ import (
 	secretbox "github.com/kevinburke/nacl/secretbox"
 	"github.com/vanclief/ez"
 	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/curve25519"
 )
 
 // Key represents a NaCL Key
@@ -21,6 +22,16 @@ type KeyPair struct {
 	*keys.KeyPair
 }
 
+// PublicKey represents a Curve25519 public key
+type PublicKey struct {
+	*keys.Key
+}
+
+// PrivateKey represents a Curve25519 private key
+type PrivateKey struct {
+	*keys.Key
+}
+
 // NewKey returns a new NaCl Key with cryptographic random data. It will panic
 // if it can't read the correct amount of random data
 func NewKey() *Key {
@@ -30,28 +41,112 @@ func NewKey() *Key {
 	return &Key{keys.New(key, keys.C25519)}
 }
 
-// KeyFromHex returns a NaCl key from a 64 byte hex string
-func KeyFromHex(hex string) (*Key, error) {
-	const op = "Crypto.LoadNaclKey"
+// NewKeyPair returns a pair of NaCl keys (Public and Private key)
+func NewKeyPair() (*KeyPair, error) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+
+	pub := KeyToBytes(&publicKey)
+	priv := KeyToBytes(&privateKey)
+
+	return &KeyPair{keys.NewKeyPair(pub, priv, keys.C25519)}, err
+}
+
+// NewGuardedKeyPair returns a pair of NaCl keys whose private key is stored in
+// guarded memory. Call Destroy to release it
+func NewGuardedKeyPair() (*KeyPair, error) {
+	const op = "NaCL.NewGuardedKeyPair"
+
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating NaCl keypair", err)
+	}
 
-	naclKey, err := libnacl.Load(hex[2:])
+	kp, err := keys.NewGuardedKeyPair(KeyToBytes(&publicKey), KeyToBytes(&privateKey), keys.C25519)
 	if err != nil {
 		return nil, ez.Wrap(op, err)
 	}
 
-	key := KeyToBytes(&naclKey)
+	return &KeyPair{kp}, nil
+}
+
+// NewPublicKey returns a Curve25519 public key from 32 bytes
+func NewPublicKey(b []byte) (*PublicKey, error) {
+	const op = "NaCL.NewPublicKey"
+
+	err := validateKey(op, b, "PublicKey")
+	if err != nil {
+		return nil, err
+	}
 
-	return &Key{keys.New(key, keys.C25519)}, err
+	return &PublicKey{keys.New(b, keys.C25519)}, nil
 }
 
-// NewKeyPair returns a pair of NaCl keys (Public and Private key)
-func NewKeyPair() (*KeyPair, error) {
-	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+// NewPrivateKey returns a Curve25519 private key from 32 bytes
+func NewPrivateKey(b []byte) (*PrivateKey, error) {
+	const op = "NaCL.NewPrivateKey"
 
-	pub := KeyToBytes(&publicKey)
-	priv := KeyToBytes(&privateKey)
+	err := validateKey(op, b, "PrivateKey")
+	if err != nil {
+		return nil, err
+	}
 
-	return &KeyPair{keys.NewKeyPair(pub, priv, keys.C25519)}, err
+	return &PrivateKey{keys.New(b, keys.C25519)}, nil
+}
+
+// Public returns the public key of the KeyPair
+func (kp *KeyPair) Public() (*PublicKey, error) {
+	const op = "NaCL.KeyPair.Public"
+
+	pub, err := NewPublicKey(kp.PublicKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return pub, nil
+}
+
+// Private returns the private key of the KeyPair
+func (kp *KeyPair) Private() (*PrivateKey, error) {
+	const op = "NaCL.KeyPair.Private"
+
+	priv, err := NewPrivateKey(kp.PrivateKey)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return priv, nil
+}
+
+// Public derives the public key of the private key
+func (k *PrivateKey) Public() *PublicKey {
+	var pub, priv [libnacl.KeySize]byte
+	copy(priv[:], k.Value)
+	curve25519.ScalarBaseMult(&pub, &priv)
+	keys.Wipe(priv[:])
+
+	return &PublicKey{keys.New(pub[:], keys.C25519)}
+}
+
+// BoxSeal creates an encrypted box from a message for the peer public key
+func (k *PrivateKey) BoxSeal(message []byte, peer *PublicKey, nonce *libnacl.Nonce) ([]byte, error) {
+	const op = "NaCL.PrivateKey.BoxSeal"
+
+	if peer == nil {
+		return nil, ez.New(op, ez.EINVALID, "Peer PublicKey can not be nil", nil)
+	}
+
+	return BoxSeal(message, peer.Value, k.Value, nonce)
+}
+
+// BoxOpen decrypts a box sent by the peer public key
+func (k *PrivateKey) BoxOpen(b []byte, peer *PublicKey, nonce *libnacl.Nonce) ([]byte, error) {
+	const op = "NaCL.PrivateKey.BoxOpen"
+
+	if peer == nil {
+		return nil, ez.New(op, ez.EINVALID, "Peer PublicKey can not be nil", nil)
+	}
+
+	return BoxOpen(b, peer.Value, k.Value, nonce)
 }
 
 // KeyToBytes converts a Nacl Key to a byte array
@@ -69,6 +164,14 @@ func KeyFromBytes(b []byte) *libnacl.Key {
 	return &key
 }
 
+// WipeKey overwrites a NaCl Key with zeros. Use it on keys returned by
+// KeyFromBytes once they are no longer needed
+func WipeKey(key *libnacl.Key) {
+	if key != nil && *key != nil {
+		keys.Wipe((*key)[:])
+	}
+}
+
 // NewNonce returns a new NaCl Nonce with cryptographically random data. It will
 // panic if it can't read the correct amount of random data.
 func NewNonce() *libnacl.Nonce {
@@ -102,7 +205,14 @@ func SecretboxSeal(message []byte, key []byte, nonce *libnacl.Nonce) ([]byte, er
 		}
 	}()
 
+	if err := validateKey(op, key, "Key"); err != nil {
+		return nil, err
+	} else if nonce == nil {
+		return nil, ez.New(op, ez.EINVALID, "Nonce can not be nil", nil)
+	}
+
 	k := KeyFromBytes(key)
+	defer WipeKey(k)
 	box := secretbox.Seal(nil, message, *nonce, *k)
 
 	return box, err
@@ -119,7 +229,14 @@ func SecretboxOpen(box, key []byte, nonce *libnacl.Nonce) ([]byte, error) {
 		}
 	}()
 
+	if err := validateKey(op, key, "Key"); err != nil {
+		return nil, err
+	} else if nonce == nil {
+		return nil, ez.New(op, ez.EINVALID, "Nonce can not be nil", nil)
+	}
+
 	k := KeyFromBytes(key)
+	defer WipeKey(k)
 
 	msg, success := secretbox.Open(nil, box, *nonce, *k)
 	if !success {
@@ -140,8 +257,13 @@ func BoxSeal(message []byte, publicKey, privateKey []byte, nonce *libnacl.Nonce)
 		}
 	}()
 
+	if err := validateBoxKeys(op, publicKey, privateKey, nonce); err != nil {
+		return nil, err
+	}
+
 	pub := KeyFromBytes(publicKey)
 	priv := KeyFromBytes(privateKey)
+	defer WipeKey(priv)
 
 	box := box.Seal(nil, message, *nonce, *pub, *priv)
 
@@ -159,8 +281,13 @@ func BoxOpen(b []byte, publicKey, privateKey []byte, nonce *libnacl.Nonce) ([]by
 		}
 	}()
 
+	if err := validateBoxKeys(op, publicKey, privateKey, nonce); err != nil {
+		return nil, err
+	}
+
 	pub := KeyFromBytes(publicKey)
 	priv := KeyFromBytes(privateKey)
+	defer WipeKey(priv)
 
 	msg, success := box.Open(nil, b, *nonce, *pub, *priv)
 	if !success {
@@ -169,3 +296,23 @@ func BoxOpen(b []byte, publicKey, privateKey []byte, nonce *libnacl.Nonce) ([]by
 
 	return msg, err
 }
+
+func validateKey(op string, key []byte, name string) error {
+	if len(key) != libnacl.KeySize {
+		return ez.New(op, ez.EINVALID, "A NaCl "+name+" must be 32 bytes long", nil)
+	}
+
+	return nil
+}
+
+func validateBoxKeys(op string, publicKey, privateKey []byte, nonce *libnacl.Nonce) error {
+	if err := validateKey(op, publicKey, "PublicKey"); err != nil {
+		return err
+	} else if err := validateKey(op, privateKey, "PrivateKey"); err != nil {
+		return err
+	} else if nonce == nil {
+		return ez.New(op, ez.EINVALID, "Nonce can not be nil", nil)
+	}
+
+	return nil
+}
@@ -1,9 +1,11 @@
 package nacl
 
 import (
+	"encoding/json"
 	"testing"
 
 	libnacl "github.com/kevinburke/nacl"
+	"github.com/vanclief/ez"
 	"github.com/vanclief/go-crypto/argon2"
 	"github.com/vanclief/go-crypto/keys"
 	"github.com/vanclief/go-crypto/utils"
@@ -29,7 +31,7 @@ func TestNewKeypair(t *testing.T) {
 
 func TestLoadNaclKey(t *testing.T) {
 	// Case 1: Should work
-	key, err := KeyFromHex("e1268fe78e064700fe6b98e47dc0758a4f966bd027299b685642c607ea376b7d47")
+	key, err := KeyFromHex("0x268fe78e064700fe6b98e47dc0758a4f966bd027299b685642c607ea376b7d47")
 	assert.NotNil(t, key)
 	assert.Nil(t, err)
 
@@ -155,3 +157,119 @@ func TestBoxOpen(t *testing.T) {
 	assert.Nil(t, decrypted)
 	assert.NotNil(t, err)
 }
+
+func TestNewPublicKey(t *testing.T) {
+	keyPair, _ := NewKeyPair()
+
+	// Case 1: Should work
+	pub, err := NewPublicKey(keyPair.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, keys.C25519, pub.Type)
+
+	// Case 2: Should fail with the wrong length
+	pub, err = NewPublicKey(keyPair.PublicKey[:31])
+	assert.Nil(t, pub)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should encode its value as JSON
+	pub, _ = NewPublicKey(keyPair.PublicKey)
+	b, err := json.Marshal(pub)
+	assert.Nil(t, err)
+
+	decoded := &keys.Key{}
+	assert.Nil(t, json.Unmarshal(b, decoded))
+	assert.Equal(t, pub.Value, decoded.Value)
+}
+
+func TestNewPrivateKey(t *testing.T) {
+	keyPair, _ := NewKeyPair()
+
+	// Case 1: Should work and derive the PublicKey
+	priv, err := NewPrivateKey(keyPair.PrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PublicKey, priv.Public().Value)
+
+	// Case 2: Should fail with the wrong length
+	priv, err = NewPrivateKey(nil)
+	assert.Nil(t, priv)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestPrivateKeyBox(t *testing.T) {
+	// Setup
+	keyPair1, _ := NewKeyPair()
+	keyPair2, _ := NewKeyPair()
+	priv1, _ := keyPair1.Private()
+	priv2, _ := keyPair2.Private()
+	nonce := NewNonce()
+	msg := []byte("Secret message")
+
+	// Case 1: Should work
+	encrypted, err := priv2.BoxSeal(msg, priv1.Public(), nonce)
+	assert.Nil(t, err)
+
+	decrypted, err := priv1.BoxOpen(encrypted, priv2.Public(), nonce)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, decrypted)
+
+	// Case 2: Should be compatible with BoxOpen
+	decrypted, err = BoxOpen(encrypted, keyPair2.PublicKey, keyPair1.PrivateKey, nonce)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, decrypted)
+
+	// Case 3: Should fail without a peer
+	decrypted, err = priv1.BoxOpen(encrypted, nil, nonce)
+	assert.Nil(t, decrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestInvalidKeyLength(t *testing.T) {
+	keyPair, _ := NewKeyPair()
+	nonce := NewNonce()
+	msg := []byte("Secret message")
+
+	// Case 1: Secretbox should fail with a short key
+	encrypted, err := SecretboxSeal(msg, []byte("short"), nonce)
+	assert.Nil(t, encrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 2: Box should fail with a short public key
+	encrypted, err = BoxSeal(msg, keyPair.PublicKey[:16], keyPair.PrivateKey, nonce)
+	assert.Nil(t, encrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Box should fail without a nonce
+	encrypted, err = BoxSeal(msg, keyPair.PublicKey, keyPair.PrivateKey, nil)
+	assert.Nil(t, encrypted)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestNewGuardedKeyPair(t *testing.T) {
+	// Setup
+	alice, err := NewGuardedKeyPair()
+	assert.Nil(t, err)
+	bob, _ := NewKeyPair()
+	nonce := NewNonce()
+
+	// Case 1: Should box with the guarded key
+	b, err := BoxSeal([]byte("guarded"), bob.PublicKey, alice.PrivateKey, nonce)
+	assert.Nil(t, err)
+
+	msg, err := BoxOpen(b, alice.PublicKey, bob.PrivateKey, nonce)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("guarded"), msg)
+
+	// Case 2: Should not box after being destroyed
+	alice.Destroy()
+	b, err = BoxSeal([]byte("guarded"), bob.PublicKey, alice.PrivateKey, nonce)
+	assert.Nil(t, b)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestWipeKey(t *testing.T) {
+	k := KeyFromBytes([]byte("0123456789abcdef0123456789abcdef"))
+	WipeKey(k)
+	assert.Equal(t, make([]byte, 32), KeyToBytes(k))
+
+	WipeKey(nil)
+}
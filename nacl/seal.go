@@ -0,0 +1,160 @@
+package nacl
+
+import (
+	"io"
+
+	libnacl "github.com/kevinburke/nacl"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/utils"
+)
+
+// SecretboxEncrypt creates an encrypted secretbox from a message and a NaCl key
+// with a random nonce. The nonce is prefixed to the returned ciphertext
+func SecretboxEncrypt(message, key []byte) ([]byte, error) {
+	const op = "NaCL.SecretboxEncrypt"
+
+	nonce, err := randomNonce(op)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := SecretboxSeal(message, key, nonce)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return append(NonceToBytes(nonce), b...), nil
+}
+
+// SecretboxDecrypt decrypts a ciphertext created by SecretboxEncrypt
+func SecretboxDecrypt(ciphertext, key []byte) ([]byte, error) {
+	const op = "NaCL.SecretboxDecrypt"
+
+	nonce, b, err := splitNonce(op, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := SecretboxOpen(b, key, nonce)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return msg, nil
+}
+
+// SecretboxEncryptString encrypts a message like SecretboxEncrypt and returns
+// the ciphertext encoded in base64
+func SecretboxEncryptString(message string, key []byte) (string, error) {
+	const op = "NaCL.SecretboxEncryptString"
+
+	b, err := SecretboxEncrypt([]byte(message), key)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return utils.BytesToBase64(b), nil
+}
+
+// SecretboxDecryptString decrypts a base64 ciphertext created by
+// SecretboxEncryptString
+func SecretboxDecryptString(ciphertext string, key []byte) (string, error) {
+	const op = "NaCL.SecretboxDecryptString"
+
+	b, err := utils.Base64ToBytes(ciphertext)
+	if err != nil {
+		return "", ez.New(op, ez.EINVALID, "Ciphertext is not valid base64", err)
+	}
+
+	msg, err := SecretboxDecrypt(b, key)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return string(msg), nil
+}
+
+// BoxEncrypt creates an encrypted box from a message, a public key and a
+// private key with a random nonce. The nonce is prefixed to the returned
+// ciphertext
+func BoxEncrypt(message []byte, publicKey, privateKey []byte) ([]byte, error) {
+	const op = "NaCL.BoxEncrypt"
+
+	nonce, err := randomNonce(op)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := BoxSeal(message, publicKey, privateKey, nonce)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return append(NonceToBytes(nonce), b...), nil
+}
+
+// BoxDecrypt decrypts a ciphertext created by BoxEncrypt
+func BoxDecrypt(ciphertext []byte, publicKey, privateKey []byte) ([]byte, error) {
+	const op = "NaCL.BoxDecrypt"
+
+	nonce, b, err := splitNonce(op, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := BoxOpen(b, publicKey, privateKey, nonce)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return msg, nil
+}
+
+// BoxEncryptString encrypts a message like BoxEncrypt and returns the
+// ciphertext encoded in base64
+func BoxEncryptString(message string, publicKey, privateKey []byte) (string, error) {
+	const op = "NaCL.BoxEncryptString"
+
+	b, err := BoxEncrypt([]byte(message), publicKey, privateKey)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return utils.BytesToBase64(b), nil
+}
+
+// BoxDecryptString decrypts a base64 ciphertext created by BoxEncryptString
+func BoxDecryptString(ciphertext string, publicKey, privateKey []byte) (string, error) {
+	const op = "NaCL.BoxDecryptString"
+
+	b, err := utils.Base64ToBytes(ciphertext)
+	if err != nil {
+		return "", ez.New(op, ez.EINVALID, "Ciphertext is not valid base64", err)
+	}
+
+	msg, err := BoxDecrypt(b, publicKey, privateKey)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return string(msg), nil
+}
+
+func randomNonce(op string) (*libnacl.Nonce, error) {
+	var nonce libnacl.Nonce = new([libnacl.NonceSize]byte)
+
+	_, err := io.ReadFull(randReader, nonce[:])
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the nonce", err)
+	}
+
+	return &nonce, nil
+}
+
+func splitNonce(op string, ciphertext []byte) (*libnacl.Nonce, []byte, error) {
+	if len(ciphertext) < libnacl.NonceSize {
+		return nil, nil, ez.New(op, ez.EINVALID, "Ciphertext is too short to contain a nonce", nil)
+	}
+
+	return NonceFromBytes(ciphertext[:libnacl.NonceSize]), ciphertext[libnacl.NonceSize:], nil
+}
@@ -0,0 +1,83 @@
+package nacl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func TestSecretboxEncrypt(t *testing.T) {
+	// Setup
+	key := NewKey().Value
+
+	// Case 1: Should work
+	b, err := SecretboxEncrypt([]byte("column value"), key)
+	assert.Nil(t, err)
+	assert.Len(t, b, 24+16+len("column value"))
+
+	msg, err := SecretboxDecrypt(b, key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("column value"), msg)
+
+	// Case 2: Should use a different nonce every time
+	again, _ := SecretboxEncrypt([]byte("column value"), key)
+	assert.NotEqual(t, b[:24], again[:24])
+
+	// Case 3: Should fail with another key
+	msg, err = SecretboxDecrypt(b, NewKey().Value)
+	assert.Nil(t, msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with a ciphertext shorter than the nonce
+	msg, err = SecretboxDecrypt(b[:23], key)
+	assert.Nil(t, msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail with a key of the wrong length
+	b, err = SecretboxEncrypt([]byte("column value"), key[:31])
+	assert.Nil(t, b)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestSecretboxEncryptString(t *testing.T) {
+	key := NewKey().Value
+
+	s, err := SecretboxEncryptString("column value", key)
+	assert.Nil(t, err)
+
+	msg, err := SecretboxDecryptString(s, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "column value", msg)
+
+	_, err = SecretboxDecryptString("not base64!", key)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestBoxEncrypt(t *testing.T) {
+	// Setup
+	alice, _ := NewKeyPair()
+	bob, _ := NewKeyPair()
+
+	// Case 1: Should work
+	b, err := BoxEncrypt([]byte("hi bob"), bob.PublicKey, alice.PrivateKey)
+	assert.Nil(t, err)
+
+	msg, err := BoxDecrypt(b, alice.PublicKey, bob.PrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hi bob"), msg)
+
+	// Case 2: Should work with strings
+	s, err := BoxEncryptString("hi bob", bob.PublicKey, alice.PrivateKey)
+	assert.Nil(t, err)
+
+	str, err := BoxDecryptString(s, alice.PublicKey, bob.PrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "hi bob", str)
+
+	// Case 3: Should fail with a modified nonce
+	b[0] ^= 1
+	msg, err = BoxDecrypt(b, alice.PublicKey, bob.PrivateKey)
+	assert.Nil(t, msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
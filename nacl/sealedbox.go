@@ -0,0 +1,72 @@
+package nacl
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/vanclief/ez"
+	sealedbox "golang.org/x/crypto/nacl/box"
+)
+
+// AnonymousOverhead is the number of bytes an anonymous box adds to a message
+const AnonymousOverhead = sealedbox.AnonymousOverhead
+
+// randReader is the source of randomness used for ephemeral keys and stream
+// nonces
+var randReader io.Reader = rand.Reader
+
+// BoxSealAnonymous creates an encrypted box from a message for a public key
+// without a sender private key. It is compatible with crypto_box_seal from
+// libsodium: the box starts with an ephemeral public key and its nonce is the
+// BLAKE2b hash of the ephemeral and recipient public keys
+func BoxSealAnonymous(message, publicKey []byte) ([]byte, error) {
+	const op = "NaCL.BoxSealAnonymous"
+
+	if err := validateKey(op, publicKey, "PublicKey"); err != nil {
+		return nil, err
+	}
+
+	pub := KeyFromBytes(publicKey)
+
+	b, err := sealedbox.SealAnonymous(nil, message, *pub, randReader)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the ephemeral key", err)
+	}
+
+	return b, nil
+}
+
+// BoxOpenAnonymous decrypts a box created by BoxSealAnonymous or by
+// crypto_box_seal from libsodium using the recipient public and private keys
+func BoxOpenAnonymous(b, publicKey, privateKey []byte) ([]byte, error) {
+	const op = "NaCL.BoxOpenAnonymous"
+
+	if err := validateKey(op, publicKey, "PublicKey"); err != nil {
+		return nil, err
+	} else if err := validateKey(op, privateKey, "PrivateKey"); err != nil {
+		return nil, err
+	}
+
+	pub := KeyFromBytes(publicKey)
+	priv := KeyFromBytes(privateKey)
+	defer WipeKey(priv)
+
+	msg, success := sealedbox.OpenAnonymous(nil, b, *pub, *priv)
+	if !success {
+		return nil, ez.New(op, ez.EINVALID, "Could not open anonymous Box, invalid box or credentials", nil)
+	}
+
+	return msg, nil
+}
+
+// BoxSealAnonymous creates an encrypted box from a message for the public key
+// without a sender private key
+func (k *PublicKey) BoxSealAnonymous(message []byte) ([]byte, error) {
+	return BoxSealAnonymous(message, k.Value)
+}
+
+// BoxOpenAnonymous decrypts a box created for the public key of the KeyPair
+// by BoxSealAnonymous
+func (kp *KeyPair) BoxOpenAnonymous(b []byte) ([]byte, error) {
+	return BoxOpenAnonymous(b, kp.PublicKey, kp.PrivateKey)
+}
@@ -0,0 +1,78 @@
+package nacl
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"golang.org/x/crypto/curve25519"
+)
+
+// libsodiumKeyPair returns the key pair used to generate the libsodium test
+// data, a private key with all bytes set to 1
+func libsodiumKeyPair() (pub, priv []byte) {
+	priv = bytes.Repeat([]byte{1}, 32)
+	pub, _ = curve25519.X25519(priv, curve25519.Basepoint)
+	return pub, priv
+}
+
+func TestBoxSealAnonymous(t *testing.T) {
+	// Setup
+	pub, _ := libsodiumKeyPair()
+	message := bytes.Repeat([]byte{3}, 64)
+
+	// Case 1: Should match crypto_box_seal from libsodium with a random source
+	// that always returns 5
+	randReader = bytes.NewReader(bytes.Repeat([]byte{5}, 32))
+	b, err := BoxSealAnonymous(message, pub)
+	randReader = rand.Reader
+	assert.Nil(t, err)
+	assert.Equal(t, "50a61409b1ddd0325e9b16b700e719e9772c07000b1bd7786e907c653d20495d2af1697137a53b1b1dfc9befc49b6eeb38f86be720e155eb2be61976d2efb34d67ecd44a6ad634625eb9c288bfc883431a84ab0f5557dfe673aa6f74c19f033e648a947358cfcc606397fa1747d5219a", hex.EncodeToString(b))
+
+	// Case 2: Should work with a key pair
+	keyPair, _ := NewKeyPair()
+	recipient, _ := keyPair.Public()
+	b, err = recipient.BoxSealAnonymous([]byte("telemetry"))
+	assert.Nil(t, err)
+	assert.Len(t, b, len("telemetry")+AnonymousOverhead)
+
+	msg, err := keyPair.BoxOpenAnonymous(b)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("telemetry"), msg)
+
+	// Case 3: Should fail with a public key of the wrong length
+	b, err = BoxSealAnonymous(message, pub[:31])
+	assert.Nil(t, b)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestBoxOpenAnonymous(t *testing.T) {
+	// Setup
+	pub, priv := libsodiumKeyPair()
+	sealed, _ := hex.DecodeString("3462e0640728247a6f581e3812850d6edc3dcad1ea5d8184c072f62fb65cb357e27ffa8b76f41656bc66a0882c4d359568410665746d27462a700f01e314f382edd7aae9064879b0f8ba7b88866f88f5e4fbd7649c850541877f9f33ebd25d46d9cbcce09b69a9ba07f0eb1d105d4264")
+
+	// Case 1: Should open a box created by libsodium
+	msg, err := BoxOpenAnonymous(sealed, pub, priv)
+	assert.Nil(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{3}, 64), msg)
+
+	// Case 2: Should fail with a modified box
+	sealed[40] ^= 1
+	msg, err = BoxOpenAnonymous(sealed, pub, priv)
+	assert.Nil(t, msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with another key pair
+	other, _ := NewKeyPair()
+	msg, err = other.BoxOpenAnonymous(sealed)
+	assert.Nil(t, msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with a truncated box
+	msg, err = BoxOpenAnonymous(sealed[:AnonymousOverhead-1], pub, priv)
+	assert.Nil(t, msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
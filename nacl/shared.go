@@ -0,0 +1,115 @@
+package nacl
+
+import (
+	libnacl "github.com/kevinburke/nacl"
+	box "github.com/kevinburke/nacl/box"
+	"github.com/vanclief/ez"
+)
+
+// SharedKey represents a Box key precomputed from a private key and a peer
+// public key, so sealing and opening skip the X25519 scalar multiplication.
+// Boxes are compatible with BoxSeal and BoxOpen. It is safe for concurrent use
+type SharedKey struct {
+	key libnacl.Key
+}
+
+// NewSharedKey precomputes the shared key between a public key and a private key
+func NewSharedKey(publicKey, privateKey []byte) (*SharedKey, error) {
+	const op = "NaCL.NewSharedKey"
+
+	if err := validateKey(op, publicKey, "PublicKey"); err != nil {
+		return nil, err
+	} else if err := validateKey(op, privateKey, "PrivateKey"); err != nil {
+		return nil, err
+	}
+
+	pub := KeyFromBytes(publicKey)
+	priv := KeyFromBytes(privateKey)
+	defer WipeKey(priv)
+
+	return &SharedKey{key: box.Precompute(*pub, *priv)}, nil
+}
+
+// SharedKey precomputes the shared key between the private key and a peer
+// public key
+func (k *PrivateKey) SharedKey(peer *PublicKey) (*SharedKey, error) {
+	const op = "NaCL.PrivateKey.SharedKey"
+
+	if peer == nil {
+		return nil, ez.New(op, ez.EINVALID, "Peer PublicKey can not be nil", nil)
+	}
+
+	return NewSharedKey(peer.Value, k.Value)
+}
+
+// BoxSeal creates an encrypted box from a message and a NaCl nonce
+func (s *SharedKey) BoxSeal(message []byte, nonce *libnacl.Nonce) ([]byte, error) {
+	const op = "NaCL.SharedKey.BoxSeal"
+
+	if s.key == nil {
+		return nil, ez.New(op, ez.EINVALID, "SharedKey was destroyed", nil)
+	} else if nonce == nil {
+		return nil, ez.New(op, ez.EINVALID, "Nonce can not be nil", nil)
+	}
+
+	return box.SealAfterPrecomputation(nil, message, *nonce, s.key), nil
+}
+
+// BoxOpen decrypts a box using a NaCl nonce
+func (s *SharedKey) BoxOpen(b []byte, nonce *libnacl.Nonce) ([]byte, error) {
+	const op = "NaCL.SharedKey.BoxOpen"
+
+	if s.key == nil {
+		return nil, ez.New(op, ez.EINVALID, "SharedKey was destroyed", nil)
+	} else if nonce == nil {
+		return nil, ez.New(op, ez.EINVALID, "Nonce can not be nil", nil)
+	}
+
+	msg, success := box.OpenAfterPrecomputation(nil, b, *nonce, s.key)
+	if !success {
+		return nil, ez.New(op, ez.EINVALID, "Could not open Box, invalid box or credentials", nil)
+	}
+
+	return msg, nil
+}
+
+// BoxEncrypt creates an encrypted box from a message with a random nonce,
+// prefixed to the returned ciphertext like BoxEncrypt
+func (s *SharedKey) BoxEncrypt(message []byte) ([]byte, error) {
+	const op = "NaCL.SharedKey.BoxEncrypt"
+
+	nonce, err := randomNonce(op)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := s.BoxSeal(message, nonce)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return append(NonceToBytes(nonce), b...), nil
+}
+
+// BoxDecrypt decrypts a ciphertext created by BoxEncrypt
+func (s *SharedKey) BoxDecrypt(ciphertext []byte) ([]byte, error) {
+	const op = "NaCL.SharedKey.BoxDecrypt"
+
+	nonce, b, err := splitNonce(op, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.BoxOpen(b, nonce)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return msg, nil
+}
+
+// Destroy zeroes the shared key. It must not be called while the key is in use
+func (s *SharedKey) Destroy() {
+	WipeKey(&s.key)
+	s.key = nil
+}
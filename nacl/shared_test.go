@@ -0,0 +1,134 @@
+package nacl
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func TestSharedKey(t *testing.T) {
+	// Setup
+	alice, _ := NewKeyPair()
+	bob, _ := NewKeyPair()
+	nonce := NewNonce()
+
+	aliceShared, err := NewSharedKey(bob.PublicKey, alice.PrivateKey)
+	assert.Nil(t, err)
+
+	bobPriv, _ := bob.Private()
+	alicePub, _ := alice.Public()
+	bobShared, err := bobPriv.SharedKey(alicePub)
+	assert.Nil(t, err)
+
+	// Case 1: Should be compatible with BoxSeal and BoxOpen
+	b, err := aliceShared.BoxSeal([]byte("broker message"), nonce)
+	assert.Nil(t, err)
+
+	expected, _ := BoxSeal([]byte("broker message"), bob.PublicKey, alice.PrivateKey, nonce)
+	assert.Equal(t, expected, b)
+
+	msg, err := bobShared.BoxOpen(b, nonce)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("broker message"), msg)
+
+	// Case 2: Should work with the nonce-managing helpers
+	b, err = bobShared.BoxEncrypt([]byte("reply"))
+	assert.Nil(t, err)
+
+	msg, err = BoxDecrypt(b, bob.PublicKey, alice.PrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("reply"), msg)
+
+	// Case 3: Should fail with a modified box
+	b[len(b)-1] ^= 1
+	msg, err = aliceShared.BoxDecrypt(b)
+	assert.Nil(t, msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with keys of the wrong length
+	shared, err := NewSharedKey(bob.PublicKey[:31], alice.PrivateKey)
+	assert.Nil(t, shared)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail after being destroyed
+	aliceShared.Destroy()
+	b, err = aliceShared.BoxEncrypt([]byte("broker message"))
+	assert.Nil(t, b)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestSharedKeyConcurrency(t *testing.T) {
+	alice, _ := NewKeyPair()
+	bob, _ := NewKeyPair()
+	aliceShared, _ := NewSharedKey(bob.PublicKey, alice.PrivateKey)
+	bobShared, _ := NewSharedKey(alice.PublicKey, bob.PrivateKey)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b, err := aliceShared.BoxEncrypt([]byte("concurrent"))
+				assert.Nil(t, err)
+
+				msg, err := bobShared.BoxDecrypt(b)
+				assert.Nil(t, err)
+				assert.Equal(t, []byte("concurrent"), msg)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkBoxSeal(b *testing.B) {
+	alice, _ := NewKeyPair()
+	bob, _ := NewKeyPair()
+	nonce := NewNonce()
+	message := make([]byte, 256)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BoxSeal(message, bob.PublicKey, alice.PrivateKey, nonce)
+	}
+}
+
+func BenchmarkSharedKeyBoxSeal(b *testing.B) {
+	alice, _ := NewKeyPair()
+	bob, _ := NewKeyPair()
+	nonce := NewNonce()
+	message := make([]byte, 256)
+	shared, _ := NewSharedKey(bob.PublicKey, alice.PrivateKey)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		shared.BoxSeal(message, nonce)
+	}
+}
+
+func BenchmarkBoxOpen(b *testing.B) {
+	alice, _ := NewKeyPair()
+	bob, _ := NewKeyPair()
+	nonce := NewNonce()
+	sealed, _ := BoxSeal(make([]byte, 256), bob.PublicKey, alice.PrivateKey, nonce)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BoxOpen(sealed, alice.PublicKey, bob.PrivateKey, nonce)
+	}
+}
+
+func BenchmarkSharedKeyBoxOpen(b *testing.B) {
+	alice, _ := NewKeyPair()
+	bob, _ := NewKeyPair()
+	nonce := NewNonce()
+	sealed, _ := BoxSeal(make([]byte, 256), bob.PublicKey, alice.PrivateKey, nonce)
+	shared, _ := NewSharedKey(alice.PublicKey, bob.PrivateKey)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		shared.BoxOpen(sealed, nonce)
+	}
+}
@@ -0,0 +1,281 @@
+package nacl
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/internal/stream"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Streams follow the STREAM construction with XChaCha20-Poly1305. The
+// plaintext is split in chunks of StreamChunkSize bytes and the nonce of each
+// chunk is the random prefix of the header, a 32 bit big endian counter and a
+// flag byte that is 1 only for the final chunk, so reordered, dropped and
+// truncated chunks fail to decrypt
+const (
+	// StreamChunkSize is the size of the plaintext of each chunk
+	StreamChunkSize = stream.ChunkSize
+	// StreamHeaderSize is the size of the header written before the chunks
+	StreamHeaderSize = 1 + streamPrefixSize
+
+	streamVersion      byte = 1
+	streamPrefixSize        = chacha20poly1305.NonceSizeX - 5
+	streamEncChunkSize      = stream.EncChunkSize
+	streamTagSize           = stream.TagSize
+	streamMaxChunks         = math.MaxUint32
+)
+
+// StreamWriter encrypts everything written to it into the underlying writer.
+// Close must be called to write the final chunk
+type StreamWriter struct {
+	w *stream.Writer
+}
+
+// StreamReader decrypts a stream created by a StreamWriter
+type StreamReader struct {
+	r *stream.Reader
+}
+
+// StreamReaderAt decrypts any part of a stream created by a StreamWriter
+// without reading the chunks before it. Use io.NewSectionReader to seek
+type StreamReaderAt struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	header []byte
+	chunks int64
+	size   int64
+	encLen int64
+}
+
+// NewStreamWriter returns a StreamWriter that encrypts data with a 32 byte key
+// and writes the stream header to w
+func NewStreamWriter(w io.Writer, key []byte) (*StreamWriter, error) {
+	const op = "NaCL.NewStreamWriter"
+
+	aead, err := newStreamAEAD(op, key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, StreamHeaderSize)
+	header[0] = streamVersion
+	_, err = io.ReadFull(randReader, header[1:])
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the stream nonce", err)
+	}
+
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while writing the stream header", err)
+	}
+
+	return &StreamWriter{stream.NewWriter(w, streamConfig(aead, header))}, nil
+}
+
+// Write encrypts p. A chunk is only written once it is full and more data
+// follows, the last one is written by Close
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	const op = "NaCL.StreamWriter.Write"
+
+	n, err := s.w.Write(p)
+	if err != nil {
+		return n, ez.Wrap(op, err)
+	}
+
+	return n, nil
+}
+
+// Close writes the final chunk. It does not close the underlying writer
+func (s *StreamWriter) Close() error {
+	const op = "NaCL.StreamWriter.Close"
+
+	err := s.w.Close()
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// NewStreamReader returns a StreamReader that decrypts the stream read from r
+// with a 32 byte key
+func NewStreamReader(r io.Reader, key []byte) (*StreamReader, error) {
+	const op = "NaCL.NewStreamReader"
+
+	aead, err := newStreamAEAD(op, key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, StreamHeaderSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Stream header is truncated", err)
+	} else if header[0] != streamVersion {
+		return nil, ez.New(op, ez.EINVALID, "Stream has an unsupported version", nil)
+	}
+
+	return &StreamReader{stream.NewReader(r, streamConfig(aead, header))}, nil
+}
+
+// Read decrypts the stream. It returns an error if a chunk was modified,
+// reordered or removed, including the final one
+func (s *StreamReader) Read(p []byte) (int, error) {
+	const op = "NaCL.StreamReader.Read"
+
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		return n, ez.Wrap(op, err)
+	}
+
+	return n, err
+}
+
+// NewStreamReaderAt returns a StreamReaderAt for a stream of size bytes read
+// from r with a 32 byte key
+func NewStreamReaderAt(r io.ReaderAt, size int64, key []byte) (*StreamReaderAt, error) {
+	const op = "NaCL.NewStreamReaderAt"
+
+	aead, err := newStreamAEAD(op, key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, StreamHeaderSize)
+	_, err = r.ReadAt(header, 0)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Stream header is truncated", err)
+	} else if header[0] != streamVersion {
+		return nil, ez.New(op, ez.EINVALID, "Stream has an unsupported version", nil)
+	}
+
+	encLen := size - StreamHeaderSize
+	if encLen < streamTagSize {
+		return nil, ez.New(op, ez.EINVALID, "Stream is truncated", nil)
+	}
+
+	chunks := (encLen + streamEncChunkSize - 1) / streamEncChunkSize
+	lastLen := encLen - (chunks-1)*streamEncChunkSize
+	if lastLen < streamTagSize || (chunks > 1 && lastLen == streamTagSize) {
+		return nil, ez.New(op, ez.EINVALID, "Stream has an invalid size", nil)
+	} else if chunks-1 > streamMaxChunks {
+		return nil, ez.New(op, ez.EINVALID, "Stream is too long", nil)
+	}
+
+	s := &StreamReaderAt{
+		r:      r,
+		aead:   aead,
+		header: header,
+		chunks: chunks,
+		size:   encLen - chunks*streamTagSize,
+		encLen: encLen,
+	}
+
+	// The final chunk is authenticated up front so a truncated stream is
+	// rejected even if only earlier chunks are read
+	_, err = s.chunk(chunks - 1)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return s, nil
+}
+
+// Size returns the size of the decrypted stream
+func (s *StreamReaderAt) Size() int64 {
+	return s.size
+}
+
+// ReadAt decrypts len(p) bytes of the stream starting at off
+func (s *StreamReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	const op = "NaCL.StreamReaderAt.ReadAt"
+
+	if off < 0 {
+		return 0, ez.New(op, ez.EINVALID, "Offset can not be negative", nil)
+	} else if off >= s.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < s.size {
+		index := off / StreamChunkSize
+		plain, err := s.chunk(index)
+		if err != nil {
+			return n, ez.Wrap(op, err)
+		}
+
+		c := copy(p[n:], plain[off-index*StreamChunkSize:])
+		n += c
+		off += int64(c)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (s *StreamReaderAt) chunk(index int64) ([]byte, error) {
+	const op = "NaCL.StreamReaderAt.chunk"
+
+	start := index * streamEncChunkSize
+	end := start + streamEncChunkSize
+	if end > s.encLen {
+		end = s.encLen
+	}
+
+	buf := make([]byte, end-start)
+	_, err := s.r.ReadAt(buf, StreamHeaderSize+start)
+	if err != nil && err != io.EOF {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while reading a stream chunk", err)
+	}
+
+	last := index == s.chunks-1
+	plain, err := s.aead.Open(buf[:0], streamNonce(s.header, uint64(index), last), buf, s.header)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not open stream chunk, invalid stream or credentials", nil)
+	}
+
+	return plain, nil
+}
+
+func newStreamAEAD(op string, key []byte) (cipher.AEAD, error) {
+	if err := validateKey(op, key, "Key"); err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while creating the cipher", err)
+	}
+
+	return aead, nil
+}
+
+// streamConfig returns the chunking of a stream, every chunk authenticates the
+// header
+func streamConfig(aead cipher.AEAD, header []byte) stream.Config {
+	return stream.Config{
+		AEAD: aead,
+		Nonce: func(counter uint64, last bool) []byte {
+			return streamNonce(header, counter, last)
+		},
+		AdditionalData: header,
+		MaxCounter:     streamMaxChunks,
+	}
+}
+
+func streamNonce(header []byte, counter uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	copy(nonce, header[1:])
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], uint32(counter))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
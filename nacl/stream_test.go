@@ -0,0 +1,162 @@
+package nacl
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+)
+
+func encryptStream(t *testing.T, key, plaintext []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewStreamWriter(&buf, key)
+	assert.Nil(t, err)
+
+	// Write in uneven pieces to exercise the chunk buffering
+	for len(plaintext) > 0 {
+		n := 1000
+		if n > len(plaintext) {
+			n = len(plaintext)
+		}
+		_, err = w.Write(plaintext[:n])
+		assert.Nil(t, err)
+		plaintext = plaintext[n:]
+	}
+
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestStream(t *testing.T) {
+	// Setup
+	key := NewKey().Value
+
+	// Case 1: Should work with sizes around the chunk boundaries
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3 * StreamChunkSize} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		ciphertext := encryptStream(t, key, plaintext)
+		chunks := (size + StreamChunkSize - 1) / StreamChunkSize
+		if chunks == 0 {
+			chunks = 1
+		}
+		assert.Equal(t, StreamHeaderSize+size+chunks*16, len(ciphertext), size)
+
+		r, err := NewStreamReader(bytes.NewReader(ciphertext), key)
+		assert.Nil(t, err)
+		decrypted, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, plaintext, decrypted, size)
+	}
+
+	// Case 2: Should fail with another key
+	ciphertext := encryptStream(t, key, []byte("backup"))
+	r, _ := NewStreamReader(bytes.NewReader(ciphertext), NewKey().Value)
+	_, err := io.ReadAll(r)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with a key of the wrong length
+	_, err = NewStreamWriter(io.Discard, key[:16])
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestStreamTampering(t *testing.T) {
+	// Setup
+	key := NewKey().Value
+	plaintext := make([]byte, 3*StreamChunkSize+100)
+	rand.Read(plaintext)
+	ciphertext := encryptStream(t, key, plaintext)
+	encChunk := StreamChunkSize + 16
+
+	decrypt := func(b []byte) ([]byte, error) {
+		r, err := NewStreamReader(bytes.NewReader(b), key)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	// Case 1: Should fail when truncated at a chunk boundary
+	_, err := decrypt(ciphertext[:StreamHeaderSize+2*encChunk])
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 2: Should fail when the final chunk is cut
+	_, err = decrypt(ciphertext[:len(ciphertext)-1])
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail with reordered chunks
+	swapped := append([]byte{}, ciphertext...)
+	first := StreamHeaderSize
+	copy(swapped[first:], ciphertext[first+encChunk:first+2*encChunk])
+	copy(swapped[first+encChunk:], ciphertext[first:first+encChunk])
+	_, err = decrypt(swapped)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should fail with a modified chunk
+	modified := append([]byte{}, ciphertext...)
+	modified[StreamHeaderSize+encChunk+10] ^= 1
+	_, err = decrypt(modified)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail with trailing data
+	_, err = decrypt(append(append([]byte{}, ciphertext...), 0))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should fail with a truncated header
+	_, err = decrypt(ciphertext[:StreamHeaderSize-1])
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestStreamReaderAt(t *testing.T) {
+	// Setup
+	key := NewKey().Value
+	plaintext := make([]byte, 3*StreamChunkSize+100)
+	rand.Read(plaintext)
+	ciphertext := encryptStream(t, key, plaintext)
+
+	// Case 1: Should read any range
+	s, err := NewStreamReaderAt(bytes.NewReader(ciphertext), int64(len(ciphertext)), key)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(plaintext)), s.Size())
+
+	for _, r := range [][2]int{{0, 10}, {StreamChunkSize - 5, 10}, {2*StreamChunkSize + 7, StreamChunkSize + 50}, {len(plaintext) - 1, 1}} {
+		buf := make([]byte, r[1])
+		n, err := s.ReadAt(buf, int64(r[0]))
+		assert.Nil(t, err)
+		assert.Equal(t, r[1], n)
+		assert.Equal(t, plaintext[r[0]:r[0]+r[1]], buf)
+	}
+
+	// Case 2: Should return io.EOF when reading past the end
+	buf := make([]byte, 200)
+	n, err := s.ReadAt(buf, int64(len(plaintext)-100))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 100, n)
+
+	// Case 3: Should seek with a SectionReader
+	section := io.NewSectionReader(s, 0, s.Size())
+	_, err = section.Seek(int64(StreamChunkSize+3), io.SeekStart)
+	assert.Nil(t, err)
+	buf = make([]byte, 5)
+	_, err = io.ReadFull(section, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, plaintext[StreamChunkSize+3:StreamChunkSize+8], buf)
+
+	// Case 4: Should fail when truncated at a chunk boundary
+	truncated := ciphertext[:StreamHeaderSize+2*(StreamChunkSize+16)]
+	s, err = NewStreamReaderAt(bytes.NewReader(truncated), int64(len(truncated)), key)
+	assert.Nil(t, s)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail with a modified chunk
+	modified := append([]byte{}, ciphertext...)
+	modified[StreamHeaderSize+5] ^= 1
+	s, err = NewStreamReaderAt(bytes.NewReader(modified), int64(len(modified)), key)
+	assert.Nil(t, err)
+	_, err = s.ReadAt(make([]byte, 10), 0)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
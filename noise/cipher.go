@@ -0,0 +1,267 @@
+package noise
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// SHA256 is the Noise hash function SHA-256
+	SHA256 Hash = "SHA256"
+	// BLAKE2s is the Noise hash function BLAKE2s with a 32 byte output
+	BLAKE2s Hash = "BLAKE2s"
+
+	// MaxMessageSize is the largest Noise message, including the tag
+	MaxMessageSize = 65535
+	// TagSize is the size of the tag added to every encrypted payload
+	TagSize = 16
+
+	keySize  = chacha20poly1305.KeySize
+	hashSize = 32
+)
+
+// ErrNonceExhausted is returned when a CipherState has used every nonce and
+// must not encrypt or decrypt again
+var ErrNonceExhausted = ez.New("noise.CipherState", ez.EINVALID, "CipherState has used every nonce", nil)
+
+// Hash is the name of a Noise hash function
+type Hash string
+
+// CipherState encrypts or decrypts the transport messages of one direction
+// with ChaCha20-Poly1305 and an implicit counter nonce. It is not safe for
+// concurrent use
+type CipherState struct {
+	key   []byte
+	aead  cipher.AEAD
+	nonce uint64
+}
+
+// symmetricState is the chaining key and handshake hash shared by both sides
+// of a handshake
+type symmetricState struct {
+	hash Hash
+	cs   CipherState
+	ck   []byte
+	h    []byte
+}
+
+// Valid returns whether the hash function is supported
+func (h Hash) Valid() bool {
+	return h == SHA256 || h == BLAKE2s
+}
+
+func (h Hash) new() hash.Hash {
+	if h == BLAKE2s {
+		b, _ := blake2s.New256(nil)
+		return b
+	}
+	return sha256.New()
+}
+
+func (h Hash) sum(data ...[]byte) []byte {
+	d := h.new()
+	for _, b := range data {
+		d.Write(b)
+	}
+	return d.Sum(nil)
+}
+
+// hkdf is the Noise HKDF, it returns two or three outputs of hashSize bytes
+func (h Hash) hkdf(ck, ikm []byte, outputs int) [][]byte {
+	mac := hmac.New(h.new, ck)
+	mac.Write(ikm)
+	temp := mac.Sum(nil)
+	defer keys.Wipe(temp)
+
+	out := make([][]byte, 0, outputs)
+	prev := []byte{}
+	for i := 1; i <= outputs; i++ {
+		mac = hmac.New(h.new, temp)
+		mac.Write(prev)
+		mac.Write([]byte{byte(i)})
+		prev = mac.Sum(nil)
+		out = append(out, prev)
+	}
+
+	return out
+}
+
+func newCipherState(key []byte) *CipherState {
+	cs := &CipherState{}
+	cs.initialize(key)
+	return cs
+}
+
+func (cs *CipherState) initialize(key []byte) {
+	keys.Wipe(cs.key)
+	cs.key = append([]byte{}, key[:keySize]...)
+	cs.aead, _ = chacha20poly1305.New(cs.key)
+	cs.nonce = 0
+}
+
+func (cs *CipherState) hasKey() bool {
+	return cs.aead != nil
+}
+
+// Encrypt seals the plaintext with the next nonce
+func (cs *CipherState) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
+	const op = "noise.CipherState.Encrypt"
+
+	if !cs.hasKey() {
+		return nil, ez.New(op, ez.EINVALID, "CipherState has no key", nil)
+	} else if cs.nonce == math.MaxUint64 {
+		return nil, ErrNonceExhausted
+	} else if len(plaintext)+TagSize > MaxMessageSize {
+		return nil, ez.New(op, ez.EINVALID, "Plaintext is larger than a Noise message", nil)
+	}
+
+	ciphertext := cs.aead.Seal(nil, cs.nonceBytes(), plaintext, associatedData)
+	cs.nonce++
+
+	return ciphertext, nil
+}
+
+// Decrypt opens the ciphertext with the next nonce. The nonce only advances
+// when the ciphertext is authentic
+func (cs *CipherState) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
+	const op = "noise.CipherState.Decrypt"
+
+	if !cs.hasKey() {
+		return nil, ez.New(op, ez.EINVALID, "CipherState has no key", nil)
+	} else if cs.nonce == math.MaxUint64 {
+		return nil, ErrNonceExhausted
+	} else if len(ciphertext) > MaxMessageSize {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is larger than a Noise message", nil)
+	}
+
+	plaintext, err := cs.aead.Open(nil, cs.nonceBytes(), ciphertext, associatedData)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Could not authenticate the message", nil)
+	}
+	cs.nonce++
+
+	return plaintext, nil
+}
+
+// Rekey replaces the key with one derived from it, both sides must rekey
+// after the same message
+func (cs *CipherState) Rekey() error {
+	const op = "noise.CipherState.Rekey"
+
+	if !cs.hasKey() {
+		return ez.New(op, ez.EINVALID, "CipherState has no key", nil)
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	for i := 4; i < len(nonce); i++ {
+		nonce[i] = 0xff
+	}
+	key := cs.aead.Seal(nil, nonce, make([]byte, keySize), nil)
+	defer keys.Wipe(key)
+
+	// Rekey keeps the nonce
+	nonceValue := cs.nonce
+	cs.initialize(key)
+	cs.nonce = nonceValue
+
+	return nil
+}
+
+// Nonce returns the number of messages processed with the current key
+func (cs *CipherState) Nonce() uint64 {
+	return cs.nonce
+}
+
+// Destroy zeroes the key, the CipherState can not be used afterwards
+func (cs *CipherState) Destroy() {
+	keys.Wipe(cs.key)
+	cs.key = nil
+	cs.aead = nil
+}
+
+// nonceBytes encodes the counter as 4 zero bytes and 8 little endian bytes
+func (cs *CipherState) nonceBytes() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], cs.nonce)
+	return nonce
+}
+
+func newSymmetricState(protocolName string, h Hash) *symmetricState {
+	s := &symmetricState{hash: h}
+	if len(protocolName) <= hashSize {
+		s.h = make([]byte, hashSize)
+		copy(s.h, protocolName)
+	} else {
+		s.h = h.sum([]byte(protocolName))
+	}
+	s.ck = append([]byte{}, s.h...)
+
+	return s
+}
+
+func (s *symmetricState) mixKey(ikm []byte) {
+	out := s.hash.hkdf(s.ck, ikm, 2)
+	keys.Wipe(s.ck)
+	s.ck = out[0]
+	s.cs.initialize(out[1])
+	keys.Wipe(out[1])
+}
+
+func (s *symmetricState) mixHash(data []byte) {
+	s.h = s.hash.sum(s.h, data)
+}
+
+func (s *symmetricState) encryptAndHash(plaintext []byte) ([]byte, error) {
+	if !s.cs.hasKey() {
+		s.mixHash(plaintext)
+		return append([]byte{}, plaintext...), nil
+	}
+
+	ciphertext, err := s.cs.Encrypt(plaintext, s.h)
+	if err != nil {
+		return nil, err
+	}
+	s.mixHash(ciphertext)
+
+	return ciphertext, nil
+}
+
+func (s *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	if !s.cs.hasKey() {
+		s.mixHash(ciphertext)
+		return append([]byte{}, ciphertext...), nil
+	}
+
+	plaintext, err := s.cs.Decrypt(ciphertext, s.h)
+	if err != nil {
+		return nil, err
+	}
+	s.mixHash(ciphertext)
+
+	return plaintext, nil
+}
+
+// split returns the cipher states of the initiator to responder and the
+// responder to initiator directions
+func (s *symmetricState) split() (*CipherState, *CipherState) {
+	out := s.hash.hkdf(s.ck, nil, 2)
+	c1, c2 := newCipherState(out[0]), newCipherState(out[1])
+	keys.Wipe(out[0])
+	keys.Wipe(out[1])
+
+	return c1, c2
+}
+
+func (s *symmetricState) destroy() {
+	keys.Wipe(s.ck)
+	s.cs.Destroy()
+}
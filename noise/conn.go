@@ -0,0 +1,274 @@
+package noise
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/nacl"
+)
+
+// Every message on a Conn, handshake or transport, is prefixed with its length
+// as 2 big endian bytes
+const (
+	lengthSize     = 2
+	maxPayloadSize = MaxMessageSize - TagSize
+)
+
+// Conn is a net.Conn that runs a Noise handshake before the first read or
+// write and then encrypts every message. Read and Write can be called
+// concurrently with each other
+type Conn struct {
+	conn   net.Conn
+	config Config
+
+	handshakeMu   sync.Mutex
+	handshakeDone bool
+	handshakeErr  error
+	peer          *nacl.PublicKey
+	hash          []byte
+
+	readMu  sync.Mutex
+	recv    *CipherState
+	pending []byte
+
+	writeMu sync.Mutex
+	send    *CipherState
+}
+
+// Client returns a Conn that initiates the handshake over conn
+func Client(conn net.Conn, c Config) *Conn {
+	c.Initiator = true
+	return &Conn{conn: conn, config: c}
+}
+
+// Server returns a Conn that responds to the handshake over conn
+func Server(conn net.Conn, c Config) *Conn {
+	c.Initiator = false
+	return &Conn{conn: conn, config: c}
+}
+
+// Handshake runs the handshake if it has not run yet. Read and Write call it
+// automatically. A failed handshake can not be retried
+func (c *Conn) Handshake() error {
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+
+	if c.handshakeDone {
+		return c.handshakeErr
+	}
+	c.handshakeDone = true
+	c.handshakeErr = c.handshake()
+
+	return c.handshakeErr
+}
+
+func (c *Conn) handshake() error {
+	const op = "noise.Conn.Handshake"
+
+	hs, err := NewHandshakeState(c.config)
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+	defer hs.Destroy()
+
+	for !hs.Complete() {
+		if hs.WriteTurn() {
+			msg, err := hs.WriteMessage(nil)
+			if err != nil {
+				return ez.Wrap(op, err)
+			}
+			err = c.writeFrame(msg)
+			if err != nil {
+				return ez.Wrap(op, err)
+			}
+			continue
+		}
+
+		msg, err := c.readFrame()
+		if err != nil {
+			return ez.Wrap(op, err)
+		}
+		_, err = hs.ReadMessage(msg)
+		if err != nil {
+			return ez.Wrap(op, err)
+		}
+
+		// The peer is verified before this side sends anything else
+		if c.peer == nil && hs.PeerStatic() != nil {
+			c.peer = hs.PeerStatic()
+			if c.config.VerifyPeer != nil {
+				err = c.config.VerifyPeer(c.peer)
+				if err != nil {
+					return ez.New(op, ez.ENOTAUTHORIZED, "Peer static key was rejected", err)
+				}
+			}
+		}
+	}
+
+	c.hash = hs.HandshakeHash()
+	c.send, c.recv, err = hs.Split()
+	if err != nil {
+		return ez.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// PeerStatic returns the static key of the peer, or nil before the handshake
+// or if the peer is anonymous
+func (c *Conn) PeerStatic() *nacl.PublicKey {
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+
+	return c.peer
+}
+
+// HandshakeHash returns the hash of the handshake, or nil before it completed
+func (c *Conn) HandshakeHash() []byte {
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+
+	return append([]byte(nil), c.hash...)
+}
+
+// Read reads and decrypts data from the connection
+func (c *Conn) Read(b []byte) (int, error) {
+	const op = "noise.Conn.Read"
+
+	err := c.Handshake()
+	if err != nil {
+		return 0, err
+	}
+
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for len(c.pending) == 0 {
+		msg, err := c.readFrame()
+		if err == io.EOF {
+			return 0, io.EOF
+		} else if err != nil {
+			return 0, ez.Wrap(op, err)
+		}
+
+		c.pending, err = c.recv.Decrypt(msg, nil)
+		if err != nil {
+			return 0, ez.Wrap(op, err)
+		}
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+
+	return n, nil
+}
+
+// Write encrypts and writes data to the connection, in as many messages as
+// needed
+func (c *Conn) Write(b []byte) (int, error) {
+	const op = "noise.Conn.Write"
+
+	err := c.Handshake()
+	if err != nil {
+		return 0, err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for len(b) > 0 {
+		n := len(b)
+		if n > maxPayloadSize {
+			n = maxPayloadSize
+		}
+
+		msg, err := c.send.Encrypt(b[:n], nil)
+		if err != nil {
+			return written, ez.Wrap(op, err)
+		}
+		err = c.writeFrame(msg)
+		if err != nil {
+			return written, ez.Wrap(op, err)
+		}
+
+		written += n
+		b = b[n:]
+	}
+
+	return written, nil
+}
+
+// Close closes the underlying connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local address of the underlying connection
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the underlying connection
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the underlying connection
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *Conn) writeFrame(msg []byte) error {
+	const op = "noise.Conn.writeFrame"
+
+	frame := make([]byte, lengthSize+len(msg))
+	binary.BigEndian.PutUint16(frame, uint16(len(msg)))
+	copy(frame[lengthSize:], msg)
+
+	_, err := c.conn.Write(frame)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while writing to the connection", err)
+	}
+
+	return nil
+}
+
+// readFrame returns io.EOF if the connection was closed between messages
+func (c *Conn) readFrame() ([]byte, error) {
+	const op = "noise.Conn.readFrame"
+
+	length := make([]byte, lengthSize)
+	_, err := io.ReadFull(c.conn, length)
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err == io.ErrUnexpectedEOF {
+		return nil, ez.New(op, ez.EINVALID, "Connection closed in the middle of a message", err)
+	} else if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while reading from the connection", err)
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint16(length))
+	_, err = io.ReadFull(c.conn, msg)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ez.New(op, ez.EINVALID, "Connection closed in the middle of a message", err)
+	} else if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while reading from the connection", err)
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,141 @@
+package noise
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/nacl"
+)
+
+// pipe returns a client and a server Conn connected by net.Pipe
+func pipe(client, server Config) (*Conn, *Conn) {
+	c, s := net.Pipe()
+	return Client(c, client), Server(s, server)
+}
+
+// handshakeBoth runs the handshake of both sides and returns their errors
+func handshakeBoth(client, server *Conn) (error, error) {
+	done := make(chan error, 1)
+	go func() {
+		err := server.Handshake()
+		if err != nil {
+			server.Close()
+		}
+		done <- err
+	}()
+
+	err := client.Handshake()
+	if err != nil {
+		client.Close()
+	}
+
+	return err, <-done
+}
+
+func TestConn(t *testing.T) {
+	// Setup
+	clientKey, clientPub := newTestKeyPair(t)
+	serverKey, serverPub := newTestKeyPair(t)
+
+	// Case 1: Should exchange data over XX and learn both static keys
+	client, server := pipe(
+		Config{Pattern: PatternXX, Hash: BLAKE2s, StaticKeyPair: clientKey},
+		Config{Pattern: PatternXX, Hash: BLAKE2s, StaticKeyPair: serverKey},
+	)
+
+	large := bytes.Repeat([]byte{'a'}, 3*MaxMessageSize)
+	go func() {
+		buf := make([]byte, 4)
+		io.ReadFull(server, buf)
+		server.Write(buf)
+		server.Write(large)
+		server.Close()
+	}()
+
+	_, err := client.Write([]byte("ping"))
+	assert.Nil(t, err)
+	out, err := io.ReadAll(client)
+	assert.Nil(t, err)
+	assert.Equal(t, append([]byte("ping"), large...), out)
+
+	assert.Equal(t, serverPub.Value, client.PeerStatic().Value)
+	assert.Equal(t, clientPub.Value, server.PeerStatic().Value)
+	assert.Equal(t, client.HandshakeHash(), server.HandshakeHash())
+
+	// Case 2: Should connect to a known server with IK and NK
+	for _, p := range []Pattern{PatternIK, PatternNK} {
+		client, server = pipe(
+			Config{Pattern: p, Hash: SHA256, StaticKeyPair: clientKey, PeerStatic: serverPub},
+			Config{Pattern: p, Hash: SHA256, StaticKeyPair: serverKey},
+		)
+		errClient, errServer := handshakeBoth(client, server)
+		assert.Nil(t, errClient)
+		assert.Nil(t, errServer)
+		assert.Equal(t, client.HandshakeHash(), server.HandshakeHash())
+	}
+
+	// Case 3: Should fail when the client expects another server
+	_, otherPub := newTestKeyPair(t)
+	client, server = pipe(
+		Config{Pattern: PatternIK, Hash: SHA256, StaticKeyPair: clientKey, PeerStatic: otherPub},
+		Config{Pattern: PatternIK, Hash: SHA256, StaticKeyPair: serverKey},
+	)
+	_, errServer := handshakeBoth(client, server)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(errServer))
+
+	// Case 4: Should abort when VerifyPeer rejects the peer
+	reject := func(peer *nacl.PublicKey) error {
+		if !bytes.Equal(peer.Value, otherPub.Value) {
+			return errors.New("unknown peer")
+		}
+		return nil
+	}
+	client, server = pipe(
+		Config{Pattern: PatternXX, Hash: SHA256, StaticKeyPair: clientKey, VerifyPeer: reject},
+		Config{Pattern: PatternXX, Hash: SHA256, StaticKeyPair: serverKey},
+	)
+	errClient, _ := handshakeBoth(client, server)
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(errClient))
+
+	_, err = client.Write([]byte("ping"))
+	assert.Equal(t, errClient, err)
+}
+
+func TestConnTampering(t *testing.T) {
+	// Setup
+	serverKey, serverPub := newTestKeyPair(t)
+	c, s := net.Pipe()
+	client := Client(c, Config{Pattern: PatternNK, Hash: BLAKE2s, PeerStatic: serverPub})
+	server := Server(&flipConn{Conn: s}, Config{Pattern: PatternNK, Hash: BLAKE2s, StaticKeyPair: serverKey})
+
+	// Case 1: Should fail to read a modified message
+	go func() {
+		client.Write([]byte("hello"))
+		client.Close()
+	}()
+
+	_, err := server.Read(make([]byte, 16))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+// flipConn flips the last bit of the first transport message it reads
+type flipConn struct {
+	net.Conn
+	reads int
+}
+
+func (f *flipConn) Read(b []byte) (int, error) {
+	n, err := f.Conn.Read(b)
+	f.reads++
+	// The handshake message and the transport message are each read as a
+	// length and a body
+	if f.reads == 4 && n > 0 {
+		b[n-1] ^= 1
+	}
+	return n, err
+}
@@ -0,0 +1,328 @@
+package noise
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"github.com/vanclief/go-crypto/nacl"
+	"golang.org/x/crypto/curve25519"
+)
+
+const dhSize = curve25519.PointSize
+
+// randReader is the source of randomness used for ephemeral keys
+var randReader io.Reader = rand.Reader
+
+// Config configures one side of a handshake
+type Config struct {
+	Pattern   Pattern
+	Hash      Hash
+	Initiator bool
+	// Prologue is data both sides must agree on, it is authenticated by the
+	// handshake but not sent
+	Prologue []byte
+	// StaticKeyPair is required when the pattern sends or pre-shares the
+	// static key of this side
+	StaticKeyPair *nacl.KeyPair
+	// PeerStatic is required by initiators of IK and NK
+	PeerStatic *nacl.PublicKey
+	// VerifyPeer is called by Conn with the static key of the peer as soon as
+	// it is received, an error aborts the handshake. It is not called for an
+	// anonymous peer
+	VerifyPeer func(peer *nacl.PublicKey) error
+}
+
+// HandshakeState runs one side of a handshake. Call WriteMessage and
+// ReadMessage in the order of the pattern until Complete, then Split. It is not
+// safe for concurrent use
+type HandshakeState struct {
+	ss        *symmetricState
+	pattern   Pattern
+	hash      Hash
+	initiator bool
+	s         []byte // static private key
+	sPub      []byte
+	e         []byte // ephemeral private key
+	ePub      []byte
+	rs        []byte // remote static public key
+	re        []byte // remote ephemeral public key
+	step      int
+	failed    bool
+}
+
+// NewHandshakeState returns the state of a handshake
+func NewHandshakeState(c Config) (*HandshakeState, error) {
+	const op = "noise.NewHandshakeState"
+
+	if len(c.Pattern.messages) == 0 {
+		return nil, ez.New(op, ez.EINVALID, "Pattern is not supported", nil)
+	} else if !c.Hash.Valid() {
+		return nil, ez.New(op, ez.EINVALID, "Hash is not supported", nil)
+	}
+
+	hs := &HandshakeState{pattern: c.Pattern, hash: c.Hash, initiator: c.Initiator}
+
+	needStatic := c.Pattern.initiatorStatic()
+	if !c.Initiator {
+		needStatic = true
+	}
+	if needStatic {
+		if c.StaticKeyPair == nil || c.StaticKeyPair.KeyPair == nil {
+			return nil, ez.New(op, ez.EINVALID, "StaticKeyPair is required by the pattern", nil)
+		}
+
+		priv, err := c.StaticKeyPair.Private()
+		if err != nil {
+			return nil, ez.Wrap(op, err)
+		}
+		hs.s = append([]byte{}, priv.Value...)
+		hs.sPub = priv.Public().Value
+	}
+
+	if c.Pattern.responderStatic {
+		if c.Initiator {
+			if c.PeerStatic == nil || c.PeerStatic.Key == nil || len(c.PeerStatic.Value) != dhSize {
+				return nil, ez.New(op, ez.EINVALID, "PeerStatic is required by the pattern", nil)
+			}
+			hs.rs = append([]byte{}, c.PeerStatic.Value...)
+		}
+	}
+
+	hs.ss = newSymmetricState(hs.ProtocolName(), c.Hash)
+	hs.ss.mixHash(c.Prologue)
+	if c.Pattern.responderStatic {
+		if c.Initiator {
+			hs.ss.mixHash(hs.rs)
+		} else {
+			hs.ss.mixHash(hs.sPub)
+		}
+	}
+
+	return hs, nil
+}
+
+// ProtocolName returns the full name of the protocol, such as
+// Noise_XX_25519_ChaChaPoly_BLAKE2s
+func (hs *HandshakeState) ProtocolName() string {
+	return "Noise_" + hs.pattern.Name + "_25519_ChaChaPoly_" + string(hs.hash)
+}
+
+// Complete returns whether every message of the pattern was processed
+func (hs *HandshakeState) Complete() bool {
+	return hs.step == len(hs.pattern.messages)
+}
+
+// WriteTurn returns whether the next message is written by this side
+func (hs *HandshakeState) WriteTurn() bool {
+	return (hs.step%2 == 0) == hs.initiator
+}
+
+// PeerStatic returns the static key of the peer, or nil if it is not known yet
+func (hs *HandshakeState) PeerStatic() *nacl.PublicKey {
+	if hs.rs == nil {
+		return nil
+	}
+	return &nacl.PublicKey{Key: keys.New(append([]byte{}, hs.rs...), keys.C25519)}
+}
+
+// HandshakeHash returns the hash that identifies the handshake, both sides
+// have the same one once it is complete. It can be used for channel binding
+func (hs *HandshakeState) HandshakeHash() []byte {
+	return append([]byte{}, hs.ss.h...)
+}
+
+// WriteMessage returns the next handshake message with the payload. The
+// payload is only encrypted once a key was agreed
+func (hs *HandshakeState) WriteMessage(payload []byte) ([]byte, error) {
+	const op = "noise.HandshakeState.WriteMessage"
+
+	if err := hs.checkTurn(op, true); err != nil {
+		return nil, err
+	}
+
+	var msg []byte
+	for _, t := range hs.pattern.messages[hs.step] {
+		switch t {
+		case tokenE:
+			err := hs.generateEphemeral()
+			if err != nil {
+				return nil, hs.fail(op, err)
+			}
+			msg = append(msg, hs.ePub...)
+			hs.ss.mixHash(hs.ePub)
+		case tokenS:
+			ciphertext, err := hs.ss.encryptAndHash(hs.sPub)
+			if err != nil {
+				return nil, hs.fail(op, err)
+			}
+			msg = append(msg, ciphertext...)
+		default:
+			err := hs.mixDH(t)
+			if err != nil {
+				return nil, hs.fail(op, err)
+			}
+		}
+	}
+
+	ciphertext, err := hs.ss.encryptAndHash(payload)
+	if err != nil {
+		return nil, hs.fail(op, err)
+	}
+	msg = append(msg, ciphertext...)
+
+	if len(msg) > MaxMessageSize {
+		return nil, hs.fail(op, ez.New(op, ez.EINVALID, "Message is larger than a Noise message", nil))
+	}
+	hs.step++
+
+	return msg, nil
+}
+
+// ReadMessage processes the next handshake message of the peer and returns its
+// payload
+func (hs *HandshakeState) ReadMessage(msg []byte) ([]byte, error) {
+	const op = "noise.HandshakeState.ReadMessage"
+
+	if err := hs.checkTurn(op, false); err != nil {
+		return nil, err
+	} else if len(msg) > MaxMessageSize {
+		return nil, hs.fail(op, ez.New(op, ez.EINVALID, "Message is larger than a Noise message", nil))
+	}
+
+	for _, t := range hs.pattern.messages[hs.step] {
+		switch t {
+		case tokenE:
+			if len(msg) < dhSize {
+				return nil, hs.fail(op, ez.New(op, ez.EINVALID, "Message is too short", nil))
+			}
+			hs.re = append([]byte{}, msg[:dhSize]...)
+			msg = msg[dhSize:]
+			hs.ss.mixHash(hs.re)
+		case tokenS:
+			size := dhSize
+			if hs.ss.cs.hasKey() {
+				size += TagSize
+			}
+			if len(msg) < size {
+				return nil, hs.fail(op, ez.New(op, ez.EINVALID, "Message is too short", nil))
+			}
+
+			rs, err := hs.ss.decryptAndHash(msg[:size])
+			if err != nil {
+				return nil, hs.fail(op, err)
+			}
+			hs.rs = rs
+			msg = msg[size:]
+		default:
+			err := hs.mixDH(t)
+			if err != nil {
+				return nil, hs.fail(op, err)
+			}
+		}
+	}
+
+	payload, err := hs.ss.decryptAndHash(msg)
+	if err != nil {
+		return nil, hs.fail(op, err)
+	}
+	hs.step++
+
+	return payload, nil
+}
+
+// Split returns the cipher states for sending and receiving transport
+// messages once the handshake is complete, and erases the handshake keys
+func (hs *HandshakeState) Split() (send, recv *CipherState, err error) {
+	const op = "noise.HandshakeState.Split"
+
+	if !hs.Complete() {
+		return nil, nil, ez.New(op, ez.EINVALID, "Handshake is not complete", nil)
+	} else if hs.ss.ck == nil {
+		return nil, nil, ez.New(op, ez.EINVALID, "Handshake was already split", nil)
+	}
+
+	c1, c2 := hs.ss.split()
+	hs.Destroy()
+
+	if hs.initiator {
+		return c1, c2, nil
+	}
+	return c2, c1, nil
+}
+
+// Destroy zeroes the private keys and the chaining key of the handshake
+func (hs *HandshakeState) Destroy() {
+	keys.Wipe(hs.s)
+	keys.Wipe(hs.e)
+	hs.ss.destroy()
+	hs.ss.ck = nil
+}
+
+func (hs *HandshakeState) checkTurn(op string, write bool) error {
+	if hs.failed {
+		return ez.New(op, ez.EINVALID, "Handshake has failed", nil)
+	} else if hs.Complete() {
+		return ez.New(op, ez.EINVALID, "Handshake is complete", nil)
+	} else if hs.WriteTurn() != write {
+		return ez.New(op, ez.EINVALID, "It is not the turn of this side", nil)
+	}
+	return nil
+}
+
+// fail marks the handshake as failed, it can not continue after an error
+func (hs *HandshakeState) fail(op string, err error) error {
+	hs.failed = true
+	hs.Destroy()
+	return ez.Wrap(op, err)
+}
+
+func (hs *HandshakeState) generateEphemeral() error {
+	const op = "noise.HandshakeState.generateEphemeral"
+
+	e := make([]byte, curve25519.ScalarSize)
+	_, err := io.ReadFull(randReader, e)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while generating the ephemeral key", err)
+	}
+
+	pub, err := curve25519.X25519(e, curve25519.Basepoint)
+	if err != nil {
+		return ez.New(op, ez.EINTERNAL, "Error while generating the ephemeral key", err)
+	}
+	hs.e, hs.ePub = e, pub
+
+	return nil
+}
+
+// mixDH mixes the Diffie-Hellman of a token into the chaining key. The first
+// letter is the key of the initiator, the second of the responder
+func (hs *HandshakeState) mixDH(t token) error {
+	const op = "noise.HandshakeState.mixDH"
+
+	var priv, pub []byte
+	switch {
+	case t == tokenEE:
+		priv, pub = hs.e, hs.re
+	case t == tokenSS:
+		priv, pub = hs.s, hs.rs
+	case (t == tokenES) == hs.initiator:
+		priv, pub = hs.e, hs.rs
+	default:
+		priv, pub = hs.s, hs.re
+	}
+
+	if priv == nil || pub == nil {
+		return ez.New(op, ez.EINVALID, "Key for "+string(t)+" is missing", nil)
+	}
+
+	shared, err := curve25519.X25519(priv, pub)
+	if err != nil {
+		return ez.New(op, ez.EINVALID, "Peer key is a low order point", nil)
+	}
+	defer keys.Wipe(shared)
+	hs.ss.mixKey(shared)
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package noise
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/keys"
+	"github.com/vanclief/go-crypto/nacl"
+)
+
+// testVector follows the format of the cacophony vectors. The vectors in
+// testdata are the XX, IK and NK vectors of github.com/flynn/noise. Transport
+// messages alternate directions starting with the initiator
+type testVector struct {
+	ProtocolName     string `json:"protocol_name"`
+	InitPrologue     string `json:"init_prologue"`
+	InitStatic       string `json:"init_static"`
+	InitEphemeral    string `json:"init_ephemeral"`
+	InitRemoteStatic string `json:"init_remote_static"`
+	RespPrologue     string `json:"resp_prologue"`
+	RespStatic       string `json:"resp_static"`
+	RespEphemeral    string `json:"resp_ephemeral"`
+	RespRemoteStatic string `json:"resp_remote_static"`
+	Messages         []struct {
+		Payload    string `json:"payload"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"messages"`
+}
+
+var testPatterns = map[string]Pattern{"XX": PatternXX, "IK": PatternIK, "NK": PatternNK}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	assert.Nil(t, err)
+	return b
+}
+
+func keyPairFromHex(t *testing.T, s string) *nacl.KeyPair {
+	if s == "" {
+		return nil
+	}
+	priv := mustHex(t, s)
+	pub := (&nacl.PrivateKey{Key: keys.New(priv, keys.C25519)}).Public()
+	return &nacl.KeyPair{KeyPair: keys.NewKeyPair(pub.Value, priv, keys.C25519)}
+}
+
+func publicKeyFromHex(t *testing.T, s string) *nacl.PublicKey {
+	if s == "" {
+		return nil
+	}
+	pub, err := nacl.NewPublicKey(mustHex(t, s))
+	assert.Nil(t, err)
+	return pub
+}
+
+func newTestKeyPair(t *testing.T) (*nacl.KeyPair, *nacl.PublicKey) {
+	kp, err := nacl.NewKeyPair()
+	assert.Nil(t, err)
+	pub, err := kp.Public()
+	assert.Nil(t, err)
+	return kp, pub
+}
+
+// handshake runs a handshake in memory and returns the cipher states of the
+// initiator and the responder
+func handshake(t *testing.T, hsI, hsR *HandshakeState) (sendI, recvI, sendR, recvR *CipherState) {
+	writer, reader := hsI, hsR
+	for !hsI.Complete() {
+		msg, err := writer.WriteMessage(nil)
+		assert.Nil(t, err)
+		_, err = reader.ReadMessage(msg)
+		assert.Nil(t, err)
+		writer, reader = reader, writer
+	}
+	assert.True(t, hsR.Complete())
+
+	sendI, recvI, err := hsI.Split()
+	assert.Nil(t, err)
+	sendR, recvR, err = hsR.Split()
+	assert.Nil(t, err)
+
+	return sendI, recvI, sendR, recvR
+}
+
+func TestVectors(t *testing.T) {
+	// Setup
+	b, err := os.ReadFile("testdata/vectors.json")
+	assert.Nil(t, err)
+
+	var file struct {
+		Vectors []testVector `json:"vectors"`
+	}
+	assert.Nil(t, json.Unmarshal(b, &file))
+	assert.Len(t, file.Vectors, 24)
+	defer func(r io.Reader) { randReader = r }(randReader)
+
+	for _, v := range file.Vectors {
+		t.Run(v.ProtocolName, func(t *testing.T) {
+			parts := strings.Split(v.ProtocolName, "_")
+			pattern := testPatterns[parts[1]]
+
+			// Ephemeral keys are generated by the initiator first
+			randReader = bytes.NewReader(append(mustHex(t, v.InitEphemeral), mustHex(t, v.RespEphemeral)...))
+
+			hsI, err := NewHandshakeState(Config{
+				Pattern:       pattern,
+				Hash:          Hash(parts[4]),
+				Initiator:     true,
+				Prologue:      mustHex(t, v.InitPrologue),
+				StaticKeyPair: keyPairFromHex(t, v.InitStatic),
+				PeerStatic:    publicKeyFromHex(t, v.InitRemoteStatic),
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, v.ProtocolName, hsI.ProtocolName())
+
+			hsR, err := NewHandshakeState(Config{
+				Pattern:       pattern,
+				Hash:          Hash(parts[4]),
+				Prologue:      mustHex(t, v.RespPrologue),
+				StaticKeyPair: keyPairFromHex(t, v.RespStatic),
+				PeerStatic:    publicKeyFromHex(t, v.RespRemoteStatic),
+			})
+			assert.Nil(t, err)
+
+			var sendI, recvI, sendR, recvR *CipherState
+			for i, m := range v.Messages {
+				payload := mustHex(t, m.Payload)
+
+				if i < len(pattern.messages) {
+					writer, reader := hsI, hsR
+					if i%2 != 0 {
+						writer, reader = hsR, hsI
+					}
+
+					msg, err := writer.WriteMessage(payload)
+					assert.Nil(t, err)
+					assert.Equal(t, m.Ciphertext, hex.EncodeToString(msg))
+
+					plaintext, err := reader.ReadMessage(msg)
+					assert.Nil(t, err)
+					assert.Equal(t, m.Payload, hex.EncodeToString(plaintext))
+
+					if hsI.Complete() {
+						assert.Equal(t, hsI.HandshakeHash(), hsR.HandshakeHash())
+						sendI, recvI, err = hsI.Split()
+						assert.Nil(t, err)
+						sendR, recvR, err = hsR.Split()
+						assert.Nil(t, err)
+					}
+					continue
+				}
+
+				enc, dec := sendI, recvR
+				if (i-len(pattern.messages))%2 != 0 {
+					enc, dec = sendR, recvI
+				}
+
+				ciphertext, err := enc.Encrypt(payload, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, m.Ciphertext, hex.EncodeToString(ciphertext))
+
+				plaintext, err := dec.Decrypt(ciphertext, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, m.Payload, hex.EncodeToString(plaintext))
+			}
+		})
+	}
+}
+
+func TestHandshakeState(t *testing.T) {
+	// Setup
+	initiator, initiatorPub := newTestKeyPair(t)
+	responder, responderPub := newTestKeyPair(t)
+
+	// Case 1: Should authenticate both static keys with XX
+	for _, h := range []Hash{SHA256, BLAKE2s} {
+		hsI, err := NewHandshakeState(Config{Pattern: PatternXX, Hash: h, Initiator: true, StaticKeyPair: initiator})
+		assert.Nil(t, err)
+		hsR, err := NewHandshakeState(Config{Pattern: PatternXX, Hash: h, StaticKeyPair: responder})
+		assert.Nil(t, err)
+
+		sendI, recvI, sendR, recvR := handshake(t, hsI, hsR)
+		assert.Equal(t, responderPub.Value, hsI.PeerStatic().Value)
+		assert.Equal(t, initiatorPub.Value, hsR.PeerStatic().Value)
+
+		ciphertext, err := sendI.Encrypt([]byte("ping"), nil)
+		assert.Nil(t, err)
+		plaintext, err := recvR.Decrypt(ciphertext, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("ping"), plaintext)
+
+		ciphertext, err = sendR.Encrypt([]byte("pong"), nil)
+		assert.Nil(t, err)
+		plaintext, err = recvI.Decrypt(ciphertext, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("pong"), plaintext)
+	}
+
+	// Case 2: Should keep the initiator anonymous with NK
+	hsI, err := NewHandshakeState(Config{Pattern: PatternNK, Hash: BLAKE2s, Initiator: true, PeerStatic: responderPub})
+	assert.Nil(t, err)
+	hsR, err := NewHandshakeState(Config{Pattern: PatternNK, Hash: BLAKE2s, StaticKeyPair: responder})
+	assert.Nil(t, err)
+	handshake(t, hsI, hsR)
+	assert.Nil(t, hsR.PeerStatic())
+
+	// Case 3: Should fail when the initiator expects another responder
+	_, other := newTestKeyPair(t)
+	hsI, _ = NewHandshakeState(Config{Pattern: PatternIK, Hash: SHA256, Initiator: true, StaticKeyPair: initiator, PeerStatic: other})
+	hsR, _ = NewHandshakeState(Config{Pattern: PatternIK, Hash: SHA256, StaticKeyPair: responder})
+	msg, err := hsI.WriteMessage(nil)
+	assert.Nil(t, err)
+	_, err = hsR.ReadMessage(msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should not continue after a failure
+	_, err = hsR.WriteMessage(nil)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should fail when the prologues differ
+	hsI, _ = NewHandshakeState(Config{Pattern: PatternNK, Hash: SHA256, Initiator: true, PeerStatic: responderPub, Prologue: []byte("a")})
+	hsR, _ = NewHandshakeState(Config{Pattern: PatternNK, Hash: SHA256, StaticKeyPair: responder, Prologue: []byte("b")})
+	msg, _ = hsI.WriteMessage(nil)
+	_, err = hsR.ReadMessage(msg)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should enforce the order of the messages
+	hsI, _ = NewHandshakeState(Config{Pattern: PatternXX, Hash: SHA256, Initiator: true, StaticKeyPair: initiator})
+	_, err = hsI.ReadMessage(make([]byte, 32))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, _, err = hsI.Split()
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 7: Should require the keys of the pattern
+	_, err = NewHandshakeState(Config{Pattern: PatternIK, Hash: SHA256, Initiator: true, StaticKeyPair: initiator})
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = NewHandshakeState(Config{Pattern: PatternXX, Hash: SHA256})
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = NewHandshakeState(Config{Pattern: PatternXX, Hash: "MD5", StaticKeyPair: responder})
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestCipherState(t *testing.T) {
+	// Setup
+	key := bytes.Repeat([]byte{1}, keySize)
+	send, recv := newCipherState(key), newCipherState(key)
+
+	// Case 1: Should reject a replayed message
+	ciphertext, err := send.Encrypt([]byte("hello"), nil)
+	assert.Nil(t, err)
+	_, err = recv.Decrypt(ciphertext, nil)
+	assert.Nil(t, err)
+	_, err = recv.Decrypt(ciphertext, nil)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	assert.Equal(t, uint64(1), recv.Nonce())
+
+	// Case 2: Should decrypt after both sides rekey
+	assert.Nil(t, send.Rekey())
+	assert.Nil(t, recv.Rekey())
+	ciphertext, _ = send.Encrypt([]byte("hello"), nil)
+	plaintext, err := recv.Decrypt(ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), plaintext)
+
+	// Case 3: Should refuse the last nonce
+	send.nonce = 1<<64 - 1
+	_, err = send.Encrypt([]byte("hello"), nil)
+	assert.Equal(t, ErrNonceExhausted, err)
+
+	// Case 4: Should refuse messages larger than a Noise message
+	_, err = recv.Encrypt(make([]byte, MaxMessageSize), nil)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should not be used after Destroy
+	recv.Destroy()
+	_, err = recv.Encrypt([]byte("hello"), nil)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
@@ -0,0 +1,70 @@
+package noise
+
+type token string
+
+const (
+	tokenE  token = "e"
+	tokenS  token = "s"
+	tokenEE token = "ee"
+	tokenES token = "es"
+	tokenSE token = "se"
+	tokenSS token = "ss"
+)
+
+// Pattern is a Noise handshake pattern. Messages alternate between the
+// initiator and the responder, starting with the initiator
+type Pattern struct {
+	Name string
+
+	// responderStatic is set when the initiator knows the static key of the
+	// responder before the handshake, the "<- s" pre-message
+	responderStatic bool
+	messages        [][]token
+}
+
+var (
+	// PatternXX transmits both static keys during the handshake, neither
+	// side needs to know the other beforehand
+	PatternXX = Pattern{
+		Name: "XX",
+		messages: [][]token{
+			{tokenE},
+			{tokenE, tokenEE, tokenS, tokenES},
+			{tokenS, tokenSE},
+		},
+	}
+
+	// PatternIK sends the static key of the initiator in the first message
+	// to a responder whose static key it already knows
+	PatternIK = Pattern{
+		Name:            "IK",
+		responderStatic: true,
+		messages: [][]token{
+			{tokenE, tokenES, tokenS, tokenSS},
+			{tokenE, tokenEE, tokenSE},
+		},
+	}
+
+	// PatternNK authenticates a responder whose static key the initiator
+	// already knows, the initiator stays anonymous
+	PatternNK = Pattern{
+		Name:            "NK",
+		responderStatic: true,
+		messages: [][]token{
+			{tokenE, tokenES},
+			{tokenE, tokenEE},
+		},
+	}
+)
+
+// initiatorStatic returns whether the initiator has a static key
+func (p Pattern) initiatorStatic() bool {
+	for i := 0; i < len(p.messages); i += 2 {
+		for _, t := range p.messages[i] {
+			if t == tokenS {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package pbe
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/argon2"
+	"github.com/vanclief/go-crypto/nacl"
+	"github.com/vanclief/go-crypto/utils"
+)
+
+// A ciphertext starts with a header made of the version byte, the argon2 time
+// and memory as 4 big endian bytes each, the argon2 threads, the salt and the
+// nonce, followed by the secretbox sealed with the derived key. The header is
+// not authenticated on its own, any change derives another key and the
+// secretbox fails to open
+const (
+	// HeaderSize is the size of the header before the secretbox
+	HeaderSize = 1 + 4 + 4 + 1 + SaltSize + NonceSize
+	// SaltSize is the size of the random salt
+	SaltSize = 16
+	// NonceSize is the size of the secretbox nonce
+	NonceSize = 24
+
+	version  byte = 1
+	overhead      = 16
+)
+
+// MaxParams are the largest argon2 parameters Decrypt accepts from a header,
+// so an untrusted ciphertext can not make it use unbounded memory or time
+var MaxParams = argon2.Params{Time: 16, Memory: 1024 * 1024, Threads: 16}
+
+// randReader is the source of randomness used for salts and nonces
+var randReader io.Reader = rand.Reader
+
+// Header represents the parameters needed to derive the key of a ciphertext
+type Header struct {
+	Params argon2.Params
+	Salt   []byte
+	Nonce  []byte
+}
+
+// Encrypt encrypts the plaintext with a key derived from the passphrase with
+// the default argon2 parameters
+func Encrypt(plaintext []byte, passphrase string) ([]byte, error) {
+	return EncryptWithParams(plaintext, passphrase, argon2.DefaultParams)
+}
+
+// EncryptWithParams encrypts the plaintext with a key derived from the
+// passphrase with the given argon2 parameters
+func EncryptWithParams(plaintext []byte, passphrase string, p argon2.Params) ([]byte, error) {
+	const op = "pbe.EncryptWithParams"
+
+	if passphrase == "" {
+		return nil, ez.New(op, ez.EINVALID, "Passphrase can not be empty", nil)
+	} else if err := p.Validate(); err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if p.Exceeds(MaxParams) {
+		return nil, ez.New(op, ez.EINVALID, "Parameters exceed MaxParams and could not be decrypted", nil)
+	}
+
+	h := &Header{Params: p, Salt: make([]byte, SaltSize), Nonce: make([]byte, NonceSize)}
+	_, err := io.ReadFull(randReader, h.Salt)
+	if err == nil {
+		_, err = io.ReadFull(randReader, h.Nonce)
+	}
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the salt and nonce", err)
+	}
+
+	key, err := argon2.IDKey([]byte(passphrase), h.Salt, h.Params)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer key.Destroy()
+
+	box, err := nacl.SecretboxSeal(plaintext, key.Value, nacl.NonceFromBytes(h.Nonce))
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return append(h.Bytes(), box...), nil
+}
+
+// Decrypt decrypts a ciphertext created by Encrypt with the passphrase. The
+// parameters of the header must not exceed MaxParams
+func Decrypt(ciphertext []byte, passphrase string) ([]byte, error) {
+	return DecryptWithLimits(ciphertext, passphrase, MaxParams)
+}
+
+// DecryptWithLimits decrypts a ciphertext created by Encrypt with the
+// passphrase. The parameters of the header must not exceed max
+func DecryptWithLimits(ciphertext []byte, passphrase string, max argon2.Params) ([]byte, error) {
+	const op = "pbe.DecryptWithLimits"
+
+	h, err := ParseHeader(ciphertext)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	} else if h.Params.Exceeds(max) {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext parameters exceed the limits", nil)
+	} else if len(ciphertext) < HeaderSize+overhead {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is too short", nil)
+	} else if passphrase == "" {
+		return nil, ez.New(op, ez.EINVALID, "Passphrase can not be empty", nil)
+	}
+
+	key, err := argon2.IDKey([]byte(passphrase), h.Salt, h.Params)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer key.Destroy()
+
+	plaintext, err := nacl.SecretboxOpen(ciphertext[HeaderSize:], key.Value, nacl.NonceFromBytes(h.Nonce))
+	if err != nil {
+		return nil, ez.New(op, ez.ENOTAUTHORIZED, "Could not decrypt, invalid ciphertext or passphrase", nil)
+	}
+
+	return plaintext, nil
+}
+
+// EncryptString encrypts a message like Encrypt and returns the ciphertext
+// encoded in base64
+func EncryptString(message, passphrase string) (string, error) {
+	const op = "pbe.EncryptString"
+
+	b, err := Encrypt([]byte(message), passphrase)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return utils.BytesToBase64(b), nil
+}
+
+// DecryptString decrypts a base64 ciphertext created by EncryptString
+func DecryptString(ciphertext, passphrase string) (string, error) {
+	const op = "pbe.DecryptString"
+
+	b, err := utils.Base64ToBytes(ciphertext)
+	if err != nil {
+		return "", ez.New(op, ez.EINVALID, "Ciphertext is not valid base64", err)
+	}
+
+	msg, err := Decrypt(b, passphrase)
+	if err != nil {
+		return "", ez.Wrap(op, err)
+	}
+
+	return string(msg), nil
+}
+
+// ParseHeader decodes the header of a ciphertext and validates its parameters
+func ParseHeader(ciphertext []byte) (*Header, error) {
+	const op = "pbe.ParseHeader"
+
+	if len(ciphertext) < HeaderSize {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is too short", nil)
+	} else if ciphertext[0] != version {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext has an unsupported version", nil)
+	}
+
+	h := &Header{
+		Params: argon2.Params{
+			Time:    binary.BigEndian.Uint32(ciphertext[1:5]),
+			Memory:  binary.BigEndian.Uint32(ciphertext[5:9]),
+			Threads: ciphertext[9],
+		},
+		Salt:  append([]byte{}, ciphertext[10:10+SaltSize]...),
+		Nonce: append([]byte{}, ciphertext[10+SaltSize:HeaderSize]...),
+	}
+
+	err := h.Params.Validate()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return h, nil
+}
+
+// Bytes returns the binary encoding of the header
+func (h *Header) Bytes() []byte {
+	b := make([]byte, 10, HeaderSize)
+	b[0] = version
+	binary.BigEndian.PutUint32(b[1:5], h.Params.Time)
+	binary.BigEndian.PutUint32(b[5:9], h.Params.Memory)
+	b[9] = h.Params.Threads
+	b = append(b, h.Salt...)
+	b = append(b, h.Nonce...)
+
+	return b
+}
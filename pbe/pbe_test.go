@@ -0,0 +1,90 @@
+package pbe
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/argon2"
+)
+
+// fastParams keep the tests quick, they are not meant for real passphrases
+var fastParams = argon2.Params{Time: 1, Memory: 1024, Threads: 1}
+
+func TestEncryptDecrypt(t *testing.T) {
+	// Setup
+	passphrase := "correct horse battery staple"
+
+	// Case 1: Should decrypt with only the passphrase
+	ciphertext, err := EncryptWithParams([]byte("backup"), passphrase, fastParams)
+	assert.Nil(t, err)
+	assert.Equal(t, HeaderSize+len("backup")+16, len(ciphertext))
+
+	plaintext, err := Decrypt(ciphertext, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("backup"), plaintext)
+
+	h, err := ParseHeader(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, fastParams, h.Params)
+
+	// Case 2: Should use a new salt and nonce every time
+	other, _ := EncryptWithParams([]byte("backup"), passphrase, fastParams)
+	assert.NotEqual(t, ciphertext[:HeaderSize], other[:HeaderSize])
+
+	// Case 3: Should fail with another passphrase
+	_, err = Decrypt(ciphertext, "wrong passphrase")
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	// Case 4: Should fail when the header is modified
+	modified := append([]byte{}, ciphertext...)
+	modified[12] ^= 1
+	_, err = Decrypt(modified, passphrase)
+	assert.Equal(t, ez.ENOTAUTHORIZED, ez.ErrorCode(err))
+
+	// Case 5: Should fail with an empty passphrase
+	_, err = EncryptWithParams([]byte("backup"), "", fastParams)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 6: Should round trip strings with the default parameters
+	s, err := EncryptString("backup", passphrase)
+	assert.Nil(t, err)
+	msg, err := DecryptString(s, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, "backup", msg)
+}
+
+func TestDecryptLimits(t *testing.T) {
+	// Setup
+	ciphertext, _ := EncryptWithParams([]byte("backup"), "passphrase", fastParams)
+
+	// Case 1: Should reject parameters above the limits before deriving the key
+	huge := append([]byte{}, ciphertext...)
+	binary.BigEndian.PutUint32(huge[5:9], 64*1024*1024)
+	_, err := Decrypt(huge, "passphrase")
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 2: Should apply custom limits
+	_, err = DecryptWithLimits(ciphertext, "passphrase", argon2.Params{Time: 1, Memory: 512, Threads: 1})
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should reject invalid parameters
+	zero := append([]byte{}, ciphertext...)
+	binary.BigEndian.PutUint32(zero[1:5], 0)
+	_, err = Decrypt(zero, "passphrase")
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 4: Should reject an unknown version and truncated ciphertexts
+	version := append([]byte{}, ciphertext...)
+	version[0] = 2
+	_, err = Decrypt(version, "passphrase")
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	_, err = Decrypt(ciphertext[:HeaderSize+15], "passphrase")
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should not encrypt with parameters that could not be decrypted
+	_, err = EncryptWithParams([]byte("backup"), "passphrase", argon2.Params{Time: 64, Memory: 1024, Threads: 1})
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
@@ -0,0 +1,342 @@
+package xwing
+
+import (
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha3"
+	"crypto/subtle"
+	"io"
+
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/aead"
+	"github.com/vanclief/go-crypto/keys"
+	"github.com/vanclief/go-crypto/nacl"
+	"golang.org/x/crypto/curve25519"
+)
+
+// X-Wing combines ML-KEM-768 and X25519. A public key is the ML-KEM
+// encapsulation key followed by the X25519 public key, a ciphertext is the
+// ML-KEM ciphertext followed by the ephemeral X25519 public key. The shared
+// secret is SHA3-256 of both shared secrets, the X25519 ciphertext, the X25519
+// public key and the X-Wing label
+const (
+	// SeedSize is the size of a private key seed
+	SeedSize = 32
+	// ExpandedSize is the size of a private key without a seed, the ML-KEM
+	// seed followed by the X25519 private key
+	ExpandedSize = mlkem.SeedSize + x25519Size
+	// PublicKeySize is the size of an encoded public key
+	PublicKeySize = mlkem.EncapsulationKeySize768 + x25519Size
+	// CiphertextSize is the size of an encapsulated shared secret
+	CiphertextSize = mlkem.CiphertextSize768 + x25519Size
+	// SharedSecretSize is the size of the shared secret, it can be used
+	// directly as an aead key
+	SharedSecretSize = 32
+
+	x25519Size = curve25519.PointSize
+	label      = `\./` + `/^\`
+)
+
+// randReader is the source of randomness used for keys and ephemeral X25519
+// keys
+var randReader io.Reader = rand.Reader
+
+// mlkemEncapsulate is replaced by tests with a derandomized encapsulation
+var mlkemEncapsulate = func(ek *mlkem.EncapsulationKey768) (sharedKey, ciphertext []byte, err error) {
+	sharedKey, ciphertext = ek.Encapsulate()
+	return sharedKey, ciphertext, nil
+}
+
+// PublicKey is an X-Wing encapsulation key
+type PublicKey struct {
+	mlkem  *mlkem.EncapsulationKey768
+	x25519 []byte
+}
+
+// PrivateKey is an X-Wing decapsulation key
+type PrivateKey struct {
+	seed      []byte // nil if the key was not derived from a seed
+	mlkemSeed []byte
+	mlkem     *mlkem.DecapsulationKey768
+	x25519    []byte
+	public    *PublicKey
+}
+
+// GenerateKey returns a new private key derived from a random seed
+func GenerateKey() (*PrivateKey, error) {
+	const op = "xwing.GenerateKey"
+
+	seed := make([]byte, SeedSize)
+	_, err := io.ReadFull(randReader, seed)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the seed", err)
+	}
+	defer keys.Wipe(seed)
+
+	return NewPrivateKey(seed)
+}
+
+// NewPrivateKey decodes a private key from a 32 byte seed or from its
+// expanded form returned by Bytes
+func NewPrivateKey(b []byte) (*PrivateKey, error) {
+	const op = "xwing.NewPrivateKey"
+
+	switch len(b) {
+	case SeedSize:
+		expanded := make([]byte, ExpandedSize)
+		defer keys.Wipe(expanded)
+
+		s := sha3.NewSHAKE256()
+		s.Write(b)
+		s.Read(expanded)
+
+		sk, err := newPrivateKey(op, expanded[:mlkem.SeedSize], expanded[mlkem.SeedSize:])
+		if err != nil {
+			return nil, err
+		}
+		sk.seed = append([]byte{}, b...)
+
+		return sk, nil
+	case ExpandedSize:
+		return newPrivateKey(op, b[:mlkem.SeedSize], b[mlkem.SeedSize:])
+	default:
+		return nil, ez.New(op, ez.EINVALID, "PrivateKey must be a 32 byte seed or 96 bytes long", nil)
+	}
+}
+
+// NewPrivateKeyFromKeyPair returns a private key that reuses the X25519 key
+// of a NaCl key pair with a new ML-KEM key. Bytes returns its expanded form
+func NewPrivateKeyFromKeyPair(kp *nacl.KeyPair) (*PrivateKey, error) {
+	const op = "xwing.NewPrivateKeyFromKeyPair"
+
+	if kp == nil || kp.KeyPair == nil {
+		return nil, ez.New(op, ez.EINVALID, "KeyPair can not be nil", nil)
+	}
+
+	priv, err := kp.Private()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	mlkemSeed := make([]byte, mlkem.SeedSize)
+	_, err = io.ReadFull(randReader, mlkemSeed)
+	if err != nil {
+		return nil, ez.New(op, ez.EINTERNAL, "Error while generating the ML-KEM seed", err)
+	}
+	defer keys.Wipe(mlkemSeed)
+
+	return newPrivateKey(op, mlkemSeed, priv.Value)
+}
+
+func newPrivateKey(op string, mlkemSeed, x25519Key []byte) (*PrivateKey, error) {
+	dk, err := mlkem.NewDecapsulationKey768(mlkemSeed)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "ML-KEM seed is not valid", err)
+	}
+
+	pub, err := curve25519.X25519(x25519Key, curve25519.Basepoint)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "X25519 private key is not valid", err)
+	}
+
+	sk := &PrivateKey{
+		mlkemSeed: append([]byte{}, mlkemSeed...),
+		mlkem:     dk,
+		x25519:    append([]byte{}, x25519Key...),
+		public:    &PublicKey{mlkem: dk.EncapsulationKey(), x25519: pub},
+	}
+
+	return sk, nil
+}
+
+// Bytes returns the seed of the private key, or its expanded form if it was
+// not derived from a seed
+func (sk *PrivateKey) Bytes() []byte {
+	if sk.seed != nil {
+		return append([]byte{}, sk.seed...)
+	}
+
+	b := make([]byte, 0, ExpandedSize)
+	b = append(b, sk.mlkemSeed...)
+	return append(b, sk.x25519...)
+}
+
+// PublicKey returns the public key of the private key
+func (sk *PrivateKey) PublicKey() *PublicKey {
+	return sk.public
+}
+
+// KeyPair returns the X25519 part of the private key as a NaCl key pair
+func (sk *PrivateKey) KeyPair() *nacl.KeyPair {
+	pub := append([]byte{}, sk.public.x25519...)
+	priv := append([]byte{}, sk.x25519...)
+
+	return &nacl.KeyPair{KeyPair: keys.NewKeyPair(pub, priv, keys.C25519)}
+}
+
+// Decapsulate returns the shared secret of a ciphertext. An ML-KEM ciphertext
+// that was modified decapsulates to an unrelated secret instead of an error,
+// so the shared secret must be used with an AEAD
+func (sk *PrivateKey) Decapsulate(ciphertext []byte) ([]byte, error) {
+	const op = "xwing.PrivateKey.Decapsulate"
+
+	if sk.mlkem == nil {
+		return nil, ez.New(op, ez.EINVALID, "PrivateKey was destroyed", nil)
+	} else if len(ciphertext) != CiphertextSize {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext must be 1120 bytes long", nil)
+	}
+
+	ctM, ctX := ciphertext[:mlkem.CiphertextSize768], ciphertext[mlkem.CiphertextSize768:]
+
+	ssM, err := sk.mlkem.Decapsulate(ctM)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is not valid", err)
+	}
+	defer keys.Wipe(ssM)
+
+	ssX, err := curve25519.X25519(sk.x25519, ctX)
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext has a low order X25519 point", nil)
+	}
+	defer keys.Wipe(ssX)
+
+	return combine(ssM, ssX, ctX, sk.public.x25519), nil
+}
+
+// Destroy zeroes the private key, it can not be used afterwards
+func (sk *PrivateKey) Destroy() {
+	keys.Wipe(sk.seed)
+	keys.Wipe(sk.mlkemSeed)
+	keys.Wipe(sk.x25519)
+	sk.mlkem = nil
+}
+
+// NewPublicKey decodes a 1216 byte public key
+func NewPublicKey(b []byte) (*PublicKey, error) {
+	const op = "xwing.NewPublicKey"
+
+	if len(b) != PublicKeySize {
+		return nil, ez.New(op, ez.EINVALID, "PublicKey must be 1216 bytes long", nil)
+	}
+
+	ek, err := mlkem.NewEncapsulationKey768(b[:mlkem.EncapsulationKeySize768])
+	if err != nil {
+		return nil, ez.New(op, ez.EINVALID, "ML-KEM encapsulation key is not valid", err)
+	}
+
+	return &PublicKey{mlkem: ek, x25519: append([]byte{}, b[mlkem.EncapsulationKeySize768:]...)}, nil
+}
+
+// Bytes returns the encoding of the public key
+func (pk *PublicKey) Bytes() []byte {
+	b := make([]byte, 0, PublicKeySize)
+	b = append(b, pk.mlkem.Bytes()...)
+	return append(b, pk.x25519...)
+}
+
+// Equal returns whether both public keys are the same
+func (pk *PublicKey) Equal(other *PublicKey) bool {
+	return other != nil && subtle.ConstantTimeCompare(pk.Bytes(), other.Bytes()) == 1
+}
+
+// X25519 returns the X25519 part of the public key as a NaCl public key
+func (pk *PublicKey) X25519() *nacl.PublicKey {
+	return &nacl.PublicKey{Key: keys.New(append([]byte{}, pk.x25519...), keys.C25519)}
+}
+
+// Encapsulate returns a new shared secret and the ciphertext that the private
+// key decapsulates to it
+func (pk *PublicKey) Encapsulate() (sharedSecret, ciphertext []byte, err error) {
+	const op = "xwing.PublicKey.Encapsulate"
+
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	_, err = io.ReadFull(randReader, ephemeral)
+	if err != nil {
+		return nil, nil, ez.New(op, ez.EINTERNAL, "Error while generating the ephemeral key", err)
+	}
+	defer keys.Wipe(ephemeral)
+
+	ssM, ctM, err := mlkemEncapsulate(pk.mlkem)
+	if err != nil {
+		return nil, nil, ez.New(op, ez.EINTERNAL, "Error while encapsulating with ML-KEM", err)
+	}
+	defer keys.Wipe(ssM)
+
+	ctX, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, ez.New(op, ez.EINTERNAL, "Error while computing the ephemeral share", err)
+	}
+
+	ssX, err := curve25519.X25519(ephemeral, pk.x25519)
+	if err != nil {
+		return nil, nil, ez.New(op, ez.EINVALID, "PublicKey has a low order X25519 point", nil)
+	}
+	defer keys.Wipe(ssX)
+
+	ciphertext = make([]byte, 0, CiphertextSize)
+	ciphertext = append(ciphertext, ctM...)
+	ciphertext = append(ciphertext, ctX...)
+
+	return combine(ssM, ssX, ctX, pk.x25519), ciphertext, nil
+}
+
+// Seal encrypts the plaintext to the public key with a new shared secret and
+// XChaCha20-Poly1305. The KEM ciphertext is prefixed to the aead ciphertext
+func (pk *PublicKey) Seal(plaintext, associatedData []byte) ([]byte, error) {
+	const op = "xwing.PublicKey.Seal"
+
+	ss, kemCiphertext, err := pk.Encapsulate()
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer keys.Wipe(ss)
+
+	c, err := aead.New(aead.XChaCha20Poly1305, ss)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	ciphertext, err := c.Encrypt(plaintext, associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return append(kemCiphertext, ciphertext...), nil
+}
+
+// Open decrypts a ciphertext created by Seal with the same associated data
+func (sk *PrivateKey) Open(ciphertext, associatedData []byte) ([]byte, error) {
+	const op = "xwing.PrivateKey.Open"
+
+	if len(ciphertext) < CiphertextSize {
+		return nil, ez.New(op, ez.EINVALID, "Ciphertext is too short", nil)
+	}
+
+	ss, err := sk.Decapsulate(ciphertext[:CiphertextSize])
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+	defer keys.Wipe(ss)
+
+	c, err := aead.New(aead.XChaCha20Poly1305, ss)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	plaintext, err := c.Decrypt(ciphertext[CiphertextSize:], associatedData)
+	if err != nil {
+		return nil, ez.Wrap(op, err)
+	}
+
+	return plaintext, nil
+}
+
+func combine(ssM, ssX, ctX, pkX []byte) []byte {
+	h := sha3.New256()
+	h.Write(ssM)
+	h.Write(ssX)
+	h.Write(ctX)
+	h.Write(pkX)
+	h.Write([]byte(label))
+	return h.Sum(nil)
+}
@@ -0,0 +1,176 @@
+package xwing
+
+import (
+	"bytes"
+	"crypto/mlkem"
+	"crypto/mlkem/mlkemtest"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vanclief/ez"
+	"github.com/vanclief/go-crypto/nacl"
+)
+
+// testVector is an X-Wing vector of draft-ietf-hpke-pq, from the test data of
+// crypto/hpke. The first 32 bytes of ikmE are the ML-KEM encapsulation
+// randomness and the last 32 the ephemeral X25519 key
+type testVector struct {
+	IkmE         string `json:"ikmE"`
+	SkRm         string `json:"skRm"`
+	PkRm         string `json:"pkRm"`
+	Enc          string `json:"enc"`
+	SharedSecret string `json:"shared_secret"`
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	assert.Nil(t, err)
+	return b
+}
+
+func TestVectors(t *testing.T) {
+	// Setup
+	b, err := os.ReadFile("testdata/vectors.json")
+	assert.Nil(t, err)
+
+	var vectors []testVector
+	assert.Nil(t, json.Unmarshal(b, &vectors))
+	assert.NotEmpty(t, vectors)
+
+	defer func(r io.Reader, e func(*mlkem.EncapsulationKey768) ([]byte, []byte, error)) {
+		randReader, mlkemEncapsulate = r, e
+	}(randReader, mlkemEncapsulate)
+
+	for _, v := range vectors {
+		ikmE := mustHex(t, v.IkmE)
+
+		// Case 1: Should derive the public key from the seed
+		sk, err := NewPrivateKey(mustHex(t, v.SkRm))
+		assert.Nil(t, err)
+		assert.Equal(t, v.PkRm, hex.EncodeToString(sk.PublicKey().Bytes()))
+		assert.Equal(t, v.SkRm, hex.EncodeToString(sk.Bytes()))
+
+		// Case 2: Should encapsulate to the ciphertext of the vector
+		pk, err := NewPublicKey(mustHex(t, v.PkRm))
+		assert.Nil(t, err)
+
+		randReader = bytes.NewReader(ikmE[32:])
+		mlkemEncapsulate = func(ek *mlkem.EncapsulationKey768) ([]byte, []byte, error) {
+			return mlkemtest.Encapsulate768(ek, ikmE[:32])
+		}
+
+		ss, ct, err := pk.Encapsulate()
+		assert.Nil(t, err)
+		assert.Equal(t, v.Enc, hex.EncodeToString(ct))
+		assert.Equal(t, v.SharedSecret, hex.EncodeToString(ss))
+
+		// Case 3: Should decapsulate the shared secret of the vector
+		ss, err = sk.Decapsulate(mustHex(t, v.Enc))
+		assert.Nil(t, err)
+		assert.Equal(t, v.SharedSecret, hex.EncodeToString(ss))
+	}
+}
+
+func TestEncapsulateDecapsulate(t *testing.T) {
+	// Setup
+	sk, err := GenerateKey()
+	assert.Nil(t, err)
+
+	// Case 1: Should agree on the shared secret
+	ss, ct, err := sk.PublicKey().Encapsulate()
+	assert.Nil(t, err)
+	assert.Len(t, ct, CiphertextSize)
+	assert.Len(t, ss, SharedSecretSize)
+
+	decapsulated, err := sk.Decapsulate(ct)
+	assert.Nil(t, err)
+	assert.Equal(t, ss, decapsulated)
+
+	// Case 2: Should derive another secret from a modified ciphertext
+	modified := append([]byte{}, ct...)
+	modified[0] ^= 1
+	decapsulated, err = sk.Decapsulate(modified)
+	assert.Nil(t, err)
+	assert.NotEqual(t, ss, decapsulated)
+
+	// Case 3: Should round trip the serialized keys
+	pk, err := NewPublicKey(sk.PublicKey().Bytes())
+	assert.Nil(t, err)
+	assert.True(t, pk.Equal(sk.PublicKey()))
+
+	restored, err := NewPrivateKey(sk.Bytes())
+	assert.Nil(t, err)
+	assert.Len(t, sk.Bytes(), SeedSize)
+	assert.True(t, restored.PublicKey().Equal(sk.PublicKey()))
+
+	// Case 4: Should reject invalid encodings
+	_, err = NewPublicKey(make([]byte, PublicKeySize-1))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = NewPrivateKey(make([]byte, 64))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = sk.Decapsulate(ct[1:])
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 5: Should not decapsulate after Destroy
+	sk.Destroy()
+	_, err = sk.Decapsulate(ct)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestKeyPair(t *testing.T) {
+	// Setup
+	kp, err := nacl.NewKeyPair()
+	assert.Nil(t, err)
+
+	// Case 1: Should reuse the X25519 key of a NaCl key pair
+	sk, err := NewPrivateKeyFromKeyPair(kp)
+	assert.Nil(t, err)
+	assert.Equal(t, kp.PublicKey, sk.PublicKey().X25519().Value)
+	assert.Equal(t, kp.PrivateKey, sk.KeyPair().PrivateKey)
+
+	// Case 2: Should round trip the expanded private key
+	assert.Len(t, sk.Bytes(), ExpandedSize)
+	restored, err := NewPrivateKey(sk.Bytes())
+	assert.Nil(t, err)
+	assert.True(t, restored.PublicKey().Equal(sk.PublicKey()))
+
+	ss, ct, err := sk.PublicKey().Encapsulate()
+	assert.Nil(t, err)
+	decapsulated, err := restored.Decapsulate(ct)
+	assert.Nil(t, err)
+	assert.Equal(t, ss, decapsulated)
+
+	// Case 3: Should require a key pair
+	_, err = NewPrivateKeyFromKeyPair(nil)
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}
+
+func TestSealOpen(t *testing.T) {
+	// Setup
+	sk, _ := GenerateKey()
+	other, _ := GenerateKey()
+
+	// Case 1: Should open a sealed message
+	ciphertext, err := sk.PublicKey().Seal([]byte("archive"), []byte("ad"))
+	assert.Nil(t, err)
+	assert.Len(t, ciphertext, CiphertextSize+24+len("archive")+16)
+
+	plaintext, err := sk.Open(ciphertext, []byte("ad"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("archive"), plaintext)
+
+	// Case 2: Should fail with other associated data or another key
+	_, err = sk.Open(ciphertext, []byte("other"))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+	_, err = other.Open(ciphertext, []byte("ad"))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+
+	// Case 3: Should fail when the KEM ciphertext is modified
+	ciphertext[0] ^= 1
+	_, err = sk.Open(ciphertext, []byte("ad"))
+	assert.Equal(t, ez.EINVALID, ez.ErrorCode(err))
+}